@@ -0,0 +1,141 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// artifactReaderLifetime is the number of seconds the artifact reader pod
+// runs if it is not deleted
+const artifactReaderLifetime = 3600
+
+// artifactPaths returns the artifacts of a stage (paths below
+// /jindra/resources)
+func artifactPaths(p core.Pod) []string {
+	paths := []string{}
+	if artifacts := p.Annotations[artifactsAnnotationKey]; artifacts != "" {
+		for _, artifact := range strings.Split(artifacts, ",") {
+			paths = append(paths, strings.TrimSpace(artifact))
+		}
+	}
+
+	return paths
+}
+
+// artifactStore returns how the artifacts container accesses the artifact
+// store
+func (ppl Pipeline) artifactStore() objectStoreMount {
+	return objectStoreMount{
+		store:            ppl.Spec.Artifacts.Store,
+		defaultClaimName: defaultArtifactsClaimName,
+		volumeName:       artifactStoreVolumeName,
+		mountPath:        artifactStorePath,
+		dir:              "artifacts",
+		envPrefix:        "JINDRA_ARTIFACTS",
+	}
+}
+
+// artifactVolumes returns the volume of the artifact store if the stage has
+// artifacts and the store is a persistent volume claim
+func (ppl Pipeline) artifactVolumes(p core.Pod) []core.Volume {
+	if v, ok := ppl.artifactStore().volume(); ok && len(artifactPaths(p)) > 0 {
+		return []core.Volume{v}
+	}
+
+	return []core.Volume{}
+}
+
+// artifactsContainer returns the container that uploads the artifacts of a
+// stage once its steps finished -- regardless whether they succeeded
+func (ppl Pipeline) artifactsContainer(p core.Pod, stageName string) core.Container {
+	args := []string{
+		path.Join(toolsPrefixPath, artifactsBin),
+		fmt.Sprintf("-run=%d", ppl.Status.BuildNo),
+		"-stage=" + stageName,
+		"-dir=" + resourcesPrefixPath,
+	}
+	if ppl.Spec.Artifacts.KeepRuns > 0 {
+		args = append(args, fmt.Sprintf("-keep-runs=%d", ppl.Spec.Artifacts.KeepRuns))
+	}
+	if ppl.Spec.Artifacts.MaxAge != nil {
+		args = append(args, "-max-age="+ppl.Spec.Artifacts.MaxAge.Duration.String())
+	}
+
+	c := core.Container{
+		Name:            artifactsContainerName,
+		Image:           toolsImage,
+		ImagePullPolicy: ppl.imagePullPolicy(),
+		VolumeMounts: append([]core.VolumeMount{
+			{Name: toolsMountName, MountPath: toolsPrefixPath, ReadOnly: true},
+			{Name: sempahoresMountName, MountPath: semaphoresPrefixPath},
+		}, jindraVolumeMounts(core.Container{}, resourceNames(p))...),
+	}
+
+	c, args = ppl.artifactStore().apply(ppl, c, args)
+	args = append(args, "upload")
+	for _, artifact := range artifactPaths(p) {
+		args = append(args, strings.TrimPrefix(artifact, resourcesPrefixPath+"/"))
+	}
+
+	c.Args = []string{"sh", "-c", fmt.Sprintf("%s %s; %s",
+		path.Join(toolsPrefixPath, waitForSemaphoreBin),
+		path.Join(semaphoresPrefixPath, StepsFinishedSemaphore),
+		strings.Join(args, " "))}
+
+	return c
+}
+
+// ArtifactReaderPod returns a pod that mounts the claim of a pvc artifact
+// store read-only, so that jindra-cli can read the artifacts of runs in the
+// cluster (kubectl exec) -- it returns the directory of the artifacts in the
+// pod as well
+func (ppl Pipeline) ArtifactReaderPod() (core.Pod, string, error) {
+	m := ppl.artifactStore()
+	v, ok := m.volume()
+	if !ok {
+		return core.Pod{}, "", fmt.Errorf("artifact store is not a persistent volume claim")
+	}
+
+	lifetime := int64(artifactReaderLifetime)
+	pod := core.Pod{
+		TypeMeta: metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("jindra.%s.artifact-reader-", ppl.Name),
+			Namespace:    ppl.Namespace,
+		},
+		Spec: core.PodSpec{
+			RestartPolicy:         core.RestartPolicyNever,
+			ActiveDeadlineSeconds: &lifetime,
+			Containers: []core.Container{{
+				Name:            "reader",
+				Image:           toolsImage,
+				ImagePullPolicy: ppl.imagePullPolicy(),
+				Command:         []string{"sleep", fmt.Sprintf("%d", artifactReaderLifetime)},
+				VolumeMounts:    []core.VolumeMount{{Name: m.volumeName, MountPath: m.mountPath, ReadOnly: true}},
+			}},
+			Volumes: []core.Volume{v},
+		},
+	}
+	ppl.hardenPod(&pod, []string{})
+
+	return pod, path.Join(m.mountPath, m.location(ppl)), nil
+}
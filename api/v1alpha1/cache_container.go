@@ -0,0 +1,140 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+)
+
+// cacheNames returns the names of the caches a stage uses
+func cacheNames(p core.Pod) []string {
+	names := []string{}
+	if caches := p.Annotations[cachesAnnotationKey]; caches != "" {
+		names = append(names, strings.Split(caches, ",")...)
+	}
+
+	return names
+}
+
+// stageCaches returns the caches a stage uses -- unknown caches are ignored
+func (ppl Pipeline) stageCaches(p core.Pod) []Cache {
+	caches := []Cache{}
+	for _, name := range cacheNames(p) {
+		for _, cache := range ppl.Spec.Caches {
+			if cache.Name == name {
+				caches = append(caches, cache)
+			}
+		}
+	}
+
+	return caches
+}
+
+// cacheStore returns how cache containers access the cache store
+func (ppl Pipeline) cacheStore() objectStoreMount {
+	return objectStoreMount{
+		store:            ppl.Spec.CacheStore,
+		defaultClaimName: defaultCacheClaimName,
+		volumeName:       cacheStoreVolumeName,
+		mountPath:        cacheStorePath,
+		dir:              "caches",
+		envPrefix:        "JINDRA_CACHE",
+	}
+}
+
+// cacheVolumes returns the volumes of the caches of a stage and the volume of
+// the store if it is a persistent volume claim
+func (ppl Pipeline) cacheVolumes(p core.Pod) []core.Volume {
+	caches := ppl.stageCaches(p)
+	volumes := []core.Volume{}
+	for _, cache := range caches {
+		volumes = append(volumes, core.Volume{
+			Name:         cacheVolumePrefix + cache.Name,
+			VolumeSource: core.VolumeSource{EmptyDir: &core.EmptyDirVolumeSource{}},
+		})
+	}
+
+	if v, ok := ppl.cacheStore().volume(); ok && len(caches) > 0 {
+		volumes = append(volumes, v)
+	}
+
+	return volumes
+}
+
+// cacheVolumeMounts returns the mounts of the cache directories for the
+// containers of a stage
+func (ppl Pipeline) cacheVolumeMounts(p core.Pod) []core.VolumeMount {
+	mounts := []core.VolumeMount{}
+	for _, cache := range ppl.stageCaches(p) {
+		mounts = append(mounts, core.VolumeMount{Name: cacheVolumePrefix + cache.Name, MountPath: cache.Path})
+	}
+
+	return mounts
+}
+
+// cacheContainer returns the container that restores (action restore) or
+// saves (action save) cache -- saving waits until the steps of the stage
+// finished successfully
+func (ppl Pipeline) cacheContainer(p core.Pod, cache Cache, action string) core.Container {
+	keyFiles := []string{}
+	for _, file := range cache.KeyFiles {
+		keyFiles = append(keyFiles, path.Join(resourcesPrefixPath, file))
+	}
+
+	args := []string{
+		path.Join(toolsPrefixPath, cacheBin),
+		"-name=" + cache.Name,
+		"-dir=" + path.Join(cachesPrefixPath, cache.Name),
+		"-state-file=" + path.Join(semaphoresPrefixPath, "cache-"+cache.Name),
+	}
+	if len(keyFiles) > 0 {
+		args = append(args, "-key-files="+strings.Join(keyFiles, ","))
+	}
+	if cache.MaxSize != nil {
+		args = append(args, fmt.Sprintf("-max-size=%d", cache.MaxSize.Value()))
+	}
+
+	c := core.Container{
+		Image:           toolsImage,
+		ImagePullPolicy: ppl.imagePullPolicy(),
+		VolumeMounts: append([]core.VolumeMount{
+			{Name: cacheVolumePrefix + cache.Name, MountPath: path.Join(cachesPrefixPath, cache.Name)},
+			{Name: toolsMountName, MountPath: toolsPrefixPath, ReadOnly: true},
+			{Name: sempahoresMountName, MountPath: semaphoresPrefixPath},
+		}, jindraVolumeMounts(core.Container{}, resourceNames(p))...),
+	}
+
+	c, args = ppl.cacheStore().apply(ppl, c, args)
+	args = append(args, action)
+
+	if action == "restore" {
+		c.Name = cacheRestoreContainerNamePrefix + cache.Name
+		c.Args = args
+		return c
+	}
+
+	c.Name = cacheSaveContainerNamePrefix + cache.Name
+	c.Args = []string{"sh", "-c", fmt.Sprintf("%s %s && %s",
+		path.Join(toolsPrefixPath, waitForSemaphoreBin),
+		path.Join(semaphoresPrefixPath, StepsRunningSemaphore),
+		strings.Join(args, " "))}
+
+	return c
+}
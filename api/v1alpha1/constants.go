@@ -17,7 +17,9 @@ package v1alpha1
 
 // annotation keys
 const (
+	artifactsAnnotationKey       = "jindra.io/artifacts"
 	buildNoOffsetAnnotationKey   = "jindra.io/build-no-offset"
+	cachesAnnotationKey          = "jindra.io/caches"
 	debugContainerAnnotationKey  = "jindra.io/debug-container"
 	debugResourcesAnnotationKey  = "jindra.io/debug-resources"
 	firstInitContainers          = "jindra.io/first-init-containers"
@@ -25,9 +27,38 @@ const (
 	inResourceEnvAnnotationKey   = "jindra.io/inputs-envs"
 	outResourceAnnotationKey     = "jindra.io/outputs"
 	outResourceEnvAnnotationKey  = "jindra.io/outputs-envs"
+	podSecurityAnnotationKey     = "jindra.io/pod-security"
 	servicesAnnotationKey        = "jindra.io/services"
 	waitForAnnotationKey         = "jindra.io/wait-for"
 	imagePullPolicyAnnotationKey = "jindra.io/image-pull-policy"
+	// options of the resource scripts are set per resource:
+	// jindra.io/resource-options.<resource directory>
+	resourceOptionsAnnotationKeyPrefix = "jindra.io/resource-options."
+
+	jindraAnnotationPrefix = "jindra.io/"
+)
+
+// annotation keys users can set on pipelines and stages -- keys that end
+// with a '.' are prefixes
+var (
+	pipelineAnnotationKeys = []string{
+		buildNoOffsetAnnotationKey,
+		imagePullPolicyAnnotationKey,
+		podSecurityAnnotationKey,
+		resourceOptionsAnnotationKeyPrefix,
+	}
+	stageAnnotationKeys = []string{
+		artifactsAnnotationKey,
+		cachesAnnotationKey,
+		debugContainerAnnotationKey,
+		debugResourcesAnnotationKey,
+		firstInitContainers,
+		inResourceAnnotationKey,
+		inResourceEnvAnnotationKey,
+		outResourceAnnotationKey,
+		outResourceEnvAnnotationKey,
+		servicesAnnotationKey,
+	}
 )
 
 // container image names
@@ -38,6 +69,8 @@ const (
 	runnerContainerName = "0-runner"
 	runnerImage         = "jindra/jindra-runner:latest"
 
+	artifactsContainerName = "jindra-artifacts"
+
 	podwatcherContainerName = "pod-watcher"
 	podwatcherImage         = "jindra/pod-watcher:latest"
 
@@ -62,31 +95,77 @@ const (
 
 	pipelineLabelKey = "jindra.io/pipeline"
 	runLabelKey      = "jindra.io/run"
+	stageLabelKey    = "jindra.io/stage"
 
 	resourcesPrefixPath   = "/jindra/resources"
+	rsyncTransitPath      = "/jindra/transit"
 	semaphoresPrefixPath  = "/var/lock/jindra"
 	toolsPrefixPath       = "/opt/jindra/bin"
+	toolsImagePath        = "/jindra/contrib"
+	emptyDirPath          = "/jindra/empty"
+	waitForSemaphoreBin   = "wait-for-semaphore"
+	nativeResourceBin     = "jindra-resource"
+	cacheBin              = "jindra-cache"
+	cachesPrefixPath      = "/jindra/caches"
+	cacheStorePath        = "/jindra/cache-store"
+	artifactsBin          = "jindra-artifacts"
+	artifactStorePath     = "/jindra/artifact-store"
 	resourceEnvFile       = ".jindra.resource.env"
 	inResourceStdoutFile  = ".jindra.in-resource.stdout"
 	inResourceStderrFile  = ".jindra.in-resource.stderr"
 	outResourceStdoutFile = ".jindra.out-resource.stdout"
 	outResourceStderrFile = ".jindra.out-resource.stderr"
 
-	inResourceContainerNamePrefix  = "jindra-resource-in-"
-	outResourceContainerNamePrefix = "jindra-resource-out-"
-	resourceVolumePrefix           = "jindra-resource-"
+	inResourceContainerNamePrefix   = "jindra-resource-in-"
+	outResourceContainerNamePrefix  = "jindra-resource-out-"
+	cacheRestoreContainerNamePrefix = "jindra-cache-restore-"
+	cacheSaveContainerNamePrefix    = "jindra-cache-save-"
+	transitCleanupContainerPrefix   = "jindra-transit-cleanup-"
+	cacheVolumePrefix               = "jindra-caches-"
+	resourceVolumePrefix            = "jindra-resource-"
+	nativeResourceImagePrefix       = "native:"
+	resourceTypeImagePrefix         = "type:"
+	clusterResourceTypeImagePrefix  = "clustertype:"
 
 	nameFormatString        = "jindra.%s.%d"
+	runnerRBACFormatString  = "jindra.%s.runner"
+	runRBACFormatString     = nameFormatString + ".runner"
 	rsyncSecretFormatString = nameFormatString + ".rsync-keys"
 	configMapFormatString   = nameFormatString + ".stages"
+	transitPVCFormatString  = nameFormatString + ".transit"
+	// crij resolves references of this format: ((<provider>:<path>#<key>))
+	secretReferenceFormatString = "((%s:%s#%s))"
 
-	sempahoresMountName = "jindra-semaphores"
-	toolsMountName      = "jindra-tools"
+	sempahoresMountName     = "jindra-semaphores"
+	rsyncTransitVolumeName  = "jindra-transit"
+	toolsMountName          = "jindra-tools"
+	cacheStoreVolumeName    = "jindra-cache-store"
+	artifactStoreVolumeName = "jindra-artifact-store"
+	emptyVolumeName         = "jindra-empty"
 
 	rsyncSecretPubKey     = "pub"
 	rsyncSecretPrivateKey = "priv"
-
-	runnerServiceAccount = "jindra-runner"
+	rsyncUser             = "jindra"
+	rsyncPort             = 2222
+	rrsyncBin             = "rrsync"
+
+	s3AccessKeyIDKey          = "access_key_id"
+	s3SecretAccessKeyKey      = "secret_access_key"
+	defaultTransitPVCSize     = "1Gi"
+	defaultCacheClaimName     = "jindra-caches"
+	defaultArtifactsClaimName = "jindra-artifacts"
+
+	// values of the pod security annotation of a pipeline
+	podSecurityRelaxed    = "relaxed"
+	podSecurityRestricted = "restricted"
+
+	// uid (and fs group) of the generated containers
+	nonRootUID = 1000
+
+	// seccomp profiles of pods that don't use the seccompProfile field yet
+	seccompPodAnnotationKey             = "seccomp.security.alpha.kubernetes.io/pod"
+	seccompContainerAnnotationKeyPrefix = "container.seccomp.security.alpha.kubernetes.io/"
+	seccompRuntimeDefault               = "runtime/default"
 
 	jindraStagesMountPath  = "/jindra/stages"
 	stagesRunningSemaphore = "stages-running"
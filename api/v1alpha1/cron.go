@@ -0,0 +1,120 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cronField describes a field of a cron schedule
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names []string // names of the values, starting with min
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	// 7 is sunday as well
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+var cronMacros = []string{"@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly"}
+
+// validCronSchedule returns an error if schedule is not a standard cron
+// expression (5 fields or one of the @ macros)
+func validCronSchedule(schedule string) error {
+	if strings.HasPrefix(schedule, "@") {
+		for _, macro := range cronMacros {
+			if schedule == macro {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown macro '%s' (must be one of: %s)", schedule, strings.Join(cronMacros, ", "))
+	}
+
+	fields := strings.Fields(schedule)
+	if len(fields) != len(cronFields) {
+		return fmt.Errorf("expected %d fields (minute hour day-of-month month day-of-week), found %d", len(cronFields), len(fields))
+	}
+
+	for i, field := range fields {
+		if err := cronFields[i].validate(field); err != nil {
+			return fmt.Errorf("invalid %s '%s': %s", cronFields[i].name, field, err)
+		}
+	}
+
+	return nil
+}
+
+// validate validates a comma separated list of '*', values or ranges, each
+// with an optional step
+func (f cronField) validate(field string) error {
+	for _, item := range strings.Split(field, ",") {
+		tokens := strings.SplitN(item, "/", 2)
+		if len(tokens) == 2 {
+			if step, err := strconv.Atoi(tokens[1]); err != nil || step < 1 {
+				return fmt.Errorf("step '%s' must be a positive number", tokens[1])
+			}
+		}
+
+		if tokens[0] == "*" {
+			continue
+		}
+
+		bounds := strings.SplitN(tokens[0], "-", 2)
+		first, err := f.value(bounds[0])
+		if err != nil {
+			return err
+		}
+		if len(bounds) == 2 {
+			last, err := f.value(bounds[1])
+			if err != nil {
+				return err
+			}
+			if first > last {
+				return fmt.Errorf("range '%s' must not end before it starts", tokens[0])
+			}
+		}
+	}
+
+	return nil
+}
+
+// value returns the value of the number or name s
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.ToLower(s) == name {
+			return f.min + i, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		if len(f.names) > 0 {
+			return 0, fmt.Errorf("'%s' must be a number between %d and %d or a name (%s)", s, f.min, f.max, strings.Join(f.names, ", "))
+		}
+		return 0, fmt.Errorf("'%s' must be a number between %d and %d", s, f.min, f.max)
+	}
+
+	return v, nil
+}
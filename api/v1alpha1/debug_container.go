@@ -26,13 +26,9 @@ func (ppl Pipeline) debugContainer(toolsMount, semaphoreMount core.VolumeMount,
 		Name:            debugContainerName,
 		Image:           "alpine",
 		ImagePullPolicy: ppl.imagePullPolicy(),
-		Args: []string{"sh", "-c", `touch /DELETE_ME_TO_STOP_DEBUG_CONTAINER
-echo "waiting for /DELETE_ME_TO_STOP_DEBUG_CONTAINER to be deleted "
-while test -f /DELETE_ME_TO_STOP_DEBUG_CONTAINER
-do
-  sleep 1
-  printf "."
-done`},
+		Args: []string{"sh", "-c", `touch /tmp/DELETE_ME_TO_STOP_DEBUG_CONTAINER
+echo "waiting for /tmp/DELETE_ME_TO_STOP_DEBUG_CONTAINER to be deleted "
+` + path.Join(toolsPrefixPath, waitForSemaphoreBin) + ` /tmp/DELETE_ME_TO_STOP_DEBUG_CONTAINER`},
 		Env: []core.EnvVar{
 			{Name: "JOB_IP", Value: "${MY_IP}"},
 		},
@@ -44,7 +40,7 @@ done`},
 
 	for _, resource := range resourceNames {
 		c.VolumeMounts = append(c.VolumeMounts,
-			core.VolumeMount{Name: resourceVolumePrefix + resource, MountPath: path.Join(resourcesPrefixPath, resource)},
+			core.VolumeMount{Name: resourceVolumePrefix + resourceDir(resource), MountPath: path.Join(resourcesPrefixPath, resourceDir(resource))},
 		)
 	}
 
@@ -17,7 +17,7 @@ package v1alpha1
 
 import (
 	core "k8s.io/api/core/v1"
-	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // log is for logging in this package.
@@ -58,7 +58,7 @@ func (ppl *Pipeline) setDefaultTriggerSchedule() {
 			trigger := ppl.Spec.Resources.Triggers[i]
 			if trigger.Schedule == "" {
 				defLog.Info("setting default trigger schedule", "pipeline", ppl.Name, "trigger", trigger.Name)
-				ppl.Spec.Resources.Triggers[i].Schedule = "/5 * * * *"
+				ppl.Spec.Resources.Triggers[i].Schedule = "*/5 * * * *"
 			}
 		}
 	}
@@ -0,0 +1,180 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+)
+
+// graphNode is a stage or a resource of the pipeline graph
+type graphNode struct {
+	id       string
+	label    string
+	resource bool
+}
+
+// graphEdge connects two nodes -- dashed edges are only taken if a stage
+// failed
+type graphEdge struct {
+	from   string
+	to     string
+	label  string
+	dashed bool
+}
+
+// pipelineGraph contains the stages in the order they run, the branches to
+// onSuccess, onError and final and the resources the stages read and write
+type pipelineGraph struct {
+	name  string
+	nodes []graphNode
+	edges []graphEdge
+}
+
+func (ppl Pipeline) graph() pipelineGraph {
+	g := pipelineGraph{name: ppl.Name}
+	resources := map[string]string{}
+
+	addStage := func(id string, p core.Pod, defaultName string) {
+		name := p.Name
+		if name == "" {
+			name = defaultName
+		}
+		g.nodes = append(g.nodes, graphNode{id: id, label: name})
+
+		resource := func(name string) string {
+			if _, ok := resources[name]; !ok {
+				resources[name] = fmt.Sprintf("resource%d", len(resources))
+				g.nodes = append(g.nodes, graphNode{id: resources[name], label: name, resource: true})
+			}
+			return resources[name]
+		}
+		for _, name := range inResourcesNames(p) {
+			g.edges = append(g.edges, graphEdge{from: resource(name), to: id, label: "in"})
+		}
+		for _, name := range outResourcesNames(p) {
+			g.edges = append(g.edges, graphEdge{from: id, to: resource(name), label: "out"})
+		}
+	}
+
+	stages := []string{}
+	for i, stage := range ppl.Spec.Stages {
+		id := fmt.Sprintf("stage%d", i)
+		addStage(id, stage, fmt.Sprintf("stage-%d", i+1))
+		if len(stages) > 0 {
+			g.edges = append(g.edges, graphEdge{from: stages[len(stages)-1], to: id})
+		}
+		stages = append(stages, id)
+	}
+
+	// like the runner: onSuccess runs if all stages succeeded, onError if a
+	// stage failed, final after both
+	successTarget, errorTarget := "", ""
+	if ppl.Spec.OnSuccess != nil {
+		addStage("onSuccess", *ppl.Spec.OnSuccess, "on-success")
+		successTarget = "onSuccess"
+	}
+	if ppl.Spec.OnError != nil {
+		addStage("onError", *ppl.Spec.OnError, "on-error")
+		errorTarget = "onError"
+	}
+	if ppl.Spec.Final != nil {
+		addStage("final", *ppl.Spec.Final, "final")
+		for _, branch := range []*string{&successTarget, &errorTarget} {
+			if *branch == "" {
+				*branch = "final"
+				continue
+			}
+			g.edges = append(g.edges, graphEdge{from: *branch, to: "final"})
+		}
+	}
+
+	if len(stages) > 0 && successTarget != "" {
+		g.edges = append(g.edges, graphEdge{from: stages[len(stages)-1], to: successTarget, label: "success"})
+	}
+	for _, stage := range stages {
+		if errorTarget != "" {
+			g.edges = append(g.edges, graphEdge{from: stage, to: errorTarget, label: "error", dashed: true})
+		}
+	}
+
+	return g
+}
+
+// DOT renders the stages of the pipeline, the order they run in and the
+// resources they use as a Graphviz graph
+func (ppl Pipeline) DOT() string {
+	g := ppl.graph()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %q {\n", g.name)
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range g.nodes {
+		if n.resource {
+			fmt.Fprintf(&b, "  %q [label=%q, shape=ellipse];\n", n.id, n.label)
+			continue
+		}
+		fmt.Fprintf(&b, "  %q [label=%q];\n", n.id, n.label)
+	}
+	for _, e := range g.edges {
+		attributes := []string{}
+		if e.label != "" {
+			attributes = append(attributes, fmt.Sprintf("label=%q", e.label))
+		}
+		if e.dashed {
+			attributes = append(attributes, "style=dashed")
+		}
+		if len(attributes) == 0 {
+			fmt.Fprintf(&b, "  %q -> %q;\n", e.from, e.to)
+			continue
+		}
+		fmt.Fprintf(&b, "  %q -> %q [%s];\n", e.from, e.to, strings.Join(attributes, ", "))
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// Mermaid renders the graph of DOT as a Mermaid flowchart
+func (ppl Pipeline) Mermaid() string {
+	g := ppl.graph()
+	label := strings.NewReplacer(`"`, "#quot;").Replace
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, n := range g.nodes {
+		if n.resource {
+			fmt.Fprintf(&b, "  %s([\"%s\"])\n", n.id, label(n.label))
+			continue
+		}
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", n.id, label(n.label))
+	}
+	for _, e := range g.edges {
+		arrow := "-->"
+		if e.dashed {
+			arrow = "-.->"
+		}
+		if e.label != "" {
+			arrow += "|" + label(e.label) + "|"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", e.from, arrow, e.to)
+	}
+
+	return b.String()
+}
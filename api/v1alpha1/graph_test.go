@@ -0,0 +1,82 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"io/ioutil"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestGraph(t *testing.T) {
+	ppl := getExamplePipeline(t)
+
+	for i, test := range []struct {
+		fixture string
+		got     string
+		desc    string
+	}{
+		{"http-fs.dot", ppl.DOT(), "dot graph should be correct"},
+		{"http-fs.mmd", ppl.Mermaid(), "mermaid graph should be correct"},
+	} {
+		expected, err := ioutil.ReadFile(path.Join(fixtureDir, test.fixture))
+		if err != nil {
+			t.Fatalf("error reading fixture %s: %s", test.fixture, err)
+		}
+
+		if string(expected) != test.got {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, string(expected), test.got))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestGraphBranches(t *testing.T) {
+	noBranches := getExamplePipeline(t)
+	noBranches.Spec.OnSuccess, noBranches.Spec.OnError, noBranches.Spec.Final = nil, nil, nil
+
+	onlyFinal := getExamplePipeline(t)
+	onlyFinal.Spec.OnSuccess, onlyFinal.Spec.OnError = nil, nil
+
+	onlyOnError := getExamplePipeline(t)
+	onlyOnError.Spec.OnSuccess, onlyOnError.Spec.Final = nil, nil
+
+	for i, test := range []struct {
+		ppl      Pipeline
+		expected []string
+		desc     string
+	}{
+		{noBranches, []string{"stage0 --> stage1"},
+			"stages without branches only run in order"},
+		{onlyFinal, []string{"stage0 --> stage1", "stage1 -->|success| final", "stage0 -.->|error| final", "stage1 -.->|error| final"},
+			"final runs after success and error"},
+		{onlyOnError, []string{"stage0 --> stage1", "stage0 -.->|error| onError", "stage1 -.->|error| onError"},
+			"on error runs after every stage"},
+	} {
+		got := []string{}
+		for _, line := range strings.Split(test.ppl.Mermaid(), "\n") {
+			if strings.Contains(line, "stage") && strings.Contains(line, "->") && !strings.Contains(line, "resource") {
+				got = append(got, strings.TrimSpace(line))
+			}
+		}
+
+		if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected, got))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
@@ -16,13 +16,14 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
-	"crypto/rsa"
-	"crypto/x509"
+	"encoding/binary"
 	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/ghodss/yaml"
@@ -48,6 +49,43 @@ func (ppl Pipeline) imagePullPolicy() core.PullPolicy {
 	return core.PullPolicy(ppl.Annotations[imagePullPolicyAnnotationKey])
 }
 
+// resourceOptionArgs returns the crij arguments that were configured for the
+// resource via its resource options annotation -- in scripts expect an empty
+// directory, so it is cleaned before retries
+func (ppl Pipeline) resourceOptionArgs(name string, direction string) []string {
+	args := []string{}
+	for _, line := range annotationLines(ppl.Annotations[resourceOptionsAnnotationKeyPrefix+resourceDir(name)]) {
+		tokens := strings.SplitN(line, "=", 2)
+		if len(tokens) != 2 {
+			continue
+		}
+		args = append(args, "-"+strings.TrimSpace(tokens[0])+"="+strings.TrimSpace(tokens[1]))
+		if strings.TrimSpace(tokens[0]) == "retries" && direction == "in" {
+			args = append(args, "-clean-dir-on-retry")
+		}
+	}
+
+	return args
+}
+
+// resourceDir returns the name that is used for the directory, volume and
+// env vars of a resource (transit channels like 'transit:binaries' become
+// 'transit-binaries')
+func resourceDir(name string) string {
+	return strings.Replace(name, ":", "-", 1)
+}
+
+// renameEnvPrefix replaces the prefix 'from' of all env var names with 'to'
+func renameEnvPrefix(env []core.EnvVar, from, to string) []core.EnvVar {
+	renamed := []core.EnvVar{}
+	for _, e := range env {
+		e.Name = to + strings.TrimPrefix(e.Name, from)
+		renamed = append(renamed, e)
+	}
+
+	return renamed
+}
+
 func initContainerNames(p core.Pod) []string {
 	names := []string{}
 	for _, c := range p.Spec.InitContainers {
@@ -89,24 +127,87 @@ func outResourcesNames(p core.Pod) []string {
 	return resourceNames
 }
 
+// secretNames returns the sorted names of all secrets the pods reference via
+// env vars, env sources or volumes
+func (pods stagePods) secretNames() []string {
+	seen := map[string]bool{}
+	for _, p := range pods {
+		for _, c := range append(p.Spec.InitContainers, p.Spec.Containers...) {
+			for _, env := range c.Env {
+				if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+					seen[env.ValueFrom.SecretKeyRef.Name] = true
+				}
+			}
+			for _, envFrom := range c.EnvFrom {
+				if envFrom.SecretRef != nil {
+					seen[envFrom.SecretRef.Name] = true
+				}
+			}
+		}
+		for _, v := range p.Spec.Volumes {
+			if v.Secret != nil {
+				seen[v.Secret.SecretName] = true
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// generateSSHKeyPair generates an ed25519 key pair -- the private key is
+// encoded in the openssh format (ssh doesn't read ed25519 keys in the pem
+// formats), the public key in the authorized_keys format
 func generateSSHKeyPair() (priv []byte, pub []byte, errdx error) {
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
 	if err != nil {
 		return []byte{}, []byte{}, fmt.Errorf("error generating private key: %s", err)
 	}
 
-	priv = pem.EncodeToMemory(&pem.Block{
-		Type:    "RSA PRIVATE KEY",
-		Headers: nil,
-		Bytes:   x509.MarshalPKCS1PrivateKey(privateKey),
-	})
-
-	publicRsaKey, err := ssh.NewPublicKey(privateKey.Public())
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
 	if err != nil {
 		return []byte{}, []byte{}, fmt.Errorf("error generating public key: %s", err)
 	}
 
-	return priv, ssh.MarshalAuthorizedKey(publicRsaKey), nil
+	var check [4]byte
+	if _, err := rand.Read(check[:]); err != nil {
+		return []byte{}, []byte{}, fmt.Errorf("error generating private key: %s", err)
+	}
+	checkInt := binary.BigEndian.Uint32(check[:])
+
+	// see PROTOCOL.key of openssh for the format
+	keys := ssh.Marshal(struct {
+		Check1  uint32
+		Check2  uint32
+		Keytype string
+		Pub     []byte
+		Priv    []byte
+		Comment string
+	}{checkInt, checkInt, ssh.KeyAlgoED25519, publicKey, privateKey, "jindra"})
+	for i := 1; len(keys)%8 != 0; i++ {
+		keys = append(keys, byte(i))
+	}
+
+	key := append([]byte("openssh-key-v1\x00"), ssh.Marshal(struct {
+		CipherName   string
+		KdfName      string
+		KdfOpts      string
+		NumKeys      uint32
+		PubKey       []byte
+		PrivKeyBlock []byte
+	}{"none", "none", "", 1, sshPublicKey.Marshal(), keys})...)
+
+	priv = pem.EncodeToMemory(&pem.Block{
+		Type:  "OPENSSH PRIVATE KEY",
+		Bytes: key,
+	})
+
+	return priv, ssh.MarshalAuthorizedKey(sshPublicKey), nil
 }
 
 func defaultLabels(name string, buildNo int, stageName string) map[string]string {
@@ -116,7 +217,7 @@ func defaultLabels(name string, buildNo int, stageName string) map[string]string
 	}
 
 	if stageName != "" {
-		labels["jindra.io/stage"] = stageName
+		labels[stageLabelKey] = stageName
 	}
 
 	return labels
@@ -190,3 +291,52 @@ func annotationToEnv(annotation string) map[string][]core.EnvVar {
 
 	return e
 }
+
+// closestMatch returns the candidate that is most similar to word -- or ""
+// if no candidate is similar enough to be a likely typo
+func closestMatch(word string, candidates []string) string {
+	match := ""
+	maxDistance := len(word)/3 + 1
+	for _, candidate := range candidates {
+		if d := editDistance(word, candidate); d <= maxDistance {
+			match, maxDistance = candidate, d-1
+		}
+	}
+
+	return match
+}
+
+// editDistance returns the levenshtein distance of a and b
+func editDistance(a, b string) int {
+	row := make([]int, len(b)+1)
+	for j := range row {
+		row[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current := row[j]
+			row[j] = minInt(row[j]+1, row[j-1]+1, prev+cost)
+			prev = current
+		}
+	}
+
+	return row[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
@@ -47,6 +47,67 @@ func getExamplePipeline(t *testing.T) Pipeline {
 	return p
 }
 
+// getRestrictedExamplePipeline returns the example pipeline with user
+// containers that comply with the "restricted" pod security standard
+func getRestrictedExamplePipeline(t *testing.T) Pipeline {
+	ppl := getExamplePipeline(t)
+	ppl.Annotations[podSecurityAnnotationKey] = podSecurityRestricted
+
+	pods := []*core.Pod{ppl.Spec.OnSuccess, ppl.Spec.OnError, ppl.Spec.Final}
+	for i := range ppl.Spec.Stages {
+		pods = append(pods, &ppl.Spec.Stages[i])
+	}
+
+	for _, p := range pods {
+		if p == nil {
+			continue
+		}
+		if p.Annotations == nil {
+			p.Annotations = map[string]string{}
+		}
+		p.Annotations[seccompPodAnnotationKey] = seccompRuntimeDefault
+		for i := range p.Spec.InitContainers {
+			p.Spec.InitContainers[i].SecurityContext = hardenedSecurityContext(false)
+		}
+		for i := range p.Spec.Containers {
+			p.Spec.Containers[i].SecurityContext = hardenedSecurityContext(false)
+		}
+	}
+
+	return ppl
+}
+
+// getExamplePipelineWithResourceTypes returns the example pipeline with the git
+// and slack resources referencing the resource types of the fixture file
+func getExamplePipelineWithResourceTypes(t *testing.T) Pipeline {
+	ppl := getExamplePipeline(t)
+
+	yamlData, err := ioutil.ReadFile(path.Join(fixtureDir, "resource-types.yaml"))
+	if err != nil {
+		t.Fatalf("error reading resource types file: %s", err)
+	}
+
+	catalog, err := NewResourceTypeCatalogFromYaml(yamlData)
+	if err != nil {
+		t.Fatalf("cannot convert yaml to resource types: %s", err)
+	}
+
+	ppl.Spec.Resources.Containers[0] = core.Container{Name: "git", Image: "type:github", Env: []core.EnvVar{
+		{Name: "git.source.uri", Value: "git@github.com:kesselborn/http-fs"},
+		{Name: "git.source.branch", Value: "develop"},
+	}}
+	ppl.Spec.Resources.Containers[2] = core.Container{Name: "slack", Image: "clustertype:slack", Env: []core.EnvVar{
+		{Name: "slack.source.url", Value: "https://hooks.slack.com/xxx"},
+		{Name: "slack.params.channel", Value: "jindra"},
+	}}
+
+	if err := ppl.ResolveResourceTypes(catalog); err != nil {
+		t.Fatalf("error resolving resource types: %s", err)
+	}
+
+	return ppl
+}
+
 func jsonFromYamlFile(file string, t *testing.T) []byte {
 	content, err := ioutil.ReadFile(file)
 	if err != nil {
@@ -18,11 +18,14 @@ package v1alpha1
 import (
 	"fmt"
 	"path"
+	"strings"
 
 	core "k8s.io/api/core/v1"
 )
 
-func (ppl Pipeline) jindraRunnerContainer(buildNo int) core.Container {
+// jindraRunnerContainer returns the container that runs the stages --
+// secretNames are the secrets whose values are masked in the collected logs
+func (ppl Pipeline) jindraRunnerContainer(buildNo int, secretNames []string) core.Container {
 	return core.Container{
 		Name:            runnerContainerName,
 		Image:           runnerImage,
@@ -34,16 +37,19 @@ func (ppl Pipeline) jindraRunnerContainer(buildNo int) core.Container {
 			{Name: "MY_NODE_NAME", ValueFrom: &core.EnvVarSource{FieldRef: &core.ObjectFieldSelector{FieldPath: "spec.nodeName"}}},
 			{Name: "MY_UID", ValueFrom: &core.EnvVarSource{FieldRef: &core.ObjectFieldSelector{FieldPath: "metadata.uid"}}},
 
+			{Name: "ARTIFACTS_CONTAINER_NAME", Value: artifactsContainerName},
+			{Name: "CACHE_SAVE_CONTAINER_NAME_PREFIX", Value: cacheSaveContainerNamePrefix},
 			{Name: "CONFIG_MAP_NAME_FORMAT_STRING", Value: configMapFormatString},
+			{Name: "JINDRA_MASKED_SECRETS", Value: strings.Join(secretNames, " ")},
 			{Name: "JINDRA_PIPELINE_NAME", Value: ppl.Name},
 			{Name: "JINDRA_PIPELINE_RUN_NO", Value: fmt.Sprintf("%d", buildNo)},
+			{Name: "JINDRA_RBAC_OBJECTS", Value: strings.Join(ppl.runRBACObjectNames(), " ")},
 			{Name: "JINDRA_SEMAPHORE_MOUNT_PATH", Value: semaphoresPrefixPath},
 			{Name: "JINDRA_STAGES_MOUNT_PATH", Value: "/jindra/stages"},
-			{Name: "OUT_RESOURCE_ANNOTATION_KEY", Value: outResourceAnnotationKey},
 			{Name: "OUT_RESOURCE_CONTAINER_NAME_PREFIX", Value: outResourceContainerNamePrefix},
 			{Name: "PIPELINE_LABEL_KEY", Value: pipelineLabelKey},
 			{Name: "STAGES_RUNNING_SEMAPHORE", Value: path.Join(semaphoresPrefixPath, stagesRunningSemaphore)},
-			{Name: "RSYNC_KEY_NAME_FORMAT_STRING", Value: rsyncSecretFormatString},
+			{Name: "JINDRA_TRANSIT_OBJECTS", Value: strings.Join(ppl.transitBackend().objectNames(), " ")},
 			{Name: "RUN_LABEL_KEY", Value: runLabelKey},
 			{Name: "WAIT_FOR_ANNOTATION_KEY", Value: waitForAnnotationKey},
 		},
@@ -0,0 +1,67 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"path"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+)
+
+// NativeResourceRegistry knows the native resources jindra-resource
+// implements
+// +kubebuilder:object:generate=false
+type NativeResourceRegistry interface {
+	// Exists returns true if there is a native resource with this name
+	Exists(name string) bool
+	// Names returns the sorted names of all native resources
+	Names() []string
+}
+
+// nativeResources is used by the validator to check that native resources
+// exist -- without a registry, the check is skipped
+var nativeResources NativeResourceRegistry
+
+// SetNativeResourceRegistry sets the registry the validator checks native
+// resources with -- the api package doesn't import the resources, so that
+// users of the api don't link their clients
+func SetNativeResourceRegistry(r NativeResourceRegistry) {
+	nativeResources = r
+}
+
+// nativeResourceType returns the type of a native resource (image
+// 'native:<type>') or an empty string if c is a concourse resource
+func nativeResourceType(c core.Container) string {
+	if !strings.HasPrefix(c.Image, nativeResourceImagePrefix) {
+		return ""
+	}
+
+	return strings.TrimPrefix(c.Image, nativeResourceImagePrefix)
+}
+
+// resourceScript returns the command crij calls for action (in or out) of
+// the resource container c. Native resources are run from the jindra tools
+// image, so the returned container gets the corresponding image.
+func (ppl Pipeline) resourceScript(c core.Container, action string) (core.Container, []string) {
+	nativeType := nativeResourceType(c)
+	if nativeType == "" {
+		return c, []string{"/opt/resource/" + action}
+	}
+
+	c.Image = toolsImage
+	return c, []string{path.Join(toolsPrefixPath, nativeResourceBin), nativeType, action}
+}
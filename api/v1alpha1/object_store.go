@@ -0,0 +1,117 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"path"
+
+	core "k8s.io/api/core/v1"
+)
+
+// object store backends
+const (
+	pvcObjectStoreBackend = "pvc"
+	s3ObjectStoreBackend  = "s3"
+)
+
+// objectStoreMount describes how a jindra tool (jindra-cache,
+// jindra-artifacts) accesses an object store
+type objectStoreMount struct {
+	store            ObjectStore
+	defaultClaimName string
+	// volumeName and mountPath of the claim of the pvc backend
+	volumeName string
+	mountPath  string
+	// name of the directory (pvc) or object prefix (s3) of the data of the
+	// pipeline
+	dir string
+	// envPrefix of the env vars holding the s3 credentials
+	envPrefix string
+}
+
+// volume returns the volume of the store -- false if the store is not a
+// persistent volume claim
+func (m objectStoreMount) volume() (core.Volume, bool) {
+	if m.store.Backend == s3ObjectStoreBackend {
+		return core.Volume{}, false
+	}
+
+	claimName := m.store.ClaimName
+	if claimName == "" {
+		claimName = m.defaultClaimName
+	}
+
+	return core.Volume{
+		Name: m.volumeName,
+		VolumeSource: core.VolumeSource{
+			PersistentVolumeClaim: &core.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+		},
+	}, true
+}
+
+// apply adds the env vars, arguments and mounts the tool needs to access the
+// store to c
+func (m objectStoreMount) apply(ppl Pipeline, c core.Container, args []string) (core.Container, []string) {
+	if m.store.Backend == s3ObjectStoreBackend && m.store.S3 != nil {
+		secretEnv := func(name, key string) core.EnvVar {
+			return core.EnvVar{Name: name, ValueFrom: &core.EnvVarSource{
+				SecretKeyRef: &core.SecretKeySelector{
+					Key:                  key,
+					LocalObjectReference: core.LocalObjectReference{Name: m.store.S3.CredentialsSecret},
+				},
+			}}
+		}
+		c.Env = append(c.Env,
+			secretEnv(m.envPrefix+"_ACCESS_KEY_ID", s3AccessKeyIDKey),
+			secretEnv(m.envPrefix+"_SECRET_ACCESS_KEY", s3SecretAccessKeyKey),
+		)
+
+		return c, append(args,
+			"-s3-endpoint="+m.store.S3.Endpoint,
+			"-s3-bucket="+m.store.S3.Bucket,
+			"-s3-region="+m.store.S3.Region,
+			"-s3-prefix="+m.location(ppl),
+		)
+	}
+
+	c.VolumeMounts = append(c.VolumeMounts, core.VolumeMount{Name: m.volumeName, MountPath: m.mountPath})
+	return c, append(args, "-store-dir="+path.Join(m.mountPath, m.location(ppl)))
+}
+
+// location returns the object prefix (s3) or the directory below the claim
+// (pvc) of the data of the pipeline
+func (m objectStoreMount) location(ppl Pipeline) string {
+	if m.store.Backend == s3ObjectStoreBackend && m.store.S3 != nil {
+		return path.Join(ppl.Namespace, ppl.Name, m.dir)
+	}
+
+	return path.Join(ppl.Name, m.dir)
+}
+
+// ArtifactStore returns the store that keeps the artifacts of the pipeline
+// (with the default claim name of pvc stores set) and the object prefix (s3)
+// or the directory below the claim (pvc) of the artifacts -- tools outside of
+// the cluster use it to access the artifacts
+func (ppl Pipeline) ArtifactStore() (ObjectStore, string) {
+	m := ppl.artifactStore()
+	objectStore := *m.store.DeepCopy()
+	if v, ok := m.volume(); ok {
+		objectStore.Backend = pvcObjectStoreBackend
+		objectStore.ClaimName = v.PersistentVolumeClaim.ClaimName
+	}
+
+	return objectStore, m.location(ppl)
+}
@@ -0,0 +1,223 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validationBuildNo is the build number the generated objects are validated
+// with -- names that are valid with this build number are valid for all runs
+// with a build number with less digits
+const validationBuildNo = 999999
+
+// placeholders are the values that the runner substitutes in the stage pods
+// with values that have the maximum length of the real values
+var placeholders = strings.NewReplacer(
+	"${MY_UID}", "00000000-0000-0000-0000-000000000000",
+)
+
+// validGeneratedObjects validates the objects that are generated for a run
+// like the api server would do -- errors of stage pods are reported at the
+// path of the stage, containers, volumes etc. are referenced by name as the
+// generated pods contain additional containers and volumes
+func (ppl Pipeline) validGeneratedObjects() field.ErrorList {
+	errs := field.ErrorList{}
+	ppl.Status.BuildNo = validationBuildNo
+	runnerName := fmt.Sprintf(nameFormatString, ppl.Name, validationBuildNo)
+
+	stages, err := ppl.generateStagePods(validationBuildNo)
+	if err != nil {
+		return append(errs, field.InternalError(field.NewPath("spec"), fmt.Errorf("error generating stage pods: %s", err)))
+	}
+
+	stagePaths := map[string]*field.Path{}
+	for _, s := range ppl.stagePaths() {
+		stagePaths[s.stage.Name] = s.path
+	}
+
+	keys := []string{}
+	for key := range stages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		pod := stages[key]
+		pod.Name = strings.Replace(pod.Name, "${MY_NAME}", runnerName, 1)
+		for k, v := range pod.Labels {
+			pod.Labels[k] = placeholders.Replace(v)
+		}
+		podPath, ok := stagePaths[pod.Labels[stageLabelKey]]
+		if !ok {
+			podPath = objectPath("Pod", pod.Name)
+		}
+		errs = append(errs, validatePod(pod, podPath)...)
+	}
+
+	runner, err := ppl.RunnerPod(validationBuildNo)
+	if err != nil {
+		return append(errs, field.InternalError(field.NewPath("spec"), fmt.Errorf("error generating runner pod: %s", err)))
+	}
+	errs = append(errs, validatePod(runner, objectPath("Pod", runner.Name))...)
+
+	configMap, err := ppl.PipelineRunConfigMap(validationBuildNo)
+	if err != nil {
+		return append(errs, field.InternalError(field.NewPath("spec"), fmt.Errorf("error generating stages config map: %s", err)))
+	}
+	errs = append(errs, validateConfigMap(configMap, objectPath("ConfigMap", configMap.Name))...)
+
+	objects := []runtime.Object{}
+	for _, generate := range []func() ([]runtime.Object, error){
+		func() ([]runtime.Object, error) { return ppl.TransitObjects(validationBuildNo) },
+		ppl.RunnerRBACObjects,
+		func() ([]runtime.Object, error) { return ppl.RunRBACObjects(validationBuildNo) },
+	} {
+		o, err := generate()
+		if err != nil {
+			return append(errs, field.InternalError(field.NewPath("spec"), fmt.Errorf("error generating objects: %s", err)))
+		}
+		objects = append(objects, o...)
+	}
+
+	for _, o := range objects {
+		accessor, err := meta.Accessor(o)
+		if err != nil {
+			return append(errs, field.InternalError(field.NewPath("spec"), err))
+		}
+		kind := o.GetObjectKind().GroupVersionKind().Kind
+		errs = append(errs, apivalidation.ValidateObjectMetaAccessor(accessor, false, apivalidation.NameIsDNSSubdomain, objectPath(kind, accessor.GetName()).Child("metadata"))...)
+	}
+
+	valLog.Info("validated validGeneratedObjects", "pipeline", ppl.Name)
+	return errs
+}
+
+// objectPath returns the root path of errors of the generated object
+func objectPath(kind, name string) *field.Path {
+	return field.NewPath(kind).Key(name)
+}
+
+func validateConfigMap(cm core.ConfigMap, cmPath *field.Path) field.ErrorList {
+	errs := apivalidation.ValidateObjectMeta(&cm.ObjectMeta, false, apivalidation.NameIsDNSSubdomain, cmPath.Child("metadata"))
+
+	size := 0
+	for key, value := range cm.Data {
+		for _, msg := range validation.IsConfigMapKey(key) {
+			errs = append(errs, field.Invalid(cmPath.Child("data").Key(key), key, msg))
+		}
+		size += len(value)
+	}
+	if size > core.MaxSecretSize {
+		errs = append(errs, field.TooLong(cmPath.Child("data"), "", core.MaxSecretSize))
+	}
+
+	return errs
+}
+
+// validatePod validates the metadata and the names, volumes and mounts of
+// the pod spec
+func validatePod(p core.Pod, podPath *field.Path) field.ErrorList {
+	errs := apivalidation.ValidateObjectMeta(&p.ObjectMeta, false, apivalidation.NameIsDNSSubdomain, podPath.Child("metadata"))
+	specPath := podPath.Child("spec")
+
+	volumes := map[string]bool{}
+	for _, v := range p.Spec.Volumes {
+		volumePath := specPath.Child("volumes").Key(v.Name)
+		for _, msg := range validation.IsDNS1123Label(v.Name) {
+			errs = append(errs, field.Invalid(volumePath.Child("name"), v.Name, msg))
+		}
+		if volumes[v.Name] {
+			errs = append(errs, field.Duplicate(volumePath.Child("name"), v.Name))
+		}
+		volumes[v.Name] = true
+	}
+
+	if len(p.Spec.Containers) == 0 {
+		errs = append(errs, field.Required(specPath.Child("containers"), ""))
+	}
+
+	containers := map[string]bool{}
+	for _, c := range p.Spec.InitContainers {
+		errs = append(errs, validateContainer(c, specPath.Child("initContainers").Key(c.Name), containers, volumes)...)
+	}
+	for _, c := range p.Spec.Containers {
+		errs = append(errs, validateContainer(c, specPath.Child("containers").Key(c.Name), containers, volumes)...)
+	}
+
+	return errs
+}
+
+// validateContainer validates container c -- names contains the names of
+// the containers that were validated before, volumes the volumes of the pod
+func validateContainer(c core.Container, containerPath *field.Path, names map[string]bool, volumes map[string]bool) field.ErrorList {
+	errs := field.ErrorList{}
+	for _, msg := range validation.IsDNS1123Label(c.Name) {
+		errs = append(errs, field.Invalid(containerPath.Child("name"), c.Name, msg))
+	}
+	if names[c.Name] {
+		errs = append(errs, field.Duplicate(containerPath.Child("name"), c.Name))
+	}
+	names[c.Name] = true
+
+	if c.Image == "" {
+		errs = append(errs, field.Required(containerPath.Child("image"), ""))
+	}
+
+	mountPaths := map[string]bool{}
+	for _, m := range c.VolumeMounts {
+		mountPath := containerPath.Child("volumeMounts").Key(m.MountPath)
+		if !volumes[m.Name] {
+			errs = append(errs, notFound(mountPath.Child("name"), m.Name, "there is no volume with this name"))
+		}
+		if m.MountPath == "" {
+			errs = append(errs, field.Required(mountPath.Child("mountPath"), ""))
+		}
+		if mountPaths[m.MountPath] {
+			errs = append(errs, field.Invalid(mountPath.Child("mountPath"), m.MountPath, "must be unique"))
+		}
+		mountPaths[m.MountPath] = true
+	}
+
+	for _, e := range c.Env {
+		for _, msg := range validation.IsEnvVarName(e.Name) {
+			errs = append(errs, field.Invalid(containerPath.Child("env").Key(e.Name).Child("name"), e.Name, msg))
+		}
+	}
+
+	for _, port := range c.Ports {
+		portPath := containerPath.Child("ports").Key(fmt.Sprintf("%d", port.ContainerPort))
+		for _, msg := range validation.IsValidPortNum(int(port.ContainerPort)) {
+			errs = append(errs, field.Invalid(portPath.Child("containerPort"), port.ContainerPort, msg))
+		}
+		if port.Name != "" {
+			for _, msg := range validation.IsValidPortName(port.Name) {
+				errs = append(errs, field.Invalid(portPath.Child("name"), port.Name, msg))
+			}
+		}
+	}
+
+	return errs
+}
@@ -66,7 +66,15 @@ func NewPipelineFromYaml(yamlData []byte) (Pipeline, error) {
 
 // RunnerPod creates the job that runs the pipeline
 func (ppl Pipeline) RunnerPod(buildNo int) (core.Pod, error) {
-	return core.Pod{
+	ppl.Status.BuildNo = buildNo
+	backend := ppl.transitBackend()
+
+	stages, err := ppl.generateStagePods(buildNo)
+	if err != nil {
+		return core.Pod{}, fmt.Errorf("error generating stage pods: %s", err)
+	}
+
+	pod := core.Pod{
 		ObjectMeta: metav1.ObjectMeta{
 			Labels: defaultLabels(ppl.Name, buildNo, ""),
 			Name:   fmt.Sprintf(nameFormatString, ppl.Name, buildNo),
@@ -78,38 +86,33 @@ func (ppl Pipeline) RunnerPod(buildNo int) (core.Pod, error) {
 
 		Spec: core.PodSpec{
 			RestartPolicy:      core.RestartPolicyNever,
-			ServiceAccountName: runnerServiceAccount,
-			Volumes: append(jindraVolumes([]string{"transit"}),
-				core.Volume{
-					Name: "stages", VolumeSource: core.VolumeSource{
-						ConfigMap: &core.ConfigMapVolumeSource{
-							LocalObjectReference: core.LocalObjectReference{
-								Name: fmt.Sprintf(configMapFormatString, ppl.Name, buildNo),
-							},
-						},
-					},
-				},
-				core.Volume{
-					Name: "rsync", VolumeSource: core.VolumeSource{
-						Secret: &core.SecretVolumeSource{
-							SecretName: fmt.Sprintf(rsyncSecretFormatString, ppl.Name, buildNo),
-							Items: []core.KeyToPath{
-								{Key: rsyncSecretPubKey, Path: "./authorized_keys"},
+			ServiceAccountName: ppl.runnerServiceAccountName(),
+			Volumes: append(
+				append(ppl.transitVolumes([]string{"transit"}),
+					core.Volume{
+						Name: "stages", VolumeSource: core.VolumeSource{
+							ConfigMap: &core.ConfigMapVolumeSource{
+								LocalObjectReference: core.LocalObjectReference{
+									Name: fmt.Sprintf(configMapFormatString, ppl.Name, buildNo),
+								},
 							},
 						},
 					},
-				},
+				),
+				backend.runnerVolumes()...,
 			),
-			Containers: []core.Container{
-				ppl.jindraRunnerContainer(buildNo),
+			Containers: append([]core.Container{
+				ppl.jindraRunnerContainer(buildNo, stages.secretNames()),
 				ppl.podWatcherContainer(),
-				ppl.rsyncServerContainer(),
-			},
+			}, backend.runnerContainers()...),
 			InitContainers: []core.Container{
 				ppl.semaphoreContainer(),
 			},
 		},
-	}, nil
+	}
+	ppl.hardenPod(&pod, []string{})
+
+	return pod, nil
 }
 
 // PipelineRunConfigMap creates the config map that the pipeline job uses
@@ -138,23 +141,45 @@ func (ppl Pipeline) PipelineRunConfigMap(buildNo int) (core.ConfigMap, error) {
 	}, nil
 }
 
-// NewRsyncSSHSecret creates a Kubernetes Secret with a public (key: pub) and
-// private ssh key (key: priv)
+// NewRsyncSSHSecret creates a Kubernetes Secret with the authorized keys of
+// the rsync server (key: pub) and the private ssh key (key: priv) -- or a
+// private key per stage using transit (key: priv.<stage>) if
+// transit.rsync.perStageKeys is set
 func (ppl Pipeline) NewRsyncSSHSecret(buildNo int) (core.Secret, error) {
-	privateKey, publicKey, err := generateSSHKeyPair()
-	if err != nil {
-		return core.Secret{}, fmt.Errorf("error creating keypair: %s", err)
+	data := map[string][]byte{}
+	authorizedKeys := []byte{}
+	addKey := func(keyName string, readOnly bool) error {
+		privateKey, publicKey, err := generateSSHKeyPair()
+		if err != nil {
+			return fmt.Errorf("error creating keypair: %s", err)
+		}
+
+		data[keyName] = privateKey
+		authorizedKeys = append(authorizedKeys, rsyncAuthorizedKey(publicKey, readOnly)...)
+		return nil
+	}
+
+	t := rsyncTransit{ppl}
+	if ppl.Spec.Transit.Rsync != nil && ppl.Spec.Transit.Rsync.PerStageKeys {
+		for _, stage := range ppl.allPods() {
+			if !usesTransit(resourceNames(stage)) {
+				continue
+			}
+			if err := addKey(t.privateKeyName(stage.Name), !usesTransit(outResourcesNames(stage))); err != nil {
+				return core.Secret{}, err
+			}
+		}
+	} else if err := addKey(rsyncSecretPrivateKey, false); err != nil {
+		return core.Secret{}, err
 	}
+	data[rsyncSecretPubKey] = authorizedKeys
 
 	return core.Secret{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Secret",
 			APIVersion: "v1",
 		},
-		Data: map[string][]byte{
-			rsyncSecretPrivateKey: privateKey,
-			rsyncSecretPubKey:     publicKey,
-		},
+		Data: data,
 		Type: core.SecretType("Opaque"),
 		ObjectMeta: metav1.ObjectMeta{
 			Name:   fmt.Sprintf(rsyncSecretFormatString, ppl.Name, buildNo),
@@ -163,6 +188,17 @@ func (ppl Pipeline) NewRsyncSSHSecret(buildNo int) (core.Secret, error) {
 	}, nil
 }
 
+// rsyncAuthorizedKey restricts publicKey to rsync access to the transit
+// directory
+func rsyncAuthorizedKey(publicKey []byte, readOnly bool) []byte {
+	command := rrsyncBin + " " + rsyncTransitPath
+	if readOnly {
+		command = rrsyncBin + " -ro " + rsyncTransitPath
+	}
+
+	return append([]byte(fmt.Sprintf(`command="%s",restrict `, command)), publicKey...)
+}
+
 func (ppl Pipeline) generateStagePods(buildNo int) (stagePods, error) {
 	config := stagePods{}
 	ppl.Status.BuildNo = buildNo
@@ -174,7 +210,9 @@ func (ppl Pipeline) generateStagePods(buildNo int) (stagePods, error) {
 		}
 	}
 
-	for i, stage := range pods {
+	for i := range pods {
+		// containers and annotations are modified -- the pipeline must not be
+		stage := *pods[i].DeepCopy()
 		if stage.Name == "" && stage.Annotations == nil {
 			continue
 		}
@@ -188,6 +226,18 @@ func (ppl Pipeline) generateStagePods(buildNo int) (stagePods, error) {
 		stage.SetName(name)
 
 		stage.Annotations[waitForAnnotationKey] = strings.Join(generateWaitForAnnotation(stage), ",")
+		userContainers := containerNames(stage)
+		for _, c := range stage.Spec.InitContainers {
+			userContainers = append(userContainers, c.Name)
+		}
+
+		cacheMounts := ppl.cacheVolumeMounts(stage)
+		for i := range stage.Spec.Containers {
+			stage.Spec.Containers[i].VolumeMounts = append(stage.Spec.Containers[i].VolumeMounts, cacheMounts...)
+		}
+		for i := range stage.Spec.InitContainers {
+			stage.Spec.InitContainers[i].VolumeMounts = append(stage.Spec.InitContainers[i].VolumeMounts, cacheMounts...)
+		}
 
 		stage.Spec.Containers = ppl.generateStageContainers(stage, stageName, strings.Join(generateWaitForAnnotation(stage), ","))
 		var err error
@@ -195,23 +245,19 @@ func (ppl Pipeline) generateStagePods(buildNo int) (stagePods, error) {
 			return stagePods{}, fmt.Errorf("error constructing init containers: %s", err)
 		}
 
+		stage.Spec.InitContainers = ppl.resolveContainers(stage.Spec.InitContainers)
+		stage.Spec.Containers = ppl.resolveContainers(stage.Spec.Containers)
+		ppl.hardenPod(&stage, userContainers)
+
 		stage.Spec.Affinity = &core.Affinity{NodeAffinity: &nodeAffinity}
+		if stage.Spec.ServiceAccountName == "" {
+			stage.Spec.ServiceAccountName = ppl.Spec.StageServiceAccountName
+		}
 
-		defaultMode := int32(256)
-		stage.Spec.Volumes = append(stage.Spec.Volumes,
-			append(jindraVolumes(resourceNames(stage)), core.Volume{
-				Name: "jindra-rsync-ssh-keys",
-				VolumeSource: core.VolumeSource{
-					Secret: &core.SecretVolumeSource{
-						SecretName:  fmt.Sprintf(rsyncSecretFormatString, ppl.Name, ppl.Status.BuildNo),
-						DefaultMode: &defaultMode,
-						Items: []core.KeyToPath{
-							core.KeyToPath{Key: rsyncSecretPrivateKey, Path: "./jindra"},
-						},
-					},
-				},
-			})...,
-		)
+		stage.Spec.Volumes = append(stage.Spec.Volumes, ppl.transitVolumes(resourceNames(stage))...)
+		stage.Spec.Volumes = append(stage.Spec.Volumes, ppl.transitBackend().stageVolumes(stage)...)
+		stage.Spec.Volumes = append(stage.Spec.Volumes, ppl.cacheVolumes(stage)...)
+		stage.Spec.Volumes = append(stage.Spec.Volumes, ppl.artifactVolumes(stage)...)
 
 		config[stageName+".yaml"] = stage
 	}
@@ -223,11 +269,15 @@ func (ppl Pipeline) generateStagePods(buildNo int) (stagePods, error) {
 // - init containers defined in the pipeline
 // - a container which copies jindra tools into a shared volume
 // - in resource containers
+// - cache restore containers
 func (ppl Pipeline) generateStageInitContainers(p core.Pod) ([]core.Container, error) {
 	createLocksSrc := []string{
-		"touch " + path.Join(semaphoresPrefixPath, "steps-running"),
+		"touch " + path.Join(semaphoresPrefixPath, StepsRunningSemaphore),
 		"touch " + path.Join(semaphoresPrefixPath, "outputs-running"),
 	}
+	if len(artifactPaths(p)) > 0 {
+		createLocksSrc = append(createLocksSrc, "touch "+path.Join(semaphoresPrefixPath, StepsFinishedSemaphore))
+	}
 	for _, name := range containerNames(p) {
 		createLocksSrc = append(createLocksSrc, "touch "+path.Join(semaphoresPrefixPath, "container-"+name))
 	}
@@ -249,8 +299,9 @@ func (ppl Pipeline) generateStageInitContainers(p core.Pod) ([]core.Container, e
 		debugArgs = append(debugArgs, "-wait-on-fail", "-debug-out=/tmp/jindra.debug")
 	}
 
-	for _, inName := range inResourcesNames(p) {
-		c, err := ppl.resourceContainer(inName)
+	for _, inName := range ppl.syncedResourceNames(inResourcesNames(p)) {
+		dir := resourceDir(inName)
+		c, err := ppl.resourceContainer(p, inName)
 		if err != nil {
 			// TODO: use logger
 			fmt.Fprintf(os.Stderr, "error creating init container: %s", err)
@@ -260,33 +311,37 @@ func (ppl Pipeline) generateStageInitContainers(p core.Pod) ([]core.Container, e
 			if c.Env == nil {
 				c.Env = []core.EnvVar{}
 			}
-			c.Env = append(c.Env, inResourceEnvs[inName]...)
+			c.Env = append(c.Env, renameEnvPrefix(inResourceEnvs[inName], inName, dir)...)
 		}
 		c.VolumeMounts = append(c.VolumeMounts, []core.VolumeMount{
-			{Name: resourceVolumePrefix + inName, MountPath: path.Join(resourcesPrefixPath, inName)},
+			{Name: resourceVolumePrefix + dir, MountPath: path.Join(resourcesPrefixPath, dir)},
 			toolsMount,
 		}...)
+		c, script := ppl.resourceScript(c, "in")
 		c.Name = inResourceContainerNamePrefix + c.Name
 		c.Args =
 			append(
 				append(
 					append([]string{
 						path.Join(toolsPrefixPath, "crij"),
-						"-env-prefix=" + inName,
+						"-env-prefix=" + dir,
 						"-semaphore-file=" + path.Join(semaphoresPrefixPath, "setting-up-pod"),
-						"-env-file=" + path.Join(resourcesPrefixPath, inName, resourceEnvFile),
+						"-env-file=" + path.Join(resourcesPrefixPath, dir, resourceEnvFile),
 						"-ignore-missing-env-file",
 						"-delete-env-file-after-read",
-						"-stderr-file=" + path.Join(resourcesPrefixPath, inName, inResourceStderrFile),
-						"-stdout-file=" + path.Join(resourcesPrefixPath, inName, inResourceStdoutFile),
-					}),
-					debugArgs...,
+						"-stderr-file=" + path.Join(resourcesPrefixPath, dir, inResourceStderrFile),
+						"-stdout-file=" + path.Join(resourcesPrefixPath, dir, inResourceStdoutFile),
+					}, debugArgs...),
+					append(ppl.resourceOptionArgs(inName, "in"), ppl.secretProvider().resourceArgs()...)...,
 				),
-				"/opt/resource/in",
-				path.Join(resourcesPrefixPath, inName))
+				append(script, path.Join(resourcesPrefixPath, dir))...)
 		initContainers = append(initContainers, c)
 	}
 
+	for _, cache := range ppl.stageCaches(p) {
+		initContainers = append(initContainers, ppl.cacheContainer(p, cache, "restore"))
+	}
+
 	podInitContainers := map[string]core.Container{}
 	for _, container := range p.Spec.InitContainers {
 		podInitContainers[container.Name] = container
@@ -319,6 +374,8 @@ func (ppl Pipeline) generateStageInitContainers(p core.Pod) ([]core.Container, e
 // generate container array which consists of:
 // - containers defined in the pipeline
 // - out resource containers
+// - cache save containers
+// - artifacts container if the stage has artifacts
 // - jindra watch container which deletes semaphores once other containers are finished
 // - debug container if debugging annotation was set
 func (ppl Pipeline) generateStageContainers(p core.Pod, stageName string, waitFor string) []core.Container {
@@ -343,8 +400,9 @@ func (ppl Pipeline) generateStageContainers(p core.Pod, stageName string, waitFo
 		outResourceEnvs = annotationToEnv(annotation)
 	}
 
-	for _, outName := range outResourcesNames(p) {
-		c, err := ppl.resourceContainer(outName)
+	for _, outName := range ppl.syncedResourceNames(outResourcesNames(p)) {
+		dir := resourceDir(outName)
+		c, err := ppl.resourceContainer(p, outName)
 		if err != nil {
 			// TODO: use logger
 			fmt.Fprintf(os.Stderr, "error creating init container: %s", err)
@@ -354,47 +412,55 @@ func (ppl Pipeline) generateStageContainers(p core.Pod, stageName string, waitFo
 			if c.Env == nil {
 				c.Env = []core.EnvVar{}
 			}
-			c.Env = append(c.Env, outResourceEnvs[outName]...)
+			c.Env = append(c.Env, renameEnvPrefix(outResourceEnvs[outName], outName, dir)...)
 		}
 		c.VolumeMounts = append(c.VolumeMounts, []core.VolumeMount{
-			{Name: resourceVolumePrefix + outName, MountPath: path.Join(resourcesPrefixPath, outName)},
+			{Name: resourceVolumePrefix + dir, MountPath: path.Join(resourcesPrefixPath, dir)},
 			toolsMount,
 			semaphoreMount,
 		}...)
+		c, script := ppl.resourceScript(c, "out")
 		c.Name = outResourceContainerNamePrefix + c.Name
 		c.Args =
 			append(
 				append(
 					append([]string{
 						path.Join(toolsPrefixPath, "crij"),
-						"-env-prefix=" + outName,
-						"-semaphore-file=" + path.Join(semaphoresPrefixPath, "steps-running"),
-						"-env-file=" + path.Join(resourcesPrefixPath, outName, resourceEnvFile),
+						"-env-prefix=" + dir,
+						"-semaphore-file=" + path.Join(semaphoresPrefixPath, StepsRunningSemaphore),
+						"-env-file=" + path.Join(resourcesPrefixPath, dir, resourceEnvFile),
 						"-ignore-missing-env-file",
 						"-delete-env-file-after-read",
-						"-stderr-file=" + path.Join(resourcesPrefixPath, outName, outResourceStderrFile),
-						"-stdout-file=" + path.Join(resourcesPrefixPath, outName, outResourceStdoutFile),
-					}),
-					debugArgs...,
+						"-stderr-file=" + path.Join(resourcesPrefixPath, dir, outResourceStderrFile),
+						"-stdout-file=" + path.Join(resourcesPrefixPath, dir, outResourceStdoutFile),
+					}, debugArgs...),
+					append(ppl.resourceOptionArgs(outName, "out"), ppl.secretProvider().resourceArgs()...)...,
 				),
-				"/opt/resource/out",
-				path.Join(resourcesPrefixPath, outName))
+				append(script, path.Join(resourcesPrefixPath, dir))...)
 
 		containers = append(containers, c)
 	}
 
+	for _, cache := range ppl.stageCaches(p) {
+		containers = append(containers, ppl.cacheContainer(p, cache, "save"))
+	}
+
+	if len(artifactPaths(p)) > 0 {
+		containers = append(containers, ppl.artifactsContainer(p, stageName))
+	}
+
 	return containers
 }
 
-func (ppl Pipeline) resourceContainer(name string) (core.Container, error) {
+func (ppl Pipeline) resourceContainer(p core.Pod, name string) (core.Container, error) {
 	for _, c := range ppl.Spec.Resources.Containers {
 		if c.Name == name {
-			return c, nil
+			return ppl.applyResourceType(c)
 		}
 	}
 
-	if name == "transit" {
-		return ppl.transitContainer(), nil
+	if c, ok := ppl.transitBackend().container(p, name); isTransit(name) && ok {
+		return c, nil
 	}
 
 	return core.Container{}, fmt.Errorf("there is no resource with name %s", name)
@@ -432,7 +498,7 @@ func jindraVolumes(resources []string) []core.Volume {
 	volumes := []core.Volume{}
 	emptyDirVolumes := []string{toolsMountName, sempahoresMountName}
 	for _, name := range resources {
-		emptyDirVolumes = append(emptyDirVolumes, resourceVolumePrefix+name)
+		emptyDirVolumes = append(emptyDirVolumes, resourceVolumePrefix+resourceDir(name))
 	}
 
 	for _, name := range emptyDirVolumes {
@@ -447,13 +513,31 @@ func jindraVolumes(resources []string) []core.Volume {
 	return volumes
 }
 
+// transitVolumes returns the jindra volumes with the transit volume provided
+// by the transit backend
+func (ppl Pipeline) transitVolumes(resources []string) []core.Volume {
+	volumes := jindraVolumes(resources)
+	for _, name := range resources {
+		if !isTransit(name) {
+			continue
+		}
+		for i := range volumes {
+			if volumes[i].Name == resourceVolumePrefix+resourceDir(name) {
+				volumes[i].VolumeSource = ppl.transitBackend().transitVolumeSource(name)
+			}
+		}
+	}
+
+	return volumes
+}
+
 func jindraVolumeMounts(c core.Container, resources []string) []core.VolumeMount {
 	mounts := []core.VolumeMount{}
 
 	for _, r := range resources {
 		mounts = append(mounts, core.VolumeMount{
-			Name:      resourceVolumePrefix + r,
-			MountPath: path.Join(resourcesPrefixPath, r),
+			Name:      resourceVolumePrefix + resourceDir(r),
+			MountPath: path.Join(resourcesPrefixPath, resourceDir(r)),
 		})
 	}
 
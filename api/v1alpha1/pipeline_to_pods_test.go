@@ -16,12 +16,22 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"crypto/ed25519"
 	"fmt"
 	"path"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"golang.org/x/crypto/ssh"
 	core "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kesselborn/jindra/secrets"
 )
 
 func TestBasicUnmarshalingTest(t *testing.T) {
@@ -85,6 +95,56 @@ func TestRsyncSSHSecret(t *testing.T) {
 	}
 }
 
+func TestRsyncPerStageKeys(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Spec.Transit.Rsync = &RsyncTransit{PerStageKeys: true}
+
+	secret, err := ppl.NewRsyncSSHSecret(42)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	keyNames := []string{}
+	for key := range secret.Data {
+		keyNames = append(keyNames, key)
+	}
+	sort.Strings(keyNames)
+	authorizedKeys := strings.Split(strings.TrimSpace(string(secret.Data[rsyncSecretPubKey])), "\n")
+	privateKey, privateKeyErr := ssh.ParseRawPrivateKey(secret.Data["priv.build-go-binary"])
+	_, isED25519 := privateKey.(*ed25519.PrivateKey)
+
+	configs, _ := ppl.generateStagePods(42)
+	in := configs["03-on-success.yaml"].Spec.InitContainers[1]
+	env := map[string]core.EnvVar{}
+	for _, e := range in.Env {
+		env[e.Name] = e
+	}
+	volumes := map[string]core.Volume{}
+	for _, v := range configs["05-final.yaml"].Spec.Volumes {
+		volumes[v.Name] = v
+	}
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{keyNames, []string{"priv.build-docker-image", "priv.build-go-binary", "priv.on-success", "pub"}, "stages using transit get their own key"},
+		{privateKeyErr, nil, "private key can be parsed"},
+		{isED25519, true, "private key is an ed25519 key"},
+		{len(authorizedKeys), 3, "every stage key is authorized"},
+		{strings.HasPrefix(authorizedKeys[0], `command="rrsync /jindra/transit",restrict ssh-ed25519 `), true, "keys of writing stages are restricted to rrsync"},
+		{strings.HasPrefix(authorizedKeys[2], `command="rrsync -ro /jindra/transit",restrict ssh-ed25519 `), true, "keys of reading stages are read only"},
+		{env["transit.source.private_key"].ValueFrom.SecretKeyRef.Key, "priv.on-success", "transit container uses the key of the stage"},
+		{volumes["jindra-rsync-ssh-keys"].Secret, (*core.SecretVolumeSource)(nil), "stages without transit get no key"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
+
 func TestStageConfigs(t *testing.T) {
 	configs, configsErr := getExamplePipeline(t).generateStagePods(42)
 
@@ -171,7 +231,7 @@ func TestDefaultModifier(t *testing.T) {
 	// remove all values that get modified by the modifying webhook
 	pplExpected.Annotations[buildNoOffsetAnnotationKey] = "0" // adjust to the default value that will get used
 	pplExpected.Spec.Final.Name = "foobar"                    // tests that given names are not overwritten
-	pplExpected.Spec.Resources.Triggers[0].Schedule = "/5 * * * *"
+	pplExpected.Spec.Resources.Triggers[0].Schedule = "*/5 * * * *"
 	pplExpected.Spec.Stages[0].Spec.RestartPolicy = core.RestartPolicyNever
 	pplExpected.Spec.Stages[1].Spec.RestartPolicy = core.RestartPolicyNever
 
@@ -229,3 +289,528 @@ func TestImagePullPolicyAlways(t *testing.T) {
 		t.Logf("\t%2d: %-80s %s", i, descr, ok())
 	}
 }
+
+// flagValues returns the values of the flags in args by name -- flags
+// without a value map to ""
+func flagValues(args []string) map[string]string {
+	flags := map[string]string{}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		tokens := strings.SplitN(arg, "=", 2)
+		flags[tokens[0]] = ""
+		if len(tokens) == 2 {
+			flags[tokens[0]] = tokens[1]
+		}
+	}
+
+	return flags
+}
+
+func TestResourceOptionArgs(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Annotations[resourceOptionsAnnotationKeyPrefix+"git"] = `
+          timeout=5m
+          retries=3
+    `
+	ppl.Annotations[resourceOptionsAnnotationKeyPrefix+"transit"] = "retry-backoff=10s"
+
+	configs, _ := ppl.generateStagePods(42)
+	stage := configs["01-build-go-binary.yaml"]
+	containers := map[string]core.Container{}
+	for _, c := range append(append([]core.Container{}, stage.Spec.InitContainers...), stage.Spec.Containers...) {
+		containers[c.Name] = c
+	}
+	in := flagValues(containers["jindra-resource-in-git"].Args)
+	out := flagValues(containers["jindra-resource-out-transit"].Args)
+	_, inCleans := in["-clean-dir-on-retry"]
+	_, outCleans := out["-clean-dir-on-retry"]
+	_, outTimeout := out["-timeout"]
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{[]string{in["-timeout"], in["-retries"]}, []string{"5m", "3"}, "in resource should get options"},
+		{inCleans, true, "in resource directory is cleaned before retries"},
+		{out["-retry-backoff"], "10s", "out resource should get options"},
+		{[]bool{outCleans, outTimeout}, []bool{false, false}, "out resource only gets its own options"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
+
+func TestNativeResource(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Spec.Resources.Containers[0].Image = "native:git"
+
+	configs, _ := ppl.generateStagePods(42)
+	c := configs["01-build-go-binary.yaml"].Spec.InitContainers[3]
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{c.Name, "jindra-resource-in-git", "native resource container name"},
+		{c.Image, toolsImage, "native resources run in the tools image"},
+		{c.Args[len(c.Args)-4:], []string{"/opt/jindra/bin/jindra-resource", "git", "in", "/jindra/resources/git"}, "crij calls the native resource"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
+
+func TestResourceType(t *testing.T) {
+	ppl := getExamplePipelineWithResourceTypes(t)
+
+	configs, _ := ppl.generateStagePods(42)
+	c := configs["01-build-go-binary.yaml"].Spec.InitContainers[3]
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{c.Name, "jindra-resource-in-git", "resource container name"},
+		{c.Image, "concourse/git-resource", "image is taken from the resource type"},
+		{c.Env[0].Name, "git.source.private_key", "template env gets prefixed with the resource name"},
+		{c.Env[0].ValueFrom.SecretKeyRef.Name, "deploy-key", "template env keeps its value source"},
+		{c.Env[1:], []core.EnvVar{
+			{Name: "git.source.uri", Value: "git@github.com:kesselborn/http-fs"},
+			{Name: "git.source.branch", Value: "develop"},
+			{Name: "HOME", Value: "/tmp"},
+		}, "resource env overrides template env"},
+		{ppl.Spec.Resources.Containers[0].Image, "type:github", "pipeline spec is not modified"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+
+	if err := ppl.ResolveResourceTypes(ResourceTypeCatalog{}); err == nil {
+		t.Fatalf("expected error when resolving unknown resource types")
+	}
+}
+
+func TestPVCTransit(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	size := resource.MustParse("5Gi")
+	ppl.Spec.Transit = Transit{Backend: "pvc", PVC: &PVCTransit{Size: &size}}
+
+	configs, _ := ppl.generateStagePods(42)
+	runner, _ := ppl.RunnerPod(42)
+	objects, _ := ppl.TransitObjects(42)
+	stage := configs["02-build-docker-image.yaml"]
+
+	volumes := map[string]core.Volume{}
+	for _, v := range stage.Spec.Volumes {
+		volumes[v.Name] = v
+	}
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{volumes["jindra-resource-transit"].PersistentVolumeClaim.ClaimName, "jindra.http-fs.42.transit", "transit volume is the claim of the run"},
+		{volumes["jindra-rsync-ssh-keys"].Secret, (*core.SecretVolumeSource)(nil), "no rsync keys needed"},
+		{containerNames(stage), []string{"jindra-debug-container", "build-docker-image", "jindra-watcher", "jindra-resource-out-registry-image"}, "no transit out container"},
+		{len(stage.Spec.InitContainers), 1, "no transit in container"},
+		{containerNames(runner), []string{"0-runner", "pod-watcher"}, "no rsync server in runner pod"},
+		{objects[0].(*core.PersistentVolumeClaim).Spec.Resources.Requests[core.ResourceStorage], size, "claim has configured size"},
+		{objects[0].(*core.PersistentVolumeClaim).Spec.AccessModes, []core.PersistentVolumeAccessMode{core.ReadWriteOnce}, "claim has default access mode"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
+
+func TestS3Transit(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Namespace = "ci"
+	ppl.Spec.Transit = Transit{Backend: "s3", S3: &S3Transit{Endpoint: "http://minio:9000", Bucket: "jindra", CredentialsSecret: "minio"}}
+
+	configs, _ := ppl.generateStagePods(42)
+	runner, _ := ppl.RunnerPod(42)
+	objects, _ := ppl.TransitObjects(42)
+	c := configs["01-build-go-binary.yaml"].Spec.Containers[2]
+	cleanup := runner.Spec.Containers[len(runner.Spec.Containers)-1]
+
+	env := map[string]core.EnvVar{}
+	for _, e := range c.Env {
+		env[e.Name] = e
+	}
+	cleanupEnv := map[string]core.EnvVar{}
+	for _, e := range cleanup.Env {
+		cleanupEnv[e.Name] = e
+	}
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{c.Name, "jindra-resource-out-transit", "transit out container"},
+		{c.Image, toolsImage, "transit uses the native s3 resource"},
+		{c.Args[len(c.Args)-4:], []string{"/opt/jindra/bin/jindra-resource", "s3", "out", "/jindra/resources/transit"}, "crij calls the native s3 resource"},
+		{env["transit.source.prefix"].Value, "ci/http-fs/42/transit", "objects are stored per run"},
+		{env["transit.source.secret_access_key"].ValueFrom.SecretKeyRef.Name, "minio", "credentials are read from secret"},
+		{containerNames(runner), []string{"0-runner", "pod-watcher", "jindra-transit-cleanup-transit"}, "no rsync server in runner pod"},
+		{len(objects), 0, "no objects need to be created"},
+		{flagValues(cleanup.Args)["-semaphore-file"], "/var/lock/jindra/stages-running", "objects are deleted once all stages ran"},
+		{cleanup.Args[len(cleanup.Args)-4:], []string{"/jindra/contrib/jindra-resource", "s3", "out", "/jindra/empty"}, "pushing an empty directory deletes the objects of the run"},
+		{cleanupEnv["transit.source.prefix"].Value, "ci/http-fs/42/transit", "only objects of the run are deleted"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
+
+func TestTransitChannels(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Spec.Stages[0].Annotations[outResourceAnnotationKey] = "transit,transit:binaries"
+	ppl.Spec.Stages[1].Annotations[inResourceAnnotationKey] = "transit:binaries"
+
+	configs, _ := ppl.generateStagePods(42)
+	out := configs["01-build-go-binary.yaml"].Spec.Containers[3]
+	in := configs["02-build-docker-image.yaml"].Spec.InitContainers[1]
+
+	ppl.Spec.Transit = Transit{Backend: "pvc"}
+	objects, _ := ppl.TransitObjects(42)
+	pvcConfigs, _ := ppl.generateStagePods(42)
+	volumes := map[string]core.Volume{}
+	for _, v := range pvcConfigs["02-build-docker-image.yaml"].Spec.Volumes {
+		volumes[v.Name] = v
+	}
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{ppl.transitChannels(), []string{"transit", "transit:binaries"}, "channels used in the pipeline"},
+		{out.Name, "jindra-resource-out-transit-binaries", "out container of the channel"},
+		{out.Env[3], core.EnvVar{Name: "transit-binaries.source.base_dir", Value: "/transit-binaries"}, "channel is synced into its own directory (relative to the rrsync directory)"},
+		{out.Args[1], "-env-prefix=transit-binaries", "crij uses channel env vars"},
+		{in.Name, "jindra-resource-in-transit-binaries", "in container of the channel"},
+		{in.VolumeMounts[0], core.VolumeMount{Name: "jindra-resource-transit-binaries", MountPath: "/jindra/resources/transit-binaries"}, "channel is mounted to its own directory"},
+		{len(objects), 2, "every channel gets its own claim"},
+		{volumes["jindra-resource-transit-binaries"].PersistentVolumeClaim.ClaimName, "jindra.http-fs.42.transit-binaries", "channel volume is the claim of the channel"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
+
+func TestVariables(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Spec.Variables = []Variable{{Name: "repository", Value: "kesselborntests"}, {Name: "goproxy", Value: "https://proxy.golang.org"}}
+	ppl.Spec.Secrets = []Secret{{Name: "registry-user", SecretKeyRef: &core.SecretKeySelector{LocalObjectReference: core.LocalObjectReference{Name: "registry"}, Key: "user"}}}
+	ppl.Spec.Resources.Containers[1].Env[2].Value = "((repository))/jindratest"
+	ppl.Spec.Stages[0].Spec.Containers[0].Env = []core.EnvVar{{Name: "GOPROXY", Value: "(( goproxy ))"}}
+	ppl.Spec.Stages[1].Annotations[outResourceEnvAnnotationKey] += "\nregistry-image.source.username=((registry-user))"
+
+	configs, _ := ppl.generateStagePods(42)
+	env := map[string]core.EnvVar{}
+	for _, c := range configs["02-build-docker-image.yaml"].Spec.Containers {
+		if c.Name == "jindra-resource-out-registry-image" {
+			for _, e := range c.Env {
+				env[e.Name] = e
+			}
+		}
+	}
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{env["registry-image.source.repository"].Value, "kesselborntests/jindratest", "variables are resolved in resource env"},
+		{env["registry-image.source.username"].ValueFrom.SecretKeyRef, ppl.Spec.Secrets[0].SecretKeyRef, "secrets are resolved in annotations"},
+		{configs["01-build-go-binary.yaml"].Spec.Containers[0].Env[0].Value, "https://proxy.golang.org", "variables are resolved in stage containers"},
+		{ppl.Spec.Stages[0].Spec.Containers[0].Env[0].Value, "(( goproxy ))", "pipeline is not changed"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
+
+func TestVaultSecrets(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Spec.SecretProvider = SecretProvider{Backend: "vault", Vault: &VaultProvider{Address: "https://vault:8200", Role: "jindra", KVVersion: 1}}
+	ppl.Spec.Secrets = []Secret{{Name: "registry-password", Vault: &VaultSecret{Path: "kv/ci/dockerhub", Key: "password"}}}
+	ppl.Spec.Resources.Containers[1].Env[4] = core.EnvVar{Name: "registry-image.source.password", Value: "((registry-password))"}
+
+	configs, _ := ppl.generateStagePods(42)
+	out := core.Container{}
+	for _, c := range configs["02-build-docker-image.yaml"].Spec.Containers {
+		if c.Name == "jindra-resource-out-registry-image" {
+			out = c
+		}
+	}
+	env := map[string]core.EnvVar{}
+	for _, e := range out.Env {
+		env[e.Name] = e
+	}
+	resolved, _ := secrets.Resolve(map[string]secrets.Provider{"vault": staticSecrets{"kv/ci/dockerhub#password": "s3cr3t"}}, env["registry-image.source.password"].Value)
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{env["registry-image.source.password"], core.EnvVar{Name: "registry-image.source.password", Value: "((vault:kv/ci/dockerhub#password))"}, "env var references the vault secret"},
+		{strings.Contains(strings.Join(out.Args, " "), "-vault-address=https://vault:8200 -vault-role=jindra -vault-kv-version=1 /opt/resource/out"), true, "crij reads the secret at run time"},
+		{resolved, "s3cr3t", "crij resolves the reference"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
+
+// staticSecrets is a secret provider with the values of '<path>#<key>'
+type staticSecrets map[string]string
+
+func (s staticSecrets) Get(path, key string) (string, error) {
+	return s[path+"#"+key], nil
+}
+
+func TestCaches(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	size := resource.MustParse("1Ki")
+	ppl.Spec.Caches = []Cache{{Name: "go-mod", Path: "/go/pkg/mod", KeyFiles: []string{"git/go.sum"}, MaxSize: &size}}
+	ppl.Spec.Stages[0].Annotations[cachesAnnotationKey] = "go-mod"
+
+	configs, _ := ppl.generateStagePods(42)
+	stage := configs["01-build-go-binary.yaml"]
+	restore := stage.Spec.InitContainers[4]
+	save := stage.Spec.Containers[len(stage.Spec.Containers)-1]
+
+	volumes := map[string]core.Volume{}
+	for _, v := range stage.Spec.Volumes {
+		volumes[v.Name] = v
+	}
+
+	ppl.Namespace = "ci"
+	ppl.Spec.CacheStore = ObjectStore{Backend: "s3", S3: &S3Transit{Endpoint: "http://minio:9000", Bucket: "jindra", CredentialsSecret: "minio"}}
+	s3Configs, _ := ppl.generateStagePods(42)
+	s3Restore := s3Configs["01-build-go-binary.yaml"].Spec.InitContainers[4]
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{initContainerNames(stage)[2:5], []string{"get-jindra-tools", "jindra-resource-in-git", "jindra-cache-restore-go-mod"}, "cache is restored after the in resources"},
+		{restore.Args, []string{"/opt/jindra/bin/jindra-cache", "-name=go-mod", "-dir=/jindra/caches/go-mod", "-state-file=/var/lock/jindra/cache-go-mod",
+			"-key-files=/jindra/resources/git/go.sum", "-max-size=1024", "-store-dir=/jindra/cache-store/http-fs/caches", "restore"}, "restore container arguments"},
+		{save.Name, "jindra-cache-save-go-mod", "cache is saved by the last container"},
+		{save.Args[2][:len("/opt/jindra/bin/wait-for-semaphore /var/lock/jindra/steps-running &&")], "/opt/jindra/bin/wait-for-semaphore /var/lock/jindra/steps-running &&", "cache is saved after the steps succeeded"},
+		{stage.Spec.Containers[0].VolumeMounts[len(stage.Spec.Containers[0].VolumeMounts)-1], core.VolumeMount{Name: "jindra-caches-go-mod", MountPath: "/go/pkg/mod"}, "cache is mounted into the steps"},
+		{volumes["jindra-cache-store"].PersistentVolumeClaim.ClaimName, "jindra-caches", "default claim of the store"},
+		{configs["02-build-docker-image.yaml"].Spec.InitContainers[len(configs["02-build-docker-image.yaml"].Spec.InitContainers)-1].Name, "jindra-resource-in-transit", "stages without caches are unchanged"},
+		{s3Restore.Args[len(s3Restore.Args)-5:], []string{"-s3-endpoint=http://minio:9000", "-s3-bucket=jindra", "-s3-region=", "-s3-prefix=ci/http-fs/caches", "restore"}, "s3 store arguments"},
+		{s3Restore.Env[1].ValueFrom.SecretKeyRef.Name, "minio", "s3 credentials are read from secret"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
+
+func TestArtifacts(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Spec.Artifacts = Artifacts{KeepRuns: 10, MaxAge: &metav1.Duration{Duration: 168 * time.Hour}}
+	ppl.Spec.Stages[0].Annotations[artifactsAnnotationKey] = "/jindra/resources/transit/http-fs-linux,/jindra/resources/git/reports"
+
+	configs, _ := ppl.generateStagePods(42)
+	stage := configs["01-build-go-binary.yaml"]
+	c := stage.Spec.Containers[len(stage.Spec.Containers)-1]
+	tools := stage.Spec.InitContainers[2]
+
+	volumes := map[string]core.Volume{}
+	for _, v := range stage.Spec.Volumes {
+		volumes[v.Name] = v
+	}
+
+	pvcStore, pvcDir := ppl.ArtifactStore()
+	s3 := ppl.DeepCopy()
+	s3.Namespace = "ci"
+	s3.Spec.Artifacts.Store = ObjectStore{Backend: s3ObjectStoreBackend, S3: &S3Transit{Endpoint: "http://minio:9000", Bucket: "jindra"}}
+	s3Store, s3Prefix := s3.ArtifactStore()
+	reader, readerDir, readerErr := ppl.ArtifactReaderPod()
+	_, _, s3ReaderErr := s3.ArtifactReaderPod()
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{c.Name, "jindra-artifacts", "artifacts container"},
+		{c.Args[2], "/opt/jindra/bin/wait-for-semaphore /var/lock/jindra/steps-finished; /opt/jindra/bin/jindra-artifacts -run=42 -stage=01-build-go-binary -dir=/jindra/resources " +
+			"-keep-runs=10 -max-age=168h0m0s -store-dir=/jindra/artifact-store/http-fs/artifacts upload transit/http-fs-linux git/reports", "artifacts are uploaded once the steps finished"},
+		{strings.Contains(tools.Command[2], "touch /var/lock/jindra/steps-finished"), true, "steps-finished semaphore is created"},
+		{volumes["jindra-artifact-store"].PersistentVolumeClaim.ClaimName, "jindra-artifacts", "default claim of the store"},
+		{containerNames(configs["02-build-docker-image.yaml"])[4], "jindra-resource-out-registry-image", "stages without artifacts have no artifacts container"},
+		{[]string{pvcStore.Backend, pvcStore.ClaimName, pvcDir}, []string{"pvc", "jindra-artifacts", "http-fs/artifacts"}, "artifacts are below the pipeline directory of the claim"},
+		{[]string{s3Store.S3.Bucket, s3Prefix}, []string{"jindra", "ci/http-fs/artifacts"}, "artifacts of s3 stores are below the namespace prefix"},
+		{[]interface{}{readerErr, readerDir, reader.Spec.Volumes[0].PersistentVolumeClaim.ClaimName, reader.Spec.Containers[0].VolumeMounts[0].ReadOnly},
+			[]interface{}{nil, "/jindra/artifact-store/http-fs/artifacts", "jindra-artifacts", true}, "reader pod mounts the claim of the artifacts read-only"},
+		{restrictedViolations(reader), []string{}, "reader pod passes the restricted pod security standard"},
+		{s3ReaderErr != nil, true, "s3 stores have no reader pod"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
+
+func TestPodSecurity(t *testing.T) {
+	ppl := getRestrictedExamplePipeline(t)
+	configs, _ := ppl.generateStagePods(42)
+	runner, _ := ppl.RunnerPod(42)
+
+	violations := map[string][]string{}
+	for name, pod := range configs {
+		if v := restrictedViolations(pod); len(v) > 0 {
+			violations[name] = v
+		}
+	}
+
+	watcher := configs["01-build-go-binary.yaml"].Spec.Containers[1]
+	user := configs["01-build-go-binary.yaml"].Spec.Containers[0]
+
+	// a user container of a compliant pod that disables seccomp by field
+	unconfined := *runner.DeepCopy()
+	unconfined.Spec.Containers[0].SecurityContext.SeccompProfile = &core.SeccompProfile{Type: core.SeccompProfileTypeUnconfined}
+	// pods that only use the seccomp annotations still comply
+	annotated := *runner.DeepCopy()
+	annotated.Spec.SecurityContext.SeccompProfile = nil
+	annotated.Annotations = map[string]string{seccompPodAnnotationKey: seccompRuntimeDefault}
+	for i := range annotated.Spec.Containers {
+		annotated.Spec.Containers[i].SecurityContext.SeccompProfile = nil
+	}
+
+	ppl.Annotations[podSecurityAnnotationKey] = podSecurityRelaxed
+	relaxedConfigs, _ := ppl.generateStagePods(42)
+	relaxed := relaxedConfigs["01-build-go-binary.yaml"]
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{violations, map[string][]string{}, "generated stage pods pass the restricted pod security standard"},
+		{restrictedViolations(runner), []string{}, "runner pod passes the restricted pod security standard"},
+		{watcher.Name, watcherContainerName, "watcher container"},
+		{*watcher.SecurityContext.ReadOnlyRootFilesystem, true, "watcher has a read-only root filesystem"},
+		{watcher.SecurityContext.SeccompProfile, &core.SeccompProfile{Type: core.SeccompProfileTypeRuntimeDefault}, "generated containers use the default seccomp profile"},
+		{runner.Spec.SecurityContext.SeccompProfile, &core.SeccompProfile{Type: core.SeccompProfileTypeRuntimeDefault}, "generated pods use the default seccomp profile"},
+		{configs["01-build-go-binary.yaml"].Spec.SecurityContext.SeccompProfile, (*core.SeccompProfile)(nil), "seccomp annotations of stages are kept"},
+		{len(restrictedViolations(unconfined)), 1, "unconfined seccomp profile fields are violations"},
+		{restrictedViolations(annotated), []string{}, "seccomp annotations are checked without fields"},
+		{user.SecurityContext, hardenedSecurityContext(false), "security contexts of user containers are kept"},
+		{relaxed.Spec.Containers[1].SecurityContext, (*core.SecurityContext)(nil), "relaxed pod security adds no security contexts"},
+		{relaxed.Spec.SecurityContext, (*core.PodSecurityContext)(nil), "relaxed pod security sets no fs group"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
+
+func TestStagePodsDoNotModifyPipeline(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	expected := ppl.DeepCopy()
+
+	first, _ := ppl.generateStagePods(42)
+	second, _ := ppl.generateStagePods(42)
+
+	if !reflect.DeepEqual(expected, &ppl) {
+		t.Fatalf("generating stage pods modified the pipeline: %s", errMsg(t, expected, &ppl))
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("generating stage pods twice has different results: %s", errMsg(t, first, second))
+	}
+}
+
+func TestRBAC(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Spec.StageServiceAccountName = "ci-builds"
+	ppl.Spec.Stages[1].Spec.ServiceAccountName = "docker-builds"
+
+	runnerObjects, runnerErr := ppl.RunnerRBACObjects()
+	runObjects, runErr := ppl.RunRBACObjects(42)
+	runner, _ := ppl.RunnerPod(42)
+	configs, _ := ppl.generateStagePods(42)
+
+	runnerRole := runnerObjects[1].(*rbac.Role)
+	runRole := runObjects[0].(*rbac.Role)
+	runBinding := runObjects[1].(*rbac.RoleBinding)
+
+	for i, test := range []struct {
+		got         interface{}
+		expectation interface{}
+		desc        string
+	}{
+		{runnerErr, nil, "runner rbac objects can be generated"},
+		{runErr, nil, "run rbac objects can be generated"},
+		{runnerObjects[0].(*core.ServiceAccount).Name, "jindra.http-fs.runner", "every pipeline has its own runner service account"},
+		{runner.Spec.ServiceAccountName, "jindra.http-fs.runner", "runner pod uses the service account of the pipeline"},
+		{runnerRole.Rules[0].ResourceNames, []string(nil), "pods can only be created, listed and watched without names"},
+		{runnerRole.Rules[1].ResourceNames, []string{"deploy-key", "dockerhub", "slack"}, "masked secrets can be read, transit keys belong to the run"},
+		{runRole.Name, "jindra.http-fs.42.runner", "every run has its own role"},
+		{runRole.Rules[0].ResourceNames, []string{
+			"jindra.http-fs.42",
+			"jindra.http-fs.42.01-build-go-binary",
+			"jindra.http-fs.42.02-build-docker-image",
+			"jindra.http-fs.42.03-on-success",
+			"jindra.http-fs.42.04-on-error",
+			"jindra.http-fs.42.05-final",
+		}, "runner can only access the pods of its run"},
+		{runRole.Rules[2].ResourceNames, []string{"jindra.http-fs.42.stages"}, "runner can only access the config map of its run"},
+		{runRole.Rules[4], rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"jindra.http-fs.42.rsync-keys"}, Verbs: []string{"get", "patch"}}, "runner can access the transit objects of its run"},
+		{runBinding.Subjects[0].Name, "jindra.http-fs.runner", "run role is bound to the runner service account"},
+		{configs["01-build-go-binary.yaml"].Spec.ServiceAccountName, "ci-builds", "stages use the stage service account of the pipeline"},
+		{configs["02-build-docker-image.yaml"].Spec.ServiceAccountName, "docker-builds", "stages can set their own service account"},
+	} {
+		if reflect.DeepEqual(test.expectation, test.got) {
+			t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+		} else {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expectation, test.got))
+		}
+	}
+}
@@ -17,11 +17,13 @@ package v1alpha1
 
 import (
 	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:storageversion
 
 // Pipeline is the Schema for the pipelines API
 type Pipeline struct {
@@ -30,6 +32,10 @@ type Pipeline struct {
 
 	Spec   PipelineSpec   `json:"spec,omitempty"`
 	Status PipelineStatus `json:"status,omitempty"`
+
+	// ResourceTypes holds the resource types referenced by the resources of this
+	// pipeline, keyed by the image reference. It is filled by ResolveResourceTypes.
+	ResourceTypes map[string]ResourceTypeSpec `json:"-"`
 }
 
 // +kubebuilder:object:root=true
@@ -47,6 +53,42 @@ type PipelineSpec struct {
 	// +optional
 	Resources Resources `json:"resources,omitempty"`
 
+	// Constants that are referenced as ((name)) in env vars of resources and
+	// stages and in the jindra.io/inputs-envs and jindra.io/outputs-envs
+	// annotations
+	// +optional
+	Variables []Variable `json:"variables,omitempty"`
+
+	// Secrets that are referenced as ((name)) like variables -- a secret must
+	// be the whole value of an env var
+	// +optional
+	Secrets []Secret `json:"secrets,omitempty"`
+
+	// Store the values of spec.secrets are read from (default: kubernetes)
+	// +optional
+	SecretProvider SecretProvider `json:"secretProvider,omitempty"`
+
+	// Storage backend that is used to pass the transit resource between stages
+	// +optional
+	Transit Transit `json:"transit,omitempty"`
+
+	// Caches that are kept between pipeline runs
+	// +optional
+	Caches []Cache `json:"caches,omitempty"`
+
+	// Store that keeps the cache entries (default claim: jindra-caches)
+	// +optional
+	CacheStore ObjectStore `json:"cacheStore,omitempty"`
+
+	// Store and retention of the artifacts of stages
+	// +optional
+	Artifacts Artifacts `json:"artifacts,omitempty"`
+
+	// Service account of the stage pods that don't set one (default: the
+	// default service account of the namespace) -- it is not created by jindra
+	// +optional
+	StageServiceAccountName string `json:"stageServiceAccountName,omitempty"`
+
 	// Definition of the stages of this pipeline. Each state is a pod definition
 	// +kubebuilder:validation:EmbeddedResource
 	Stages []core.Pod `json:"stages"`
@@ -78,6 +120,181 @@ type Resources struct {
 	Containers []core.Container `json:"containers,omitempty"`
 }
 
+// Variable is a constant of the pipeline
+type Variable struct {
+	Name string `json:"name"`
+
+	Value string `json:"value"`
+}
+
+// Secret is a key of a secret that is used by the pipeline -- secretKeyRef
+// must be set for the secret provider kubernetes, vault for vault
+type Secret struct {
+	Name string `json:"name"`
+
+	// +optional
+	SecretKeyRef *core.SecretKeySelector `json:"secretKeyRef,omitempty"`
+
+	// +optional
+	Vault *VaultSecret `json:"vault,omitempty"`
+}
+
+// VaultSecret is a key of a secret of the KV secrets engine of vault
+type VaultSecret struct {
+	// Path of the secret including the mount path of the secrets engine,
+	// e.g. secret/ci/dockerhub
+	Path string `json:"path"`
+
+	Key string `json:"key"`
+}
+
+// SecretProvider configures the store the values of secrets are read from
+// +kubebuilder:validation:Optional
+type SecretProvider struct {
+	// Backend that stores the secrets: kubernetes (default, secrets of the
+	// namespace of the pipeline) or vault (HashiCorp Vault, read by the
+	// resource containers at run time -- vault secrets can only be used in env
+	// vars of resources)
+	// +kubebuilder:validation:Enum=kubernetes;vault
+	Backend string `json:"backend,omitempty"`
+
+	// +optional
+	Vault *VaultProvider `json:"vault,omitempty"`
+}
+
+// VaultProvider configures the access to vault -- resource containers log in
+// with the kubernetes auth method using the service account of the stage pod
+type VaultProvider struct {
+	// Address of the vault server, e.g. https://vault.example.com:8200
+	Address string `json:"address"`
+
+	// Role of the kubernetes auth method
+	Role string `json:"role"`
+
+	// Mount path of the kubernetes auth method (default: kubernetes)
+	// +optional
+	AuthPath string `json:"authPath,omitempty"`
+
+	// Version of the KV secrets engine (default: 2)
+	// +kubebuilder:validation:Enum=1;2
+	// +optional
+	KVVersion int `json:"kvVersion,omitempty"`
+}
+
+// Transit configures the storage backend of the transit resource
+// +kubebuilder:validation:Optional
+type Transit struct {
+	// Backend that stores the transit directory: rsync (default, the runner pod
+	// serves the data via ssh), pvc (a persistent volume claim per run) or s3
+	// (s3 compatible object storage)
+	// +kubebuilder:validation:Enum=rsync;pvc;s3
+	Backend string `json:"backend,omitempty"`
+
+	// +optional
+	Rsync *RsyncTransit `json:"rsync,omitempty"`
+
+	// +optional
+	PVC *PVCTransit `json:"pvc,omitempty"`
+
+	// +optional
+	S3 *S3Transit `json:"s3,omitempty"`
+}
+
+// RsyncTransit configures the ssh server of the runner pod
+type RsyncTransit struct {
+	// Issue a key per stage instead of a key per run -- keys of stages that
+	// don't write to a transit channel only have read access
+	// +optional
+	PerStageKeys bool `json:"perStageKeys,omitempty"`
+}
+
+// PVCTransit configures the persistent volume claim that is created for
+// every pipeline run
+type PVCTransit struct {
+	// Storage class of the claim (default: the cluster's default storage class)
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// Size of the claim (default: 1Gi)
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// Access modes of the claim (default: ReadWriteOnce)
+	// +optional
+	AccessModes []core.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+}
+
+// S3Transit configures the s3 compatible object storage the transit
+// directory is stored in
+type S3Transit struct {
+	// Url of the object storage, e.g. https://s3.amazonaws.com or http://minio:9000
+	Endpoint string `json:"endpoint"`
+
+	Bucket string `json:"bucket"`
+
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Name of the secret that contains the keys 'access_key_id' and
+	// 'secret_access_key'
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// Cache is a directory that is restored before the containers of a stage
+// run and saved after they finished successfully
+type Cache struct {
+	// Name of the cache -- stages use caches via the annotation jindra.io/caches
+	Name string `json:"name"`
+
+	// Path the cache directory is mounted to in the containers of the stage
+	Path string `json:"path"`
+
+	// Files whose content makes up the key of the cache entry, relative to
+	// /jindra/resources (e.g. git/go.sum) -- if empty, the cache has a single
+	// entry that is saved after every run
+	// +optional
+	KeyFiles []string `json:"keyFiles,omitempty"`
+
+	// The least recently used entries are evicted once all entries of the
+	// cache exceed this size (default: no eviction)
+	// +optional
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+}
+
+// ObjectStore configures where data that outlives a pipeline run (cache
+// entries, artifacts) is stored
+// +kubebuilder:validation:Optional
+type ObjectStore struct {
+	// Backend that stores the data: pvc (default, an existing persistent volume
+	// claim) or s3 (s3 compatible object storage)
+	// +kubebuilder:validation:Enum=pvc;s3
+	Backend string `json:"backend,omitempty"`
+
+	// Name of the persistent volume claim of the pvc backend
+	// +optional
+	ClaimName string `json:"claimName,omitempty"`
+
+	// Object storage of the s3 backend
+	// +optional
+	S3 *S3Transit `json:"s3,omitempty"`
+}
+
+// Artifacts configures where the artifacts of stages (annotation
+// jindra.io/artifacts) are stored and how long they are kept
+type Artifacts struct {
+	// Store that keeps the artifacts (default claim: jindra-artifacts)
+	// +optional
+	Store ObjectStore `json:"store,omitempty"`
+
+	// Number of most recent runs whose artifacts are kept (default: all)
+	// +optional
+	KeepRuns int `json:"keepRuns,omitempty"`
+
+	// Artifacts of runs older than this are deleted, e.g. 168h (default: never)
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
 // Trigger defines a pipeline trigger and the cron schedule when the checks
 // for new versions should be done
 type Trigger struct {
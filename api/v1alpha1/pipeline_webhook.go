@@ -16,18 +16,45 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
-	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
+const validatingWebhookPath = "/validate-ci-jindra-io-v1alpha1-pipeline"
+
 // log is for logging in this package.
 var webhLog = logf.Log.WithName("pipeline-webook")
 
+// resourceTypeGetter is used by the validator to resolve resource types
+var resourceTypeGetter ResourceTypeGetter
+
+// runLister is used by the update validator to look up the active runs
+var runLister RunLister
+
 // SetupWebhookWithManager registeres this webhook with a manager
 func (ppl *Pipeline) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	webhLog.Info("Setting up Webhook")
+	resourceTypeGetter = ClientResourceTypeGetter{Reader: mgr.GetClient()}
+	runLister = ClientRunLister{mgr.GetClient()}
+
+	// registered before the builder registers its validating webhook, which
+	// only reports the message of validation errors
+	server := mgr.GetWebhookServer()
+	if !isHandled(server, validatingWebhookPath) {
+		server.Register(validatingWebhookPath, &webhook.Admission{Handler: &validatingHandler{}})
+	}
+
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(ppl).
 		Complete()
@@ -46,7 +73,6 @@ func (ppl *Pipeline) Default() {
 	ppl.SetDefaults()
 }
 
-// TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
 // +kubebuilder:webhook:verbs=create;update,path=/validate-ci-jindra-io-v1alpha1-pipeline,mutating=false,failurePolicy=fail,groups=ci.jindra.io,resources=pipelines,versions=v1alpha1,name=validator.jindra.io
 
 var _ webhook.Validator = &Pipeline{}
@@ -54,20 +80,137 @@ var _ webhook.Validator = &Pipeline{}
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (ppl *Pipeline) ValidateCreate() error {
 	webhLog.Info("calling create validator for ", "pipeline", ppl.Name)
+	if err := ppl.resolveResourceTypes(); err != nil {
+		return err
+	}
 	return ppl.Validate()
 }
 
-// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+// ValidateUpdate implements webhook.Validator so a webhook will be registered
+// for the type -- updates that don't change spec or annotations (e.g. of the
+// finalizers or of pipelines that are being deleted) are not validated, so
+// the controller can finalize pipelines that became invalid
 func (ppl *Pipeline) ValidateUpdate(old runtime.Object) error {
 	webhLog.Info("calling update validator for ", "pipeline", ppl.Name)
-	return ppl.Validate()
+	oldPpl, ok := old.(*Pipeline)
+	if !ok {
+		return fmt.Errorf("expected a Pipeline but got a %T", old)
+	}
+
+	if ppl.DeletionTimestamp != nil || (equality.Semantic.DeepEqual(ppl.Spec, oldPpl.Spec) && equality.Semantic.DeepEqual(ppl.Annotations, oldPpl.Annotations)) {
+		return nil
+	}
+
+	if err := ppl.resolveResourceTypes(); err != nil {
+		return err
+	}
+
+	activeRuns := []int{}
+	if runLister != nil {
+		var err error
+		if activeRuns, err = runLister.ActiveRuns(*ppl); err != nil {
+			return err
+		}
+	}
+
+	errs := append(ppl.UpdateErrors(*oldPpl, activeRuns), ppl.ValidationErrors()...)
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("Pipeline").GroupKind(), ppl.Name, errs)
+	}
+
+	return nil
 }
 
-// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
-func (ppl *Pipeline) ValidateDelete() error {
-	// webhLog.Info("calling delete validator for ", "pipeline", ppl.Name)
-	webhLog.Info("delete validator not implemented", "pipeline", ppl.Name)
+// isHandled returns whether the webhook server already has a handler for path
+func isHandled(server *webhook.Server, path string) bool {
+	if server.WebhookMux == nil {
+		return false
+	}
+	h, p := server.WebhookMux.Handler(&http.Request{URL: &url.URL{Path: path}})
+	return h != nil && p == path
+}
 
-	// TODO(user): fill in your validation logic upon object deletion.
+// validatingHandler is a validating webhook that returns validation errors
+// as status with reason Invalid, so that clients can show all validation
+// errors with their field paths -- validation warnings of created or changed
+// pipelines are returned as admission warnings
+type validatingHandler struct {
+	decoder *admission.Decoder
+}
+
+// InjectDecoder injects the decoder into the validatingHandler
+func (h *validatingHandler) InjectDecoder(d *admission.Decoder) error {
+	h.decoder = d
+	return nil
+}
+
+// Handle validates pipelines on create and update
+func (h *validatingHandler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var err error
+	var warnings []string
+	ppl := &Pipeline{}
+
+	switch req.Operation {
+	case admissionv1.Create:
+		if err := h.decoder.Decode(req, ppl); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = ppl.ValidateCreate()
+		warnings = ppl.ValidationWarnings()
+	case admissionv1.Update:
+		old := &Pipeline{}
+		if err := h.decoder.DecodeRaw(req.Object, ppl); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := h.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = ppl.ValidateUpdate(old)
+		if !equality.Semantic.DeepEqual(ppl.Spec, old.Spec) {
+			warnings = ppl.ValidationWarnings()
+		}
+	case admissionv1.Delete:
+		if err := h.decoder.DecodeRaw(req.OldObject, ppl); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		err = ppl.ValidateDelete()
+	}
+
+	resp := validationResponse(err)
+	if len(warnings) > 0 {
+		resp.Warnings = warnings
+	}
+
+	return resp
+}
+
+// validationResponse returns the admission response for the validation
+// error err -- api status errors are returned as they are
+func validationResponse(err error) admission.Response {
+	if err == nil {
+		return admission.Allowed("")
+	}
+
+	if statusErr, ok := err.(apierrors.APIStatus); ok {
+		status := statusErr.Status()
+		return admission.Response{AdmissionResponse: admissionv1.AdmissionResponse{Allowed: false, Result: &status}}
+	}
+
+	return admission.Denied(err.Error())
+}
+
+func (ppl *Pipeline) resolveResourceTypes() error {
+	if resourceTypeGetter == nil {
+		return nil
+	}
+
+	return ppl.ResolveResourceTypes(resourceTypeGetter)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered
+// for the type -- pipelines can always be deleted: the controller's finalizer
+// cancels their active runs before they are gone
+func (ppl *Pipeline) ValidateDelete() error {
+	webhLog.Info("calling delete validator for ", "pipeline", ppl.Name)
 	return nil
 }
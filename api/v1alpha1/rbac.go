@@ -0,0 +1,157 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// runnerServiceAccountName returns the name of the service account of the
+// runner pods of the pipeline
+func (ppl Pipeline) runnerServiceAccountName() string {
+	return fmt.Sprintf(runnerRBACFormatString, ppl.Name)
+}
+
+// runRoleName returns the name of the role and role binding of a run
+func (ppl Pipeline) runRoleName(buildNo int) string {
+	return fmt.Sprintf(runRBACFormatString, ppl.Name, buildNo)
+}
+
+// RunnerRBACObjects returns the service account of the runner pods of the
+// pipeline and the role (and its binding) with the permissions that can't be
+// restricted to the objects of a run: creating and watching pods and reading
+// the secrets whose values are masked in the logs
+func (ppl Pipeline) RunnerRBACObjects() ([]runtime.Object, error) {
+	stages, err := ppl.generateStagePods(ppl.Status.BuildNo)
+	if err != nil {
+		return nil, fmt.Errorf("error generating stage pods: %s", err)
+	}
+
+	name := ppl.runnerServiceAccountName()
+	meta := metav1.ObjectMeta{
+		Name:   name,
+		Labels: map[string]string{pipelineLabelKey: ppl.Name},
+	}
+
+	rules := []rbac.PolicyRule{
+		// kubernetes can't restrict creating, listing and watching objects to names
+		{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"create", "list", "watch"}},
+	}
+	// secrets of a run (transit keys) are part of the role of the run
+	runObjects := arrayToSet(ppl.transitBackend().objectNames())
+	secrets := []string{}
+	for _, secret := range stages.secretNames() {
+		if !runObjects["secret/"+secret] {
+			secrets = append(secrets, secret)
+		}
+	}
+	if len(secrets) > 0 {
+		rules = append(rules, rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: secrets, Verbs: []string{"get"}})
+	}
+
+	return []runtime.Object{
+		&core.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{Kind: "ServiceAccount", APIVersion: "v1"},
+			ObjectMeta: meta,
+		},
+		&rbac.Role{
+			TypeMeta:   metav1.TypeMeta{Kind: "Role", APIVersion: rbac.SchemeGroupVersion.String()},
+			ObjectMeta: meta,
+			Rules:      rules,
+		},
+		ppl.runnerRoleBinding(meta),
+	}, nil
+}
+
+// RunRBACObjects returns the role (and its binding) that allows the runner
+// service account to access the objects of run buildNo only: its pods, their
+// logs, the stages config map and the objects of the transit backend (the
+// runner masks the values of the rsync keys in the logs)
+func (ppl Pipeline) RunRBACObjects(buildNo int) ([]runtime.Object, error) {
+	ppl.Status.BuildNo = buildNo
+	stages, err := ppl.generateStagePods(buildNo)
+	if err != nil {
+		return nil, fmt.Errorf("error generating stage pods: %s", err)
+	}
+
+	runnerName := fmt.Sprintf(nameFormatString, ppl.Name, buildNo)
+	stagePodNames := []string{}
+	for key := range stages {
+		stagePodNames = append(stagePodNames, runnerName+"."+strings.TrimSuffix(key, ".yaml"))
+	}
+	sort.Strings(stagePodNames)
+
+	name := ppl.runRoleName(buildNo)
+	rules := []rbac.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: append([]string{runnerName}, stagePodNames...), Verbs: []string{"get", "patch", "delete"}},
+		{APIGroups: []string{""}, Resources: []string{"pods/log"}, ResourceNames: stagePodNames, Verbs: []string{"get"}},
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{fmt.Sprintf(configMapFormatString, ppl.Name, buildNo)}, Verbs: []string{"get", "patch"}},
+		// the runner makes its run own the role and role binding
+		{APIGroups: []string{rbac.GroupName}, Resources: []string{"roles", "rolebindings"}, ResourceNames: []string{name}, Verbs: []string{"patch"}},
+	}
+
+	transitObjects := map[string][]string{}
+	resources := []string{}
+	for _, object := range ppl.transitBackend().objectNames() {
+		tokens := strings.SplitN(object, "/", 2)
+		resource := tokens[0] + "s"
+		if _, ok := transitObjects[resource]; !ok {
+			resources = append(resources, resource)
+		}
+		transitObjects[resource] = append(transitObjects[resource], tokens[1])
+	}
+	for _, resource := range resources {
+		rules = append(rules, rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{resource}, ResourceNames: transitObjects[resource], Verbs: []string{"get", "patch"}})
+	}
+
+	meta := metav1.ObjectMeta{
+		Name:   name,
+		Labels: defaultLabels(ppl.Name, buildNo, ""),
+	}
+
+	return []runtime.Object{
+		&rbac.Role{
+			TypeMeta:   metav1.TypeMeta{Kind: "Role", APIVersion: rbac.SchemeGroupVersion.String()},
+			ObjectMeta: meta,
+			Rules:      rules,
+		},
+		ppl.runnerRoleBinding(meta),
+	}, nil
+}
+
+// runnerRoleBinding binds the role of meta to the runner service account
+func (ppl Pipeline) runnerRoleBinding(meta metav1.ObjectMeta) *rbac.RoleBinding {
+	return &rbac.RoleBinding{
+		TypeMeta:   metav1.TypeMeta{Kind: "RoleBinding", APIVersion: rbac.SchemeGroupVersion.String()},
+		ObjectMeta: meta,
+		RoleRef:    rbac.RoleRef{APIGroup: rbac.GroupName, Kind: "Role", Name: meta.Name},
+		Subjects:   []rbac.Subject{{Kind: rbac.ServiceAccountKind, Name: ppl.runnerServiceAccountName()}},
+	}
+}
+
+// runRBACObjectNames returns '<resource>/<name>' of the rbac objects of the
+// run
+func (ppl Pipeline) runRBACObjectNames() []string {
+	name := ppl.runRoleName(ppl.Status.BuildNo)
+	return []string{"role/" + name, "rolebinding/" + name}
+}
@@ -0,0 +1,221 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResourceTypeGetter looks up the resource types referenced by pipelines
+// +kubebuilder:object:generate=false
+type ResourceTypeGetter interface {
+	ResourceType(namespace, name string) (ResourceTypeSpec, error)
+	ClusterResourceType(name string) (ResourceTypeSpec, error)
+}
+
+// ResourceTypeCatalog is a ResourceTypeGetter for resource types that are not
+// read from a cluster (e.g. from a file). Namespaces are ignored.
+// +kubebuilder:object:generate=false
+type ResourceTypeCatalog struct {
+	ResourceTypes        map[string]ResourceTypeSpec
+	ClusterResourceTypes map[string]ResourceTypeSpec
+}
+
+// NewResourceTypeCatalogFromYaml creates a catalog from yaml source code
+// containing ResourceType and ClusterResourceType documents
+func NewResourceTypeCatalogFromYaml(yamlData []byte) (ResourceTypeCatalog, error) {
+	catalog := ResourceTypeCatalog{
+		ResourceTypes:        map[string]ResourceTypeSpec{},
+		ClusterResourceTypes: map[string]ResourceTypeSpec{},
+	}
+
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(yamlData), 4096)
+	for {
+		var doc struct {
+			metav1.TypeMeta   `json:",inline"`
+			metav1.ObjectMeta `json:"metadata,omitempty"`
+			Spec              ResourceTypeSpec `json:"spec,omitempty"`
+		}
+
+		if err := decoder.Decode(&doc); err == io.EOF {
+			break
+		} else if err != nil {
+			return catalog, fmt.Errorf("cannot decode resource type: %s", err)
+		}
+
+		switch doc.Kind {
+		case "":
+			continue
+		case "ResourceType":
+			catalog.ResourceTypes[doc.Name] = doc.Spec
+		case "ClusterResourceType":
+			catalog.ClusterResourceTypes[doc.Name] = doc.Spec
+		default:
+			return catalog, fmt.Errorf("unexpected kind '%s' (must be ResourceType or ClusterResourceType)", doc.Kind)
+		}
+	}
+
+	return catalog, nil
+}
+
+// ResourceType implements ResourceTypeGetter
+func (catalog ResourceTypeCatalog) ResourceType(namespace, name string) (ResourceTypeSpec, error) {
+	spec, ok := catalog.ResourceTypes[name]
+	if !ok {
+		return ResourceTypeSpec{}, fmt.Errorf("resource type '%s' not found", name)
+	}
+
+	return spec, nil
+}
+
+// ClusterResourceType implements ResourceTypeGetter
+func (catalog ResourceTypeCatalog) ClusterResourceType(name string) (ResourceTypeSpec, error) {
+	spec, ok := catalog.ClusterResourceTypes[name]
+	if !ok {
+		return ResourceTypeSpec{}, fmt.Errorf("cluster resource type '%s' not found", name)
+	}
+
+	return spec, nil
+}
+
+// ClientResourceTypeGetter is a ResourceTypeGetter that reads resource types
+// from the cluster
+// +kubebuilder:object:generate=false
+type ClientResourceTypeGetter struct {
+	client.Reader
+}
+
+// ResourceType implements ResourceTypeGetter
+func (g ClientResourceTypeGetter) ResourceType(namespace, name string) (ResourceTypeSpec, error) {
+	var rt ResourceType
+	if err := g.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: name}, &rt); err != nil {
+		return ResourceTypeSpec{}, err
+	}
+
+	return rt.Spec, nil
+}
+
+// ClusterResourceType implements ResourceTypeGetter
+func (g ClientResourceTypeGetter) ClusterResourceType(name string) (ResourceTypeSpec, error) {
+	var crt ClusterResourceType
+	if err := g.Get(context.Background(), types.NamespacedName{Name: name}, &crt); err != nil {
+		return ResourceTypeSpec{}, err
+	}
+
+	return crt.Spec, nil
+}
+
+// resourceTypeReference returns kind and name of the resource type the
+// resource container c references (image 'type:<name>' or
+// 'clustertype:<name>') or empty strings if c does not reference one
+func resourceTypeReference(c core.Container) (string, string) {
+	switch {
+	case strings.HasPrefix(c.Image, resourceTypeImagePrefix):
+		return "ResourceType", strings.TrimPrefix(c.Image, resourceTypeImagePrefix)
+	case strings.HasPrefix(c.Image, clusterResourceTypeImagePrefix):
+		return "ClusterResourceType", strings.TrimPrefix(c.Image, clusterResourceTypeImagePrefix)
+	}
+
+	return "", ""
+}
+
+// ResolveResourceTypes looks up all resource types referenced by the resources
+// of the pipeline, so they can be used when generating the stage pods
+func (ppl *Pipeline) ResolveResourceTypes(getter ResourceTypeGetter) error {
+	for _, c := range ppl.Spec.Resources.Containers {
+		var spec ResourceTypeSpec
+		var err error
+
+		kind, name := resourceTypeReference(c)
+		switch kind {
+		case "":
+			continue
+		case "ResourceType":
+			spec, err = getter.ResourceType(ppl.Namespace, name)
+		case "ClusterResourceType":
+			spec, err = getter.ClusterResourceType(name)
+		}
+
+		if err != nil {
+			return fmt.Errorf("error resolving %s '%s' of resource '%s': %s", kind, name, c.Name, err)
+		}
+
+		if ppl.ResourceTypes == nil {
+			ppl.ResourceTypes = map[string]ResourceTypeSpec{}
+		}
+		ppl.ResourceTypes[c.Image] = spec
+	}
+
+	return nil
+}
+
+// applyResourceType merges the resource container c into the template of the
+// resource type it references: the template's env gets prefixed with the
+// resource name and env vars of c override env vars of the template
+func (ppl Pipeline) applyResourceType(c core.Container) (core.Container, error) {
+	if kind, _ := resourceTypeReference(c); kind == "" {
+		return c, nil
+	}
+
+	spec, ok := ppl.ResourceTypes[c.Image]
+	if !ok {
+		return c, fmt.Errorf("resource type '%s' of resource '%s' has not been resolved", c.Image, c.Name)
+	}
+
+	overrides := map[string]bool{}
+	for _, e := range c.Env {
+		overrides[e.Name] = true
+	}
+
+	merged := *spec.Template.DeepCopy()
+	merged.Name = c.Name
+
+	env := []core.EnvVar{}
+	for _, e := range merged.Env {
+		e.Name = c.Name + "." + e.Name
+		if !overrides[e.Name] {
+			env = append(env, e)
+		}
+	}
+	merged.Env = append(env, c.Env...)
+
+	return merged, nil
+}
+
+// resourceContainers returns the resource containers of the pipeline with
+// their resource types applied
+func (ppl Pipeline) resourceContainers() ([]core.Container, error) {
+	containers := []core.Container{}
+	for _, c := range ppl.Spec.Resources.Containers {
+		merged, err := ppl.applyResourceType(c)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, merged)
+	}
+
+	return containers, nil
+}
@@ -0,0 +1,80 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:object:root=true
+
+// ResourceType is a reusable resource definition that can be referenced by
+// pipelines of the same namespace via the image 'type:<name>'
+type ResourceType struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ResourceTypeSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ResourceTypeList contains a list of ResourceType
+type ResourceTypeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ResourceType `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+
+// ClusterResourceType is a reusable resource definition that can be referenced
+// by pipelines of all namespaces via the image 'clustertype:<name>'
+type ClusterResourceType struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ResourceTypeSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterResourceTypeList contains a list of ClusterResourceType
+type ClusterResourceTypeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterResourceType `json:"items"`
+}
+
+// ResourceTypeSpec defines the container a resource of this type is based on
+type ResourceTypeSpec struct {
+	// Container template for resources of this type. Env var names are relative
+	// to the resource (e.g. 'source.uri') and get prefixed with the name of the
+	// referencing resource. The name of the template is ignored.
+	Template core.Container `json:"template"`
+
+	// Parameters (e.g. 'source.uri') that need to be set by the template, the
+	// referencing resource or the inputs-envs / outputs-envs annotation of each
+	// stage that uses the resource
+	// +optional
+	RequiredParams []string `json:"requiredParams,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ResourceType{}, &ResourceTypeList{}, &ClusterResourceType{}, &ClusterResourceTypeList{})
+}
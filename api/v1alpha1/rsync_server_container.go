@@ -16,12 +16,19 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
 	"path"
+	"strings"
 
 	core "k8s.io/api/core/v1"
 )
 
 func (ppl Pipeline) rsyncServerContainer() core.Container {
+	transitDirs := []string{}
+	for _, channel := range ppl.transitChannels() {
+		transitDirs = append(transitDirs, path.Join(rsyncTransitPath, resourceDir(channel)))
+	}
+
 	return core.Container{
 		Name:            rsyncContainerName,
 		Image:           rsyncImage,
@@ -29,10 +36,12 @@ func (ppl Pipeline) rsyncServerContainer() core.Container {
 		VolumeMounts: []core.VolumeMount{
 			core.VolumeMount{MountPath: "/mnt/ssh", Name: "rsync"},
 			core.VolumeMount{MountPath: semaphoresPrefixPath, Name: sempahoresMountName},
+			core.VolumeMount{MountPath: rsyncTransitPath, Name: rsyncTransitVolumeName},
 		},
 		Env: []core.EnvVar{
-			{Name: "SSH_ENABLE_ROOT", Value: "true"},
+			{Name: "JINDRA_RSYNC_PORT", Value: fmt.Sprintf("%d", rsyncPort)},
 			{Name: "STAGES_RUNNING_SEMAPHORE", Value: path.Join(semaphoresPrefixPath, stagesRunningSemaphore)},
+			{Name: "JINDRA_TRANSIT_DIRS", Value: strings.Join(transitDirs, " ")},
 		},
 	}
 }
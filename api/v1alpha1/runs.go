@@ -0,0 +1,113 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	core "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// names of the containers, volumes and semaphores of stage pods that the
+// runner relies on -- runners outside of the cluster (jindra-cli run
+// --local) need them to emulate the runner pod
+const (
+	// WaitForAnnotationKey lists the containers of a stage pod (the steps)
+	// that decide whether the stage succeeded
+	WaitForAnnotationKey = waitForAnnotationKey
+	// WatcherContainerName is the container that deletes the semaphores of the
+	// steps once they finished -- it asks the pod watcher of the runner pod
+	WatcherContainerName = watcherContainerName
+	// DebugContainerName is the debug container, it is a step that doesn't
+	// block the outputs
+	DebugContainerName = debugContainerName
+	// ArtifactsContainerName is the container that saves the artifacts, even
+	// if the steps failed
+	ArtifactsContainerName = artifactsContainerName
+	// OutResourceContainerNamePrefix is the prefix of output resource containers
+	OutResourceContainerNamePrefix = outResourceContainerNamePrefix
+	// CacheSaveContainerNamePrefix is the prefix of the containers that save caches
+	CacheSaveContainerNamePrefix = cacheSaveContainerNamePrefix
+	// SemaphoresVolumeName is the volume with the semaphore files of a stage pod
+	SemaphoresVolumeName = sempahoresMountName
+	// StepsFinishedSemaphore is deleted when the steps finished
+	StepsFinishedSemaphore = "steps-finished"
+	// StepsRunningSemaphore is deleted when the steps succeeded
+	StepsRunningSemaphore = "steps-running"
+	// PVCTransitBackend is the transit backend that mounts a volume per
+	// transit channel into the stage pods
+	PVCTransitBackend = pvcTransitBackend
+)
+
+// PipelineLabelKey is the label with the name of the pipeline on the pods of
+// its runs
+const PipelineLabelKey = pipelineLabelKey
+
+// RunLister looks up the runs of pipelines
+// +kubebuilder:object:generate=false
+type RunLister interface {
+	// ActiveRuns returns the sorted build numbers of the runs of the pipeline
+	// that have pods which are not finished
+	ActiveRuns(ppl Pipeline) ([]int, error)
+}
+
+// ClientRunLister is a RunLister that reads the pods of runs from the cluster
+// +kubebuilder:object:generate=false
+type ClientRunLister struct {
+	client.Reader
+}
+
+// ActiveRuns implements RunLister -- pods that are being deleted are active
+// until they are gone, so runs that are cancelled are active until all their
+// pods are gone
+func (l ClientRunLister) ActiveRuns(ppl Pipeline) ([]int, error) {
+	var pods core.PodList
+	if err := l.List(context.Background(), &pods, client.InNamespace(ppl.Namespace), client.MatchingLabels{pipelineLabelKey: ppl.Name}); err != nil {
+		return nil, fmt.Errorf("error listing pods of pipeline %s: %s", ppl.Name, err)
+	}
+
+	active := map[int]bool{}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == core.PodSucceeded || pod.Status.Phase == core.PodFailed {
+			continue
+		}
+		if buildNo, err := strconv.Atoi(pod.Labels[runLabelKey]); err == nil {
+			active[buildNo] = true
+		}
+	}
+
+	runs := []int{}
+	for buildNo := range active {
+		runs = append(runs, buildNo)
+	}
+	sort.Ints(runs)
+
+	return runs, nil
+}
+
+// RunLabels returns the labels of all objects of run buildNo
+func (ppl Pipeline) RunLabels(buildNo int) map[string]string {
+	return defaultLabels(ppl.Name, buildNo, "")
+}
+
+// RunnerName returns the name of the runner pod of run buildNo
+func (ppl Pipeline) RunnerName(buildNo int) string {
+	return fmt.Sprintf(nameFormatString, ppl.Name, buildNo)
+}
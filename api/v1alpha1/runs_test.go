@@ -0,0 +1,60 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestActiveRuns(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Namespace = "ci"
+
+	pod := func(namespace string, labels map[string]string, phase core.PodPhase) runtime.Object {
+		return &core.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: namespace + "." + labels[runLabelKey] + "." + labels[stageLabelKey], Labels: labels},
+			Status:     core.PodStatus{Phase: phase},
+		}
+	}
+
+	for i, test := range []struct {
+		pods     []runtime.Object
+		expected []int
+		desc     string
+	}{
+		{[]runtime.Object{}, []int{}, "no pods"},
+		{[]runtime.Object{pod("ci", ppl.RunLabels(1), core.PodSucceeded), pod("ci", ppl.RunLabels(2), core.PodFailed)}, []int{}, "finished runs"},
+		{[]runtime.Object{pod("ci", ppl.RunLabels(3), core.PodRunning), pod("ci", defaultLabels(ppl.Name, 3, "build"), core.PodPending), pod("ci", ppl.RunLabels(2), core.PodRunning)},
+			[]int{2, 3}, "runner and stage pods"},
+		{[]runtime.Object{pod("ci", ppl.RunLabels(1), core.PodSucceeded), pod("ci", defaultLabels(ppl.Name, 1, "final"), core.PodRunning)}, []int{1}, "run with running stage"},
+		{[]runtime.Object{pod("other", ppl.RunLabels(1), core.PodRunning), pod("ci", defaultLabels("other", 2, ""), core.PodRunning)}, []int{}, "runs of other pipelines"},
+	} {
+		runs, err := ClientRunLister{fake.NewFakeClient(test.pods...)}.ActiveRuns(ppl)
+		if err != nil {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, nil, err.Error()))
+		}
+		if !reflect.DeepEqual(test.expected, runs) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected, runs))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
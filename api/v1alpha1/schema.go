@@ -0,0 +1,170 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"reflect"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+
+	"github.com/kesselborn/jindra/jsonschema"
+)
+
+// patterns of annotation values -- they must be valid in go and in
+// ECMAScript (the regex dialect of editors)
+const (
+	listPattern     = `^[^,\s]+(,[^,\s]+)*$`
+	envLinePattern  = `[^.=\s][^.=\n]*\.[^=\n]+=.*`
+	envLinesPattern = `^[ \t]*(` + envLinePattern + `)?(\n[ \t]*(` + envLinePattern + `)?)*$`
+)
+
+// annotationSchemas documents the annotations users can set on pipelines
+// and stages
+var annotationSchemas = map[string]jsonschema.Schema{
+	buildNoOffsetAnnotationKey: {
+		Type:        "string",
+		Description: "Offset for the build number (if you re-create a pipeline which already had runs before) -- must be a string",
+		Pattern:     `^[0-9]+$`,
+	},
+	imagePullPolicyAnnotationKey: {
+		Type:        "string",
+		Description: "Image pull policy of all jindra-generated containers",
+		Enum:        []string{string(core.PullAlways), string(core.PullIfNotPresent), string(core.PullNever)},
+	},
+	podSecurityAnnotationKey: {
+		Type:        "string",
+		Description: "Security contexts of the jindra-generated containers: empty (default: hardened), relaxed (no security contexts) or restricted (hardened, and stages must pass the restricted pod security standard)",
+		Enum:        []string{"", podSecurityRelaxed, podSecurityRestricted},
+	},
+	artifactsAnnotationKey: {
+		Type:        "string",
+		Description: "Comma separated list of paths below /jindra/resources of resources of this stage that are kept as artifacts (spec.artifacts)",
+		Pattern:     listPattern,
+	},
+	cachesAnnotationKey: {
+		Type:        "string",
+		Description: "Comma separated list of caches (spec.caches) this stage uses",
+		Pattern:     listPattern,
+	},
+	debugContainerAnnotationKey: {
+		Type:        "string",
+		Description: "Set to enable to add the container jindra-debug-container with all shared volumes -- the stage does not finish until /tmp/DELETE_ME_TO_STOP_DEBUG_CONTAINER is deleted in this container",
+		Enum:        []string{"enable"},
+	},
+	debugResourcesAnnotationKey: {
+		Type:        "string",
+		Description: "Set to enable to keep failing resource containers running for 5 more minutes",
+		Enum:        []string{"enable"},
+	},
+	firstInitContainers: {
+		Type:        "string",
+		Description: "Comma separated list of init containers that are executed in this order before the jindra-injected init containers",
+		Pattern:     listPattern,
+	},
+	inResourceAnnotationKey: {
+		Type:        "string",
+		Description: "Comma separated list of input resources and transit channels of this stage",
+		Pattern:     listPattern,
+	},
+	inResourceEnvAnnotationKey: {
+		Type:        "string",
+		Description: "Additional or modified env of input resources, one per line: <resource>.<param>=<value>",
+		Pattern:     envLinesPattern,
+	},
+	outResourceAnnotationKey: {
+		Type:        "string",
+		Description: "Comma separated list of output resources and transit channels of this stage",
+		Pattern:     listPattern,
+	},
+	outResourceEnvAnnotationKey: {
+		Type:        "string",
+		Description: "Additional or modified env of output resources, one per line: <resource>.<param>=<value>",
+		Pattern:     envLinesPattern,
+	},
+	servicesAnnotationKey: {
+		Type:        "string",
+		Description: "Comma separated list of containers which provide services for this stage (e.g. a database for testing) and are not waited for to finish",
+		Pattern:     listPattern,
+	},
+}
+
+// PipelineSchema returns the JSON schema of v1alpha1 pipelines with the
+// jindra annotations of pipelines and stages
+func PipelineSchema() *jsonschema.Schema {
+	s := jsonschema.Reflect(Pipeline{})
+
+	ppl := s.Definition(Pipeline{})
+	ppl.Properties["apiVersion"] = &jsonschema.Schema{Type: "string", Enum: []string{GroupVersion.String()}}
+	ppl.Properties["kind"] = &jsonschema.Schema{Type: "string", Enum: []string{"Pipeline"}}
+	ppl.Properties["metadata"] = WithAnnotations(ppl.Properties["metadata"], PipelineAnnotationsSchema())
+
+	// stages are pods with the stage annotations
+	stageName := strings.TrimSuffix(jsonschema.DefinitionName(reflect.TypeOf(Pipeline{})), "Pipeline") + "Stage"
+	s.Definitions[stageName] = &jsonschema.Schema{
+		AllOf: []*jsonschema.Schema{
+			jsonschema.RefTo(jsonschema.DefinitionName(reflect.TypeOf(core.Pod{}))),
+			{Properties: map[string]*jsonschema.Schema{"metadata": annotationsProperty(StageAnnotationsSchema())}},
+		},
+	}
+
+	spec := s.Definition(PipelineSpec{})
+	spec.Properties["stages"].Items = jsonschema.RefTo(stageName)
+	for _, name := range []string{"onSuccess", "onError", "final"} {
+		spec.Properties[name] = jsonschema.RefTo(stageName)
+	}
+
+	return s
+}
+
+// PipelineAnnotationsSchema returns the schema of the annotations of
+// pipelines
+func PipelineAnnotationsSchema() *jsonschema.Schema {
+	return annotationsSchema(pipelineAnnotationKeys)
+}
+
+// StageAnnotationsSchema returns the schema of the annotations of stages
+func StageAnnotationsSchema() *jsonschema.Schema {
+	return annotationsSchema(stageAnnotationKeys)
+}
+
+func annotationsSchema(keys []string) *jsonschema.Schema {
+	s := &jsonschema.Schema{
+		Type:                 "object",
+		Properties:           map[string]*jsonschema.Schema{},
+		AdditionalProperties: &jsonschema.Schema{Type: "string"},
+	}
+	for _, key := range keys {
+		annotation, ok := annotationSchemas[key]
+		if !ok {
+			// prefixes of keys are only checked by the validator
+			continue
+		}
+		s.Properties[key] = &annotation
+	}
+
+	return s
+}
+
+// WithAnnotations returns the schema of metadata (a reference to ObjectMeta)
+// with the schema of its annotations
+func WithAnnotations(metadata *jsonschema.Schema, annotations *jsonschema.Schema) *jsonschema.Schema {
+	return &jsonschema.Schema{AllOf: []*jsonschema.Schema{metadata, annotationsProperty(annotations)}}
+}
+
+func annotationsProperty(annotations *jsonschema.Schema) *jsonschema.Schema {
+	return &jsonschema.Schema{Properties: map[string]*jsonschema.Schema{"annotations": annotations}}
+}
@@ -0,0 +1,58 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"regexp"
+	"testing"
+)
+
+// TestAnnotationPatterns checks that the patterns of the schema accept the
+// same values as the validator
+func TestAnnotationPatterns(t *testing.T) {
+	for i, test := range []struct {
+		pattern string
+		value   string
+		valid   bool
+	}{
+		{listPattern, "git", true},
+		{listPattern, "git,transit,slack", true},
+		{listPattern, "git, transit", false},
+		{listPattern, "git,,transit", false},
+		{listPattern, "git,", false},
+		{envLinesPattern, "git.source.uri=git@github.com:kesselborn/jindra", true},
+		{envLinesPattern, "\n  git.source.branch=main\n\n\tslack.params.text=a = b\n", true},
+		{envLinesPattern, "git.source.uri", false},
+		{envLinesPattern, "git=main", false},
+		{envLinesPattern, ".source=main", false},
+		{envLinesPattern, "git.=main", false},
+	} {
+		if got := regexp.MustCompile(test.pattern).MatchString(test.value); got != test.valid {
+			t.Fatalf("\t%2d: %-80s [FAILED]: expected %t, got %t", i, test.value, test.valid, got)
+		}
+
+		if test.pattern == envLinesPattern {
+			valid := true
+			for _, line := range annotationLines(test.value) {
+				valid = valid && validEnvLine(line)
+			}
+			if valid != test.valid {
+				t.Fatalf("\t%2d: %-80s [FAILED]: validator returns %t", i, test.value, valid)
+			}
+		}
+		t.Logf("\t%2d: %-80q %s", i, test.value, ok())
+	}
+}
@@ -0,0 +1,95 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	core "k8s.io/api/core/v1"
+)
+
+// secret providers
+const (
+	kubernetesSecretProvider = "kubernetes"
+	vaultSecretProvider      = "vault"
+)
+
+// secretProvider resolves the secrets of the pipeline
+type secretProvider interface {
+	// env returns the env var name that references secret -- false if the
+	// secret is not configured for the provider
+	env(name string, secret Secret) (core.EnvVar, bool)
+	// resourceArgs returns the crij arguments resource containers need to
+	// read secrets at run time
+	resourceArgs() []string
+}
+
+func (ppl Pipeline) secretProvider() secretProvider {
+	if ppl.Spec.SecretProvider.Backend == vaultSecretProvider {
+		return vaultSecrets{ppl}
+	}
+
+	return kubernetesSecrets{ppl}
+}
+
+// kubernetesSecrets references secrets of the namespace of the pipeline via
+// secret key refs
+type kubernetesSecrets struct {
+	ppl Pipeline
+}
+
+func (p kubernetesSecrets) env(name string, secret Secret) (core.EnvVar, bool) {
+	if secret.SecretKeyRef == nil {
+		return core.EnvVar{}, false
+	}
+
+	return core.EnvVar{Name: name, ValueFrom: &core.EnvVarSource{SecretKeyRef: secret.SecretKeyRef.DeepCopy()}}, true
+}
+
+func (p kubernetesSecrets) resourceArgs() []string {
+	return []string{}
+}
+
+// vaultSecrets references secrets of vault which crij reads when the
+// resource container runs -- the values never end up in the stage pods
+type vaultSecrets struct {
+	ppl Pipeline
+}
+
+func (p vaultSecrets) env(name string, secret Secret) (core.EnvVar, bool) {
+	if secret.Vault == nil {
+		return core.EnvVar{}, false
+	}
+
+	return core.EnvVar{Name: name, Value: fmt.Sprintf(secretReferenceFormatString, vaultSecretProvider, secret.Vault.Path, secret.Vault.Key)}, true
+}
+
+func (p vaultSecrets) resourceArgs() []string {
+	config := VaultProvider{}
+	if p.ppl.Spec.SecretProvider.Vault != nil {
+		config = *p.ppl.Spec.SecretProvider.Vault
+	}
+
+	args := []string{"-vault-address=" + config.Address, "-vault-role=" + config.Role}
+	if config.AuthPath != "" {
+		args = append(args, "-vault-auth-path="+config.AuthPath)
+	}
+	if config.KVVersion != 0 {
+		args = append(args, fmt.Sprintf("-vault-kv-version=%d", config.KVVersion))
+	}
+
+	return args
+}
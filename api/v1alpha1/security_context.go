@@ -0,0 +1,225 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+)
+
+// readOnlyRootContainers are the generated containers that only write to
+// volumes and can run with a read-only root filesystem
+var readOnlyRootContainers = map[string]bool{
+	podwatcherContainerName:    true,
+	setSempahoresContainerName: true,
+	toolsContainerName:         true,
+	watcherContainerName:       true,
+}
+
+// podSecurity returns the value of the pod security annotation
+func (ppl Pipeline) podSecurity() string {
+	if ppl.Annotations == nil {
+		return ""
+	}
+
+	return ppl.Annotations[podSecurityAnnotationKey]
+}
+
+// hardenedSecurityContext returns the security context of generated
+// containers that complies with the "restricted" pod security standard
+func hardenedSecurityContext(readOnlyRoot bool) *core.SecurityContext {
+	f := false
+	t := true
+	uid := int64(nonRootUID)
+
+	return &core.SecurityContext{
+		AllowPrivilegeEscalation: &f,
+		Capabilities:             &core.Capabilities{Drop: []core.Capability{"ALL"}},
+		ReadOnlyRootFilesystem:   &readOnlyRoot,
+		RunAsNonRoot:             &t,
+		RunAsUser:                &uid,
+		SeccompProfile:           &core.SeccompProfile{Type: core.SeccompProfileTypeRuntimeDefault},
+	}
+}
+
+// hardenPod sets a hardened security context on all containers of p that are
+// not listed in userContainers and have no security context yet -- unless
+// the pipeline relaxes the pod security. The fs group of the pod is set, so
+// that the (non-root) containers can write to persistent volumes, and the
+// default seccomp profile, if the pod doesn't set one.
+func (ppl Pipeline) hardenPod(p *core.Pod, userContainers []string) {
+	if ppl.podSecurity() == podSecurityRelaxed {
+		return
+	}
+
+	skip := arrayToSet(userContainers)
+	harden := func(containers []core.Container) {
+		for i, c := range containers {
+			if skip[c.Name] || c.SecurityContext != nil {
+				continue
+			}
+
+			containers[i].SecurityContext = hardenedSecurityContext(readOnlyRootContainers[c.Name])
+			if !readOnlyRootContainers[c.Name] && !hasEnv(c, "HOME") {
+				// the home directories of most images belong to root
+				containers[i].Env = append(containers[i].Env, core.EnvVar{Name: "HOME", Value: "/tmp"})
+			}
+		}
+	}
+
+	harden(p.Spec.InitContainers)
+	harden(p.Spec.Containers)
+
+	if p.Spec.SecurityContext == nil {
+		p.Spec.SecurityContext = &core.PodSecurityContext{}
+	}
+	if p.Spec.SecurityContext.FSGroup == nil {
+		fsGroup := int64(nonRootUID)
+		p.Spec.SecurityContext.FSGroup = &fsGroup
+	}
+	if p.Spec.SecurityContext.SeccompProfile == nil && p.Annotations[seccompPodAnnotationKey] == "" {
+		p.Spec.SecurityContext.SeccompProfile = &core.SeccompProfile{Type: core.SeccompProfileTypeRuntimeDefault}
+	}
+}
+
+func hasEnv(c core.Container, name string) bool {
+	for _, env := range c.Env {
+		if env.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// restrictedViolations returns the violations of the "restricted" pod
+// security standard of p
+func restrictedViolations(p core.Pod) []string {
+	violations := []string{}
+	if p.Spec.HostNetwork || p.Spec.HostPID || p.Spec.HostIPC {
+		violations = append(violations, "host namespaces must not be used")
+	}
+
+	for _, v := range p.Spec.Volumes {
+		s := v.VolumeSource
+		if s.ConfigMap == nil && s.CSI == nil && s.DownwardAPI == nil && s.EmptyDir == nil &&
+			s.PersistentVolumeClaim == nil && s.Projected == nil && s.Secret == nil {
+			violations = append(violations, fmt.Sprintf("volume '%s' has a forbidden type", v.Name))
+		}
+	}
+
+	podNonRoot := false
+	podSeccomp := validSeccompProfile(nil, p.Annotations[seccompPodAnnotationKey])
+	if sc := p.Spec.SecurityContext; sc != nil {
+		podNonRoot = sc.RunAsNonRoot != nil && *sc.RunAsNonRoot
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			violations = append(violations, "pod must not run as user 0")
+		}
+		if sc.SeccompProfile != nil {
+			podSeccomp = validSeccompProfile(sc.SeccompProfile, "")
+		}
+	}
+
+	for _, c := range append(append([]core.Container{}, p.Spec.InitContainers...), p.Spec.Containers...) {
+		violation := func(format string, a ...interface{}) {
+			violations = append(violations, fmt.Sprintf("container '%s': ", c.Name)+fmt.Sprintf(format, a...))
+		}
+
+		for _, port := range c.Ports {
+			if port.HostPort != 0 {
+				violation("host port %d must not be used", port.HostPort)
+			}
+		}
+
+		sc := c.SecurityContext
+		if sc == nil {
+			sc = &core.SecurityContext{}
+		}
+
+		// the field takes precedence over the annotations, the container over
+		// the pod
+		seccomp := podSeccomp
+		if annotation, ok := p.Annotations[seccompContainerAnnotationKeyPrefix+c.Name]; ok {
+			seccomp = validSeccompProfile(nil, annotation)
+		}
+		if sc.SeccompProfile != nil {
+			seccomp = validSeccompProfile(sc.SeccompProfile, "")
+		}
+		if !seccomp {
+			violation("seccomp profile must be %s or %s", core.SeccompProfileTypeRuntimeDefault, core.SeccompProfileTypeLocalhost)
+		}
+		if sc.Privileged != nil && *sc.Privileged {
+			violation("must not be privileged")
+		}
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			violation("allowPrivilegeEscalation must be false")
+		}
+		if (sc.RunAsNonRoot == nil && !podNonRoot) || (sc.RunAsNonRoot != nil && !*sc.RunAsNonRoot) {
+			violation("runAsNonRoot must be true")
+		}
+		if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+			violation("must not run as user 0")
+		}
+
+		dropsAll := false
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Drop {
+				dropsAll = dropsAll || capability == "ALL"
+			}
+			for _, capability := range sc.Capabilities.Add {
+				if capability != "NET_BIND_SERVICE" {
+					violation("capability %s must not be added", capability)
+				}
+			}
+		}
+		if !dropsAll {
+			violation("capabilities must drop ALL")
+		}
+	}
+
+	return violations
+}
+
+// validSeccompProfile checks the seccomp profile field or, if it isn't set,
+// the value of a seccomp annotation
+func validSeccompProfile(profile *core.SeccompProfile, annotation string) bool {
+	if profile != nil {
+		return profile.Type == core.SeccompProfileTypeRuntimeDefault || profile.Type == core.SeccompProfileTypeLocalhost
+	}
+
+	return annotation == seccompRuntimeDefault || annotation == "docker/default" || strings.HasPrefix(annotation, "localhost/")
+}
+
+// restrictedStageViolations returns the violations of the "restricted" pod
+// security standard of the generated pods of all stages, indexed by stage
+// name -- stages without violations are omitted
+func (ppl Pipeline) restrictedStageViolations() (map[string][]string, error) {
+	pods, err := ppl.generateStagePods(ppl.Status.BuildNo)
+	if err != nil {
+		return nil, err
+	}
+
+	violations := map[string][]string{}
+	for _, pod := range pods {
+		if v := restrictedViolations(pod); len(v) > 0 {
+			violations[pod.Labels[stageLabelKey]] = v
+		}
+	}
+
+	return violations, nil
+}
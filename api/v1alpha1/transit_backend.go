@@ -0,0 +1,361 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// transit backends
+const (
+	rsyncTransitBackend = "rsync"
+	pvcTransitBackend   = "pvc"
+	s3TransitBackend    = "s3"
+)
+
+// transitBackend stores the transit directory between the stages of a run
+type transitBackend interface {
+	// container returns the resource container that syncs the transit
+	// channel name of stage -- false if the volume is mounted directly
+	container(stage core.Pod, name string) (core.Container, bool)
+	// transitVolumeSource returns the volume source of the transit channel name
+	transitVolumeSource(name string) core.VolumeSource
+	// stageVolumes returns additional volumes of the pod of stage
+	stageVolumes(stage core.Pod) []core.Volume
+	// runnerVolumes returns additional volumes of the runner pod
+	runnerVolumes() []core.Volume
+	// runnerContainers returns additional containers of the runner pod
+	runnerContainers() []core.Container
+	// objects returns the objects that need to be created for a run
+	objects() ([]runtime.Object, error)
+	// objectNames returns '<resource>/<name>' of all objects of a run
+	objectNames() []string
+}
+
+func (ppl Pipeline) transitBackend() transitBackend {
+	switch ppl.Spec.Transit.Backend {
+	case pvcTransitBackend:
+		return pvcTransit{ppl}
+	case s3TransitBackend:
+		return s3Transit{ppl}
+	}
+
+	return rsyncTransit{ppl}
+}
+
+// TransitObjects returns the objects the transit backend needs for a run
+func (ppl Pipeline) TransitObjects(buildNo int) ([]runtime.Object, error) {
+	ppl.Status.BuildNo = buildNo
+	return ppl.transitBackend().objects()
+}
+
+// isTransit returns true for the transit resource and named transit channels
+// ('transit:<channel>')
+func isTransit(name string) bool {
+	return name == "transit" || strings.HasPrefix(name, "transit:")
+}
+
+// usesTransit returns true if one of the resource names is a transit channel
+func usesTransit(names []string) bool {
+	for _, name := range names {
+		if isTransit(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// transitChannels returns all transit channels that are used in the pipeline
+func (ppl Pipeline) transitChannels() []string {
+	channels := []string{}
+	seen := map[string]bool{}
+	for _, stage := range ppl.allPods() {
+		for _, name := range resourceNames(stage) {
+			if isTransit(name) && !seen[name] {
+				channels = append(channels, name)
+				seen[name] = true
+			}
+		}
+	}
+	sort.Strings(channels)
+
+	return channels
+}
+
+// syncedResourceNames filters out resources that don't need a resource
+// container as their volume is mounted directly
+func (ppl Pipeline) syncedResourceNames(names []string) []string {
+	synced := []string{}
+	for _, name := range names {
+		if _, ok := ppl.transitBackend().container(core.Pod{}, name); isTransit(name) && !ok {
+			continue
+		}
+		synced = append(synced, name)
+	}
+
+	return synced
+}
+
+// rsyncTransit syncs the transit directory to an ssh server in the runner pod
+type rsyncTransit struct {
+	ppl Pipeline
+}
+
+// privateKeyName returns the key of the private key of stage in the rsync
+// secret
+func (t rsyncTransit) privateKeyName(stage string) string {
+	if t.ppl.Spec.Transit.Rsync != nil && t.ppl.Spec.Transit.Rsync.PerStageKeys {
+		return rsyncSecretPrivateKey + "." + stage
+	}
+
+	return rsyncSecretPrivateKey
+}
+
+func (t rsyncTransit) container(stage core.Pod, name string) (core.Container, bool) {
+	return t.ppl.transitContainer(name, t.privateKeyName(stage.Labels[stageLabelKey])), true
+}
+
+func (t rsyncTransit) transitVolumeSource(name string) core.VolumeSource {
+	return core.VolumeSource{EmptyDir: &core.EmptyDirVolumeSource{}}
+}
+
+func (t rsyncTransit) stageVolumes(stage core.Pod) []core.Volume {
+	if !usesTransit(resourceNames(stage)) {
+		return []core.Volume{}
+	}
+
+	defaultMode := int32(256)
+	return []core.Volume{{
+		Name: "jindra-rsync-ssh-keys",
+		VolumeSource: core.VolumeSource{
+			Secret: &core.SecretVolumeSource{
+				SecretName:  fmt.Sprintf(rsyncSecretFormatString, t.ppl.Name, t.ppl.Status.BuildNo),
+				DefaultMode: &defaultMode,
+				Items: []core.KeyToPath{
+					core.KeyToPath{Key: t.privateKeyName(stage.Labels[stageLabelKey]), Path: "./jindra"},
+				},
+			},
+		},
+	}}
+}
+
+func (t rsyncTransit) runnerVolumes() []core.Volume {
+	return []core.Volume{{
+		Name: rsyncTransitVolumeName, VolumeSource: core.VolumeSource{EmptyDir: &core.EmptyDirVolumeSource{}},
+	}, {
+		Name: "rsync", VolumeSource: core.VolumeSource{
+			Secret: &core.SecretVolumeSource{
+				SecretName: fmt.Sprintf(rsyncSecretFormatString, t.ppl.Name, t.ppl.Status.BuildNo),
+				Items: []core.KeyToPath{
+					{Key: rsyncSecretPubKey, Path: "./authorized_keys"},
+				},
+			},
+		},
+	}}
+}
+
+func (t rsyncTransit) runnerContainers() []core.Container {
+	return []core.Container{t.ppl.rsyncServerContainer()}
+}
+
+func (t rsyncTransit) objects() ([]runtime.Object, error) {
+	secret, err := t.ppl.NewRsyncSSHSecret(t.ppl.Status.BuildNo)
+	if err != nil {
+		return nil, err
+	}
+
+	return []runtime.Object{&secret}, nil
+}
+
+func (t rsyncTransit) objectNames() []string {
+	return []string{"secret/" + fmt.Sprintf(rsyncSecretFormatString, t.ppl.Name, t.ppl.Status.BuildNo)}
+}
+
+// pvcTransit mounts a persistent volume claim of the run as the transit
+// directory of every stage
+type pvcTransit struct {
+	ppl Pipeline
+}
+
+// claimName returns the name of the claim of transit channel name -- every
+// channel gets its own claim
+func (t pvcTransit) claimName(name string) string {
+	return fmt.Sprintf(transitPVCFormatString, t.ppl.Name, t.ppl.Status.BuildNo) + strings.TrimPrefix(resourceDir(name), "transit")
+}
+
+func (t pvcTransit) container(stage core.Pod, name string) (core.Container, bool) {
+	return core.Container{}, false
+}
+
+func (t pvcTransit) transitVolumeSource(name string) core.VolumeSource {
+	return core.VolumeSource{PersistentVolumeClaim: &core.PersistentVolumeClaimVolumeSource{ClaimName: t.claimName(name)}}
+}
+
+func (t pvcTransit) stageVolumes(stage core.Pod) []core.Volume {
+	return []core.Volume{}
+}
+
+func (t pvcTransit) runnerVolumes() []core.Volume {
+	return []core.Volume{}
+}
+
+func (t pvcTransit) runnerContainers() []core.Container {
+	return []core.Container{}
+}
+
+func (t pvcTransit) objects() ([]runtime.Object, error) {
+	config := PVCTransit{}
+	if t.ppl.Spec.Transit.PVC != nil {
+		config = *t.ppl.Spec.Transit.PVC
+	}
+
+	size := resource.MustParse(defaultTransitPVCSize)
+	if config.Size != nil {
+		size = *config.Size
+	}
+
+	accessModes := config.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []core.PersistentVolumeAccessMode{core.ReadWriteOnce}
+	}
+
+	objects := []runtime.Object{}
+	for _, channel := range t.ppl.transitChannels() {
+		objects = append(objects, &core.PersistentVolumeClaim{
+			TypeMeta: metav1.TypeMeta{
+				Kind:       "PersistentVolumeClaim",
+				APIVersion: "v1",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   t.claimName(channel),
+				Labels: defaultLabels(t.ppl.Name, t.ppl.Status.BuildNo, ""),
+			},
+			Spec: core.PersistentVolumeClaimSpec{
+				AccessModes:      accessModes,
+				StorageClassName: config.StorageClassName,
+				Resources: core.ResourceRequirements{
+					Requests: core.ResourceList{core.ResourceStorage: size},
+				},
+			},
+		})
+	}
+
+	return objects, nil
+}
+
+func (t pvcTransit) objectNames() []string {
+	names := []string{}
+	for _, channel := range t.ppl.transitChannels() {
+		names = append(names, "persistentvolumeclaim/"+t.claimName(channel))
+	}
+
+	return names
+}
+
+// s3Transit syncs the transit directory to an s3 compatible object storage
+// using the native s3 resource
+type s3Transit struct {
+	ppl Pipeline
+}
+
+func (t s3Transit) container(stage core.Pod, name string) (core.Container, bool) {
+	config := S3Transit{}
+	if t.ppl.Spec.Transit.S3 != nil {
+		config = *t.ppl.Spec.Transit.S3
+	}
+
+	secretEnv := func(name, key string) core.EnvVar {
+		return core.EnvVar{Name: name, ValueFrom: &core.EnvVarSource{
+			SecretKeyRef: &core.SecretKeySelector{
+				Key:                  key,
+				LocalObjectReference: core.LocalObjectReference{Name: config.CredentialsSecret},
+			},
+		}}
+	}
+
+	dir := resourceDir(name)
+	return core.Container{
+		Name:            dir,
+		Image:           nativeResourceImagePrefix + "s3",
+		ImagePullPolicy: t.ppl.imagePullPolicy(),
+		Env: []core.EnvVar{
+			{Name: dir + ".source.endpoint", Value: config.Endpoint},
+			{Name: dir + ".source.bucket", Value: config.Bucket},
+			{Name: dir + ".source.region", Value: config.Region},
+			{Name: dir + ".source.prefix", Value: path.Join(t.ppl.Namespace, t.ppl.Name, fmt.Sprintf("%d", t.ppl.Status.BuildNo), dir)},
+			secretEnv(dir+".source.access_key_id", s3AccessKeyIDKey),
+			secretEnv(dir+".source.secret_access_key", s3SecretAccessKeyKey),
+		},
+	}, true
+}
+
+func (t s3Transit) transitVolumeSource(name string) core.VolumeSource {
+	return core.VolumeSource{EmptyDir: &core.EmptyDirVolumeSource{}}
+}
+
+func (t s3Transit) stageVolumes(stage core.Pod) []core.Volume {
+	return []core.Volume{}
+}
+
+func (t s3Transit) runnerVolumes() []core.Volume {
+	return []core.Volume{{
+		Name: emptyVolumeName, VolumeSource: core.VolumeSource{EmptyDir: &core.EmptyDirVolumeSource{}},
+	}}
+}
+
+// runnerContainers deletes the objects of the transit channels once all
+// stages ran -- pushing an empty directory deletes all objects below the
+// prefix of a channel
+func (t s3Transit) runnerContainers() []core.Container {
+	containers := []core.Container{}
+	for _, channel := range t.ppl.transitChannels() {
+		c, _ := t.container(core.Pod{}, channel)
+		dir := c.Name
+		c.Name = transitCleanupContainerPrefix + dir
+		c.Image = toolsImage
+		c.VolumeMounts = []core.VolumeMount{
+			{MountPath: semaphoresPrefixPath, Name: sempahoresMountName},
+			{MountPath: emptyDirPath, Name: emptyVolumeName, ReadOnly: true},
+		}
+		c.Args = []string{
+			path.Join(toolsImagePath, "crij"),
+			"-env-prefix=" + dir,
+			"-semaphore-file=" + path.Join(semaphoresPrefixPath, stagesRunningSemaphore),
+			"-retries=3",
+			path.Join(toolsImagePath, nativeResourceBin), "s3", "out", emptyDirPath,
+		}
+		containers = append(containers, c)
+	}
+
+	return containers
+}
+
+func (t s3Transit) objects() ([]runtime.Object, error) {
+	return []runtime.Object{}, nil
+}
+
+func (t s3Transit) objectNames() []string {
+	return []string{}
+}
@@ -21,21 +21,27 @@ import (
 	core "k8s.io/api/core/v1"
 )
 
-func (ppl Pipeline) transitContainer() core.Container {
+// transitContainer returns the rsync resource container for the transit
+// channel name -- every channel is synced to its own directory on the server.
+// Keys are restricted to rrsync, which resolves paths relative to the transit
+// directory of the server.
+func (ppl Pipeline) transitContainer(name string, privateKeyName string) core.Container {
+	dir := resourceDir(name)
 	return core.Container{
-		Name:            transitContainerName,
+		Name:            dir,
 		Image:           transitImage,
 		ImagePullPolicy: ppl.imagePullPolicy(),
 		Env: []core.EnvVar{
-			{Name: "transit.params.rsync_opts", Value: `["--delete", "--recursive"]`},
-			{Name: "transit.source.server", Value: "${MY_IP}"},
-			{Name: "transit.source.base_dir", Value: "/tmp"},
-			{Name: "transit.source.user", Value: "root"},
-			{Name: "transit.source.disable_version_path", Value: "true"},
-			{Name: "transit.version", Value: `{"ref":"tmp"}`},
-			{Name: "transit.source.private_key", ValueFrom: &core.EnvVarSource{
+			{Name: dir + ".params.rsync_opts", Value: `["--delete", "--recursive"]`},
+			{Name: dir + ".source.server", Value: "${MY_IP}"},
+			{Name: dir + ".source.port", Value: fmt.Sprintf("%d", rsyncPort)},
+			{Name: dir + ".source.base_dir", Value: "/" + dir},
+			{Name: dir + ".source.user", Value: rsyncUser},
+			{Name: dir + ".source.disable_version_path", Value: "true"},
+			{Name: dir + ".version", Value: `{"ref":"tmp"}`},
+			{Name: dir + ".source.private_key", ValueFrom: &core.EnvVarSource{
 				SecretKeyRef: &core.SecretKeySelector{
-					Key:                  rsyncSecretPrivateKey,
+					Key:                  privateKeyName,
 					LocalObjectReference: core.LocalObjectReference{Name: fmt.Sprintf(rsyncSecretFormatString, ppl.Name, ppl.Status.BuildNo)},
 				},
 			}},
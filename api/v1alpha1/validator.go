@@ -17,189 +17,980 @@ package v1alpha1
 
 import (
 	"fmt"
+	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	core "k8s.io/api/core/v1"
-	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
-// Validate validates the correctnes of the Pipeline object
+// Validate validates the correctnes of the Pipeline object -- the error is
+// an api status error with reason StatusReasonInvalid that contains all
+// validation errors
 func (ppl Pipeline) Validate() error {
-	for _, f := range []func() error{
+	errs := ppl.ValidationErrors()
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("Pipeline").GroupKind(), ppl.Name, errs)
+	}
+
+	valLog.Info("validation successful", "pipeline", ppl.Name)
+	return nil
+}
+
+// ValidationErrors returns all validation errors of the Pipeline object
+func (ppl Pipeline) ValidationErrors() field.ErrorList {
+	errs := field.ErrorList{}
+	for _, f := range []func() field.ErrorList{
+		ppl.validNames,
 		ppl.correctOrNoRestartPolicy,
+		ppl.validArtifacts,
+		ppl.validCaches,
+		ppl.validObjectStores,
 		ppl.noDuplicateResourceAnnotations,
 		ppl.noDuplicateResourceNames,
+		ppl.nativeResourcesExist,
 		ppl.noOwnerReference,
 		ppl.referencedResourcesExist,
+		ppl.requiredResourceParamsSet,
 		ppl.serviceExist,
+		ppl.validStageServiceAccount,
 		ppl.triggerHasResource,
 		ppl.triggerIsInResourceOfFirstStage,
+		ppl.validTriggerSchedules,
+		ppl.validAnnotationKeys,
+		ppl.validAnnotationSyntax,
 		ppl.validImagePullPolicyAnnotation,
+		ppl.validResourceOptionsAnnotation,
+		ppl.validTransit,
+		ppl.validTransitChannels,
+		ppl.validTransitDataflow,
+		ppl.validSecretProvider,
+		ppl.validVariables,
+		ppl.validReferences,
+		ppl.validPodSecurityAnnotation,
 	} {
-		if err := f(); err != nil {
-			return err
-		}
+		errs = append(errs, f()...)
 	}
 
-	valLog.Info("validation successful", "pipeline", ppl.Name)
-	return nil
+	// objects can only be generated for pipelines without errors
+	if len(errs) == 0 {
+		errs = append(ppl.validGeneratedObjects(), ppl.validStagePodSecurity()...)
+	}
+
+	for _, err := range errs {
+		valLog.Info("validation failed", "pipeline", ppl.Name, "error", err.Error())
+	}
+
+	return errs
 }
 
 var valLog = logf.Log.WithName("pipeline-validator")
 
-func printValidationError(ppl Pipeline, err error) error {
-	valLog.Info("validation failed", "pipeline", ppl.Name, "error", err.Error())
+// UpdateErrors returns the errors of changes from old to ppl that must not
+// be made while runs are active -- runs use the stages of the config map that
+// was created when they started, so all other changes apply to the next run
+func (ppl Pipeline) UpdateErrors(old Pipeline, activeRuns []int) field.ErrorList {
+	errs := field.ErrorList{}
+	if len(activeRuns) == 0 {
+		return errs
+	}
+
+	runs := []string{}
+	for _, buildNo := range activeRuns {
+		runs = append(runs, fmt.Sprintf("%d", buildNo))
+	}
+
+	// the build numbers of new runs must not collide with the active runs
+	if ppl.Annotations[buildNoOffsetAnnotationKey] != old.Annotations[buildNoOffsetAnnotationKey] {
+		errs = append(errs, field.Forbidden(field.NewPath("metadata", "annotations").Key(buildNoOffsetAnnotationKey),
+			fmt.Sprintf("must not be changed while runs are active (%s)", strings.Join(runs, ", "))))
+	}
+
+	valLog.Info("validated UpdateErrors", "pipeline", ppl.Name)
+	return errs
+}
+
+// stagePath is a stage and its path in the pipeline
+type stagePath struct {
+	stage core.Pod
+	path  *field.Path
+}
+
+// annotation returns the path of the annotation key of the stage
+func (s stagePath) annotation(key string) *field.Path {
+	return s.path.Child("metadata", "annotations").Key(key)
+}
+
+// stagePaths returns all stages (like allPods) with their paths
+func (ppl Pipeline) stagePaths() []stagePath {
+	stages := []stagePath{}
+	for i, stage := range ppl.Spec.Stages {
+		stages = append(stages, stagePath{stage, field.NewPath("spec", "stages").Index(i)})
+	}
+
+	for _, s := range []struct {
+		stage *core.Pod
+		name  string
+	}{
+		{ppl.Spec.OnSuccess, "onSuccess"},
+		{ppl.Spec.OnError, "onError"},
+		{ppl.Spec.Final, "final"},
+	} {
+		if s.stage != nil {
+			stages = append(stages, stagePath{*s.stage, field.NewPath("spec", s.name)})
+		}
+	}
+
+	return stages
+}
+
+// notFound is field.NotFound with a detail
+func notFound(path *field.Path, value interface{}, detail string) *field.Error {
+	err := field.NotFound(path, value)
+	err.Detail = detail
 	return err
 }
 
-func (ppl Pipeline) triggerHasResource() error {
+func (ppl Pipeline) triggerHasResource() field.ErrorList {
+	errs := field.ErrorList{}
 	containerNames := map[string]bool{}
 	for _, resource := range ppl.Spec.Resources.Containers {
 		containerNames[resource.Name] = true
 	}
 
-	for _, trigger := range ppl.Spec.Resources.Triggers {
+	for i, trigger := range ppl.Spec.Resources.Triggers {
 		if _, ok := containerNames[trigger.Name]; !ok && trigger.Name != "" {
-			return printValidationError(ppl, fmt.Errorf("there is no resource for trigger '%s'", trigger.Name))
+			errs = append(errs, notFound(field.NewPath("spec", "resources", "triggers").Index(i).Child("name"), trigger.Name, "there is no resource with this name"))
 		}
 	}
 
 	valLog.Info("validated triggerHasResource", "pipeline", ppl.Name)
-	return nil
+	return errs
 }
 
-func (ppl Pipeline) triggerIsInResourceOfFirstStage() error {
+func (ppl Pipeline) triggerIsInResourceOfFirstStage() field.ErrorList {
+	errs := field.ErrorList{}
+	if len(ppl.Spec.Stages) == 0 {
+		return errs
+	}
+
 	stage1InResourcesArray := strings.Split(ppl.Spec.Stages[0].Annotations[inResourceAnnotationKey], ",")
 	stage1InResources := arrayToSet(stage1InResourcesArray)
+	containerNames := map[string]bool{}
+	for _, resource := range ppl.Spec.Resources.Containers {
+		containerNames[resource.Name] = true
+	}
 
-	for _, trigger := range ppl.Spec.Resources.Triggers {
-		if _, ok := stage1InResources[trigger.Name]; !ok && trigger.Name != "" {
-			return printValidationError(ppl, fmt.Errorf("invalid trigger '%s': every trigger needs to be an input resource of the first stage", trigger.Name))
+	for i, trigger := range ppl.Spec.Resources.Triggers {
+		// triggers without a resource are reported by triggerHasResource
+		if _, ok := stage1InResources[trigger.Name]; !ok && containerNames[trigger.Name] {
+			errs = append(errs, field.Invalid(field.NewPath("spec", "resources", "triggers").Index(i).Child("name"), trigger.Name, "every trigger needs to be an input resource of the first stage"))
 		}
 	}
 
 	valLog.Info("validated triggerIsInResourceOfFirstStage", "pipeline", ppl.Name)
-	return nil
+	return errs
 }
-func (ppl Pipeline) noDuplicateResourceAnnotations() error {
-	for _, stage := range ppl.allPods() {
-		if duplicate := findDuplicate(strings.Split(stage.Annotations[inResourceAnnotationKey], ",")); duplicate != "" {
-			return printValidationError(ppl, fmt.Errorf("stage '%s' uses the input resource '%s' twice", stage.Name, duplicate))
+
+// validNames checks the names that become part of the names and labels of
+// the generated objects
+func (ppl Pipeline) validNames() field.ErrorList {
+	errs := field.ErrorList{}
+	for _, msg := range validation.IsValidLabelValue(ppl.Name) {
+		errs = append(errs, field.Invalid(field.NewPath("metadata", "name"), ppl.Name, msg))
+	}
+
+	for _, s := range ppl.stagePaths() {
+		for _, msg := range validation.IsDNS1123Label(s.stage.Name) {
+			errs = append(errs, field.Invalid(s.path.Child("metadata", "name"), s.stage.Name, msg))
+		}
+	}
+
+	valLog.Info("validated validNames", "pipeline", ppl.Name)
+	return errs
+}
+
+func (ppl Pipeline) validTriggerSchedules() field.ErrorList {
+	errs := field.ErrorList{}
+	for i, trigger := range ppl.Spec.Resources.Triggers {
+		schedulePath := field.NewPath("spec", "resources", "triggers").Index(i).Child("schedule")
+		if trigger.Schedule == "" {
+			errs = append(errs, field.Required(schedulePath, "triggers need a cron schedule"))
+			continue
 		}
-		if duplicate := findDuplicate(strings.Split(stage.Annotations[outResourceAnnotationKey], ",")); duplicate != "" {
-			return printValidationError(ppl, fmt.Errorf("stage '%s' uses the output resource '%s' twice", stage.Name, duplicate))
+		if err := validCronSchedule(trigger.Schedule); err != nil {
+			errs = append(errs, field.Invalid(schedulePath, trigger.Schedule, err.Error()))
 		}
 	}
 
-	valLog.Info("validated noDuplicateResourceAnnotations", "pipeline", ppl.Name)
-	return nil
+	valLog.Info("validated validTriggerSchedules", "pipeline", ppl.Name)
+	return errs
 }
 
-func (ppl Pipeline) noDuplicateResourceNames() error {
-	containerNames := []string{}
-	for _, container := range ppl.Spec.Resources.Containers {
-		containerNames = append(containerNames, container.Name)
+// validAnnotationKeys reports jindra annotations that are unknown or set on
+// the wrong object
+func (ppl Pipeline) validAnnotationKeys() field.ErrorList {
+	errs := field.ErrorList{}
+	check := func(annotationsPath *field.Path, annotations map[string]string, known []string, other []string, otherKind string) {
+		keys := []string{}
+		for key := range annotations {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			switch {
+			case !strings.HasPrefix(key, jindraAnnotationPrefix) || isAnnotationKey(key, known):
+			case isAnnotationKey(key, other):
+				errs = append(errs, field.Invalid(annotationsPath, key, fmt.Sprintf("must be set on the %s", otherKind)))
+			case key == waitForAnnotationKey:
+				errs = append(errs, field.Forbidden(annotationsPath.Key(key), "is set by jindra"))
+			default:
+				detail := "unknown jindra annotation"
+				if match := closestMatch(key, known); match != "" {
+					detail += fmt.Sprintf(" (did you mean '%s'?)", match)
+				}
+				errs = append(errs, field.Invalid(annotationsPath, key, detail))
+			}
+		}
+	}
+
+	check(field.NewPath("metadata", "annotations"), ppl.Annotations, pipelineAnnotationKeys, stageAnnotationKeys, "stages")
+	for _, s := range ppl.stagePaths() {
+		check(s.path.Child("metadata", "annotations"), s.stage.Annotations, stageAnnotationKeys, pipelineAnnotationKeys, "pipeline")
+	}
+
+	valLog.Info("validated validAnnotationKeys", "pipeline", ppl.Name)
+	return errs
+}
+
+// isAnnotationKey returns true if key is one of keys or starts with one of
+// the prefixes (keys that end with a '.') in keys
+func isAnnotationKey(key string, keys []string) bool {
+	for _, k := range keys {
+		if key == k || (strings.HasSuffix(k, ".") && strings.HasPrefix(key, k) && len(key) > len(k)) {
+			return true
+		}
 	}
 
-	if duplicate := findDuplicate(containerNames); duplicate != "" {
-		return printValidationError(ppl, fmt.Errorf("resource name '%s' is used twice", duplicate))
+	return false
+}
+
+// validAnnotationSyntax checks the syntax of the values of the jindra
+// annotations -- references to resources, caches etc. are checked
+// separately
+func (ppl Pipeline) validAnnotationSyntax() field.ErrorList {
+	errs := field.ErrorList{}
+	pipelineAnnotations := field.NewPath("metadata", "annotations")
+
+	if offset, ok := ppl.Annotations[buildNoOffsetAnnotationKey]; ok {
+		if n, err := strconv.Atoi(offset); err != nil || n < 0 {
+			errs = append(errs, field.Invalid(pipelineAnnotations.Key(buildNoOffsetAnnotationKey), offset, "must be a non-negative number"))
+		}
+	}
+
+	for _, s := range ppl.stagePaths() {
+		for _, key := range []string{inResourceAnnotationKey, outResourceAnnotationKey, servicesAnnotationKey, firstInitContainers, cachesAnnotationKey, artifactsAnnotationKey} {
+			value, ok := s.stage.Annotations[key]
+			if !ok || value == "" {
+				continue
+			}
+			for _, entry := range strings.Split(value, ",") {
+				if entry == "" || strings.TrimSpace(entry) != entry {
+					errs = append(errs, field.Invalid(s.annotation(key), value, "must be a comma separated list without spaces or empty entries"))
+					break
+				}
+			}
+		}
+
+		for _, key := range []string{debugContainerAnnotationKey, debugResourcesAnnotationKey} {
+			if value, ok := s.stage.Annotations[key]; ok && value != "enable" {
+				errs = append(errs, field.NotSupported(s.annotation(key), value, []string{"enable"}))
+			}
+		}
+
+		for _, usage := range []struct {
+			key       string
+			resources []string
+			kind      string
+		}{
+			{inResourceEnvAnnotationKey, inResourcesNames(s.stage), "input"},
+			{outResourceEnvAnnotationKey, outResourcesNames(s.stage), "output"},
+		} {
+			resources := arrayToSet(usage.resources)
+			for _, line := range annotationLines(s.stage.Annotations[usage.key]) {
+				if !validEnvLine(line) {
+					errs = append(errs, field.Invalid(s.annotation(usage.key), line, "lines must have the format '<resource>.<param>=<value>'"))
+					continue
+				}
+				if resource := strings.Split(line, ".")[0]; !resources[resource] {
+					errs = append(errs, notFound(s.annotation(usage.key), resource, fmt.Sprintf("is not an %s resource of the stage", usage.kind)))
+				}
+			}
+		}
+
+		initContainers := arrayToSet(initContainerNames(s.stage))
+		names := map[string]bool{}
+		for _, name := range strings.Split(s.stage.Annotations[firstInitContainers], ",") {
+			if name == "" {
+				continue
+			}
+			if names[name] {
+				errs = append(errs, field.Duplicate(s.annotation(firstInitContainers), name))
+			}
+			names[name] = true
+			if !initContainers[name] {
+				errs = append(errs, notFound(s.annotation(firstInitContainers), name, "there is no init container with this name"))
+			}
+		}
+	}
+
+	valLog.Info("validated validAnnotationSyntax", "pipeline", ppl.Name)
+	return errs
+}
+
+// annotationLines returns the non-blank lines of annotation without leading
+// whitespace
+func annotationLines(annotation string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(annotation, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimLeft(line, " \t"))
+		}
+	}
+
+	return lines
+}
+
+// validEnvLine returns whether line has the format '<resource>.<name>=<value>'
+func validEnvLine(line string) bool {
+	tokens := strings.SplitN(line, "=", 2)
+	if len(tokens) != 2 {
+		return false
+	}
+
+	name := strings.SplitN(tokens[0], ".", 2)
+	return len(name) == 2 && name[0] != "" && name[1] != ""
+}
+
+func (ppl Pipeline) noDuplicateResourceAnnotations() field.ErrorList {
+	errs := field.ErrorList{}
+	for _, s := range ppl.stagePaths() {
+		for _, key := range []string{inResourceAnnotationKey, outResourceAnnotationKey} {
+			for _, duplicate := range findDuplicates(strings.Split(s.stage.Annotations[key], ",")) {
+				errs = append(errs, field.Duplicate(s.annotation(key), duplicate))
+			}
+		}
+	}
+
+	valLog.Info("validated noDuplicateResourceAnnotations", "pipeline", ppl.Name)
+	return errs
+}
+
+func (ppl Pipeline) noDuplicateResourceNames() field.ErrorList {
+	errs := field.ErrorList{}
+	names := map[string]bool{}
+	for i, container := range ppl.Spec.Resources.Containers {
+		if names[container.Name] {
+			errs = append(errs, field.Duplicate(field.NewPath("spec", "resources", "containers").Index(i).Child("name"), container.Name))
+		}
+		names[container.Name] = true
 	}
 
 	valLog.Info("validated noDuplicateResourceNames", "pipeline", ppl.Name)
-	return nil
+	return errs
 }
 
-func (ppl Pipeline) referencedResourcesExist() error {
+func (ppl Pipeline) referencedResourcesExist() field.ErrorList {
+	errs := field.ErrorList{}
 	resourceNames := map[string]bool{"transit": true}
 
 	for _, container := range ppl.Spec.Resources.Containers {
 		resourceNames[container.Name] = true
 	}
 
-	for _, stage := range ppl.allPods() {
-		for _, resource := range strings.Split(stage.Annotations[inResourceAnnotationKey], ",") {
-			if _, ok := resourceNames[resource]; !ok && resource != "" {
-				return printValidationError(ppl, fmt.Errorf("input resource '%s' referenced in stage '%s' does not exist", resource, stage.Name))
+	for _, s := range ppl.stagePaths() {
+		for _, key := range []string{inResourceAnnotationKey, outResourceAnnotationKey} {
+			for _, resource := range strings.Split(s.stage.Annotations[key], ",") {
+				if _, ok := resourceNames[resource]; !ok && resource != "" && !isTransit(resource) {
+					errs = append(errs, notFound(s.annotation(key), resource, "there is no resource with this name"))
+				}
 			}
 		}
-		for _, resource := range strings.Split(stage.Annotations[outResourceAnnotationKey], ",") {
-			if _, ok := resourceNames[resource]; !ok && resource != "" {
-				return printValidationError(ppl, fmt.Errorf("output resource '%s' referenced in stage '%s' does not exist", resource, stage.Name))
+	}
+
+	valLog.Info("validated referencedResourcesExist", "pipeline", ppl.Name)
+	return errs
+}
+
+func (ppl Pipeline) nativeResourcesExist() field.ErrorList {
+	errs := field.ErrorList{}
+	if nativeResources == nil {
+		valLog.Info("skipped nativeResourcesExist", "pipeline", ppl.Name, "reason", "no native resource registry")
+		return errs
+	}
+
+	containers, err := ppl.resourceContainers()
+	if err != nil {
+		// unresolved resource types are reported by requiredResourceParamsSet
+		valLog.Info("skipped nativeResourcesExist", "pipeline", ppl.Name, "reason", err.Error())
+		return errs
+	}
+
+	for i, container := range containers {
+		if nativeType := nativeResourceType(container); nativeType != "" && !nativeResources.Exists(nativeType) {
+			supported := []string{}
+			for _, name := range nativeResources.Names() {
+				supported = append(supported, nativeResourceImagePrefix+name)
 			}
+			errs = append(errs, field.NotSupported(field.NewPath("spec", "resources", "containers").Index(i).Child("image"), container.Image, supported))
 		}
 	}
 
-	valLog.Info("validated referencedResourcesExist", "pipeline", ppl.Name)
-	return nil
+	valLog.Info("validated nativeResourcesExist", "pipeline", ppl.Name)
+	return errs
+}
+
+func (ppl Pipeline) requiredResourceParamsSet() field.ErrorList {
+	errs := field.ErrorList{}
+	for i, container := range ppl.Spec.Resources.Containers {
+		if kind, _ := resourceTypeReference(container); kind == "" {
+			continue
+		}
+
+		c, err := ppl.applyResourceType(container)
+		if err != nil {
+			errs = append(errs, field.Invalid(field.NewPath("spec", "resources", "containers").Index(i).Child("image"), container.Image, err.Error()))
+			continue
+		}
+
+		for _, s := range ppl.stagePaths() {
+			for _, usage := range []struct {
+				resourceNames []string
+				envAnnotation string
+			}{
+				{inResourcesNames(s.stage), inResourceEnvAnnotationKey},
+				{outResourcesNames(s.stage), outResourceEnvAnnotationKey},
+			} {
+				if !arrayToSet(usage.resourceNames)[c.Name] {
+					continue
+				}
+
+				params := map[string]bool{}
+				for _, e := range append(c.Env, annotationToEnv(s.stage.Annotations[usage.envAnnotation])[c.Name]...) {
+					params[strings.TrimPrefix(e.Name, c.Name+".")] = true
+				}
+
+				for _, param := range ppl.ResourceTypes[container.Image].RequiredParams {
+					if !params[param] {
+						errs = append(errs, field.Required(s.annotation(usage.envAnnotation), fmt.Sprintf("resource '%s' of type '%s' needs the parameter '%s'", c.Name, container.Image, param)))
+					}
+				}
+			}
+		}
+	}
+
+	valLog.Info("validated requiredResourceParamsSet", "pipeline", ppl.Name)
+	return errs
 }
 
-func (ppl Pipeline) serviceExist() error {
-	for _, stage := range ppl.allPods() {
-		if services := strings.Split(stage.Annotations[servicesAnnotationKey], ","); len(services) > 1 && services[0] != "" {
+func (ppl Pipeline) serviceExist() field.ErrorList {
+	errs := field.ErrorList{}
+	for _, s := range ppl.stagePaths() {
+		if services := strings.Split(s.stage.Annotations[servicesAnnotationKey], ","); len(services) > 1 && services[0] != "" {
 			containers := map[string]bool{}
-			for _, container := range stage.Spec.Containers {
+			for _, container := range s.stage.Spec.Containers {
 				containers[container.Name] = true
 			}
 			for _, service := range services {
 				if _, ok := containers[service]; !ok {
-					return printValidationError(ppl, fmt.Errorf("service container '%s' referenced in stage '%s' does not exist", service, stage.Name))
+					errs = append(errs, notFound(s.annotation(servicesAnnotationKey), service, "there is no container with this name"))
 				}
 			}
 		}
 	}
 
 	valLog.Info("validated serviceExist", "pipeline", ppl.Name)
-	return nil
+	return errs
 }
 
-func (ppl Pipeline) noOwnerReference() error {
-	for _, stage := range ppl.allPods() {
-		if len(stage.OwnerReferences) > 0 {
-			return printValidationError(ppl, fmt.Errorf("stage '%s' must not have an owner reference", stage.Name))
+func (ppl Pipeline) validStageServiceAccount() field.ErrorList {
+	errs := field.ErrorList{}
+	if name := ppl.Spec.StageServiceAccountName; name != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			errs = append(errs, field.Invalid(field.NewPath("spec", "stageServiceAccountName"), name, msg))
+		}
+	}
+
+	valLog.Info("validated validStageServiceAccount", "pipeline", ppl.Name)
+	return errs
+}
+
+func (ppl Pipeline) noOwnerReference() field.ErrorList {
+	errs := field.ErrorList{}
+	for _, s := range ppl.stagePaths() {
+		if len(s.stage.OwnerReferences) > 0 {
+			errs = append(errs, field.Forbidden(s.path.Child("metadata", "ownerReferences"), "stages must not have owner references"))
 		}
 	}
 
 	valLog.Info("validated noOwnerReference", "pipeline", ppl.Name)
-	return nil
+	return errs
 }
 
-func (ppl Pipeline) correctOrNoRestartPolicy() error {
-	for _, stage := range ppl.allPods() {
-		if stage.Spec.RestartPolicy != core.RestartPolicyNever && stage.Spec.RestartPolicy != "" {
-			return printValidationError(ppl, fmt.Errorf(`restartPolicy of stage '%s' must not be set or set to "Never"`, stage.Name))
+func (ppl Pipeline) correctOrNoRestartPolicy() field.ErrorList {
+	errs := field.ErrorList{}
+	for _, s := range ppl.stagePaths() {
+		if s.stage.Spec.RestartPolicy != core.RestartPolicyNever && s.stage.Spec.RestartPolicy != "" {
+			errs = append(errs, field.NotSupported(s.path.Child("spec", "restartPolicy"), s.stage.Spec.RestartPolicy, []string{string(core.RestartPolicyNever)}))
 		}
 	}
 
 	valLog.Info("validated correctOrNoRestartPolicy", "pipeline", ppl.Name)
-	return nil
+	return errs
+}
+
+func (ppl Pipeline) validImagePullPolicyAnnotation() field.ErrorList {
+	errs := field.ErrorList{}
+	v := ppl.imagePullPolicy()
+	if v != "" && v != core.PullAlways && v != core.PullIfNotPresent && v != core.PullNever {
+		errs = append(errs, field.NotSupported(field.NewPath("metadata", "annotations").Key(imagePullPolicyAnnotationKey), v,
+			[]string{string(core.PullAlways), string(core.PullIfNotPresent), string(core.PullNever)}))
+	}
+
+	valLog.Info("validated validImagePullPolicyAnnotation", "pipeline", ppl.Name)
+	return errs
 }
 
-func (ppl Pipeline) validImagePullPolicyAnnotation() error {
-	if ppl.Annotations == nil {
-		valLog.Info("validated correctOrNoRestartPolicy", "pipeline", ppl.Name)
-		return nil
+func (ppl Pipeline) validPodSecurityAnnotation() field.ErrorList {
+	errs := field.ErrorList{}
+	security := ppl.podSecurity()
+	if security != "" && security != podSecurityRelaxed && security != podSecurityRestricted {
+		errs = append(errs, field.NotSupported(field.NewPath("metadata", "annotations").Key(podSecurityAnnotationKey), security, []string{podSecurityRelaxed, podSecurityRestricted}))
 	}
 
-	v := ppl.Annotations[imagePullPolicyAnnotationKey]
-	if v == "" || v == string(core.PullAlways) || v == string(core.PullIfNotPresent) || v == string(core.PullNever) {
-		valLog.Info("validated correctOrNoRestartPolicy", "pipeline", ppl.Name)
-		return nil
+	valLog.Info("validated validPodSecurityAnnotation", "pipeline", ppl.Name)
+	return errs
+}
+
+// validStagePodSecurity reports the stages that violate the "restricted" pod
+// security standard -- violations are only an error if the pipeline
+// requires restricted pod security
+func (ppl Pipeline) validStagePodSecurity() field.ErrorList {
+	errs := field.ErrorList{}
+	violations, err := ppl.restrictedStageViolations()
+	if err != nil {
+		return append(errs, field.InternalError(field.NewPath("spec"), fmt.Errorf("error generating stage pods: %s", err)))
+	}
+
+	for _, s := range ppl.stagePaths() {
+		v, ok := violations[s.stage.Name]
+		if !ok {
+			continue
+		}
+		if ppl.podSecurity() == podSecurityRestricted {
+			errs = append(errs, field.Forbidden(s.path, "violates the restricted pod security standard: "+strings.Join(v, ", ")))
+			continue
+		}
+		valLog.Info("stage violates the restricted pod security standard", "pipeline", ppl.Name, "stage", s.stage.Name, "violations", v)
 	}
 
-	return printValidationError(ppl, fmt.Errorf("invalid pull policy '%s'", v))
+	valLog.Info("validated validStagePodSecurity", "pipeline", ppl.Name)
+	return errs
 }
 
-func findDuplicate(words []string) string {
-	wordSet := map[string]bool{}
+func (ppl Pipeline) validResourceOptionsAnnotation() field.ErrorList {
+	errs := field.ErrorList{}
+	resourceDirs := map[string]bool{}
+	for _, container := range ppl.Spec.Resources.Containers {
+		resourceDirs[resourceDir(container.Name)] = true
+	}
+	for _, channel := range ppl.transitChannels() {
+		resourceDirs[resourceDir(channel)] = true
+	}
+
+	keys := []string{}
+	for key := range ppl.Annotations {
+		if strings.HasPrefix(key, resourceOptionsAnnotationKeyPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		annotation := field.NewPath("metadata", "annotations").Key(key)
+		resource := strings.TrimPrefix(key, resourceOptionsAnnotationKeyPrefix)
+		if !resourceDirs[resource] {
+			errs = append(errs, notFound(annotation, resource, "there is no resource with this name"))
+			continue
+		}
+
+		for _, line := range annotationLines(ppl.Annotations[key]) {
+			tokens := strings.SplitN(line, "=", 2)
+			if len(tokens) != 2 {
+				errs = append(errs, field.Invalid(annotation, line, "lines must have the format '<option>=<value>'"))
+				continue
+			}
+
+			var err error
+			option, value := strings.TrimSpace(tokens[0]), strings.TrimSpace(tokens[1])
+			switch option {
+			case "timeout", "retry-backoff":
+				_, err = time.ParseDuration(value)
+			case "retries":
+				_, err = strconv.ParseUint(value, 10, 32)
+			default:
+				errs = append(errs, field.NotSupported(annotation, option, []string{"timeout", "retries", "retry-backoff"}))
+				continue
+			}
+
+			if err != nil {
+				errs = append(errs, field.Invalid(annotation, line, err.Error()))
+			}
+		}
+	}
+
+	valLog.Info("validated validResourceOptionsAnnotation", "pipeline", ppl.Name)
+	return errs
+}
+
+func (ppl Pipeline) validTransit() field.ErrorList {
+	errs := field.ErrorList{}
+	transit := ppl.Spec.Transit
+	transitPath := field.NewPath("spec", "transit")
+
+	switch transit.Backend {
+	case "", rsyncTransitBackend, pvcTransitBackend, s3TransitBackend:
+	default:
+		errs = append(errs, field.NotSupported(transitPath.Child("backend"), transit.Backend, []string{rsyncTransitBackend, pvcTransitBackend, s3TransitBackend}))
+	}
+
+	if transit.Rsync != nil && transit.Backend != "" && transit.Backend != rsyncTransitBackend {
+		errs = append(errs, field.Forbidden(transitPath.Child("rsync"), fmt.Sprintf("must only be set for transit backend '%s'", rsyncTransitBackend)))
+	}
+
+	if transit.PVC != nil && transit.Backend != pvcTransitBackend {
+		errs = append(errs, field.Forbidden(transitPath.Child("pvc"), fmt.Sprintf("must only be set for transit backend '%s'", pvcTransitBackend)))
+	}
+
+	if transit.S3 != nil && transit.Backend != s3TransitBackend {
+		errs = append(errs, field.Forbidden(transitPath.Child("s3"), fmt.Sprintf("must only be set for transit backend '%s'", s3TransitBackend)))
+	}
+
+	if transit.Backend == s3TransitBackend {
+		errs = append(errs, requiredS3Fields(transitPath.Child("s3"), transit.S3, fmt.Sprintf("transit backend '%s'", s3TransitBackend))...)
+	}
+
+	valLog.Info("validated validTransit", "pipeline", ppl.Name)
+	return errs
+}
+
+// requiredS3Fields returns an error for every missing field of the s3
+// config at path that user needs
+func requiredS3Fields(path *field.Path, s3 *S3Transit, user string) field.ErrorList {
+	if s3 == nil {
+		return field.ErrorList{field.Required(path, fmt.Sprintf("%s needs endpoint, bucket and credentialsSecret", user))}
+	}
+
+	errs := field.ErrorList{}
+	for _, f := range []struct {
+		name  string
+		value string
+	}{
+		{"endpoint", s3.Endpoint},
+		{"bucket", s3.Bucket},
+		{"credentialsSecret", s3.CredentialsSecret},
+	} {
+		if f.value == "" {
+			errs = append(errs, field.Required(path.Child(f.name), fmt.Sprintf("%s needs %s", user, f.name)))
+		}
+	}
+
+	return errs
+}
+
+func (ppl Pipeline) validTransitChannels() field.ErrorList {
+	errs := field.ErrorList{}
+	resourceNames := map[string]bool{}
+	for _, container := range ppl.Spec.Resources.Containers {
+		resourceNames[container.Name] = true
+	}
+
+	// every channel is reported once, at its first usage
+	reported := map[string]bool{}
+	for _, s := range ppl.stagePaths() {
+		for _, key := range []string{inResourceAnnotationKey, outResourceAnnotationKey} {
+			for _, channel := range strings.Split(s.stage.Annotations[key], ",") {
+				if !isTransit(channel) || channel == "transit" || reported[channel] {
+					continue
+				}
+				reported[channel] = true
+
+				for _, msg := range validation.IsDNS1123Label(strings.TrimPrefix(channel, "transit:")) {
+					errs = append(errs, field.Invalid(s.annotation(key), channel, msg))
+				}
+
+				if resourceNames[resourceDir(channel)] {
+					errs = append(errs, field.Invalid(s.annotation(key), channel, fmt.Sprintf("transit channel conflicts with resource '%s'", resourceDir(channel))))
+				}
+			}
+		}
+	}
+
+	valLog.Info("validated validTransitChannels", "pipeline", ppl.Name)
+	return errs
+}
+
+// validTransitDataflow checks the transit channels along the order the
+// stages run in: stages only write their outputs if they succeed, so a
+// channel can only be read if an earlier stage writes it -- onSuccess runs
+// after all stages succeeded, onError after the first failing stage and
+// final after onSuccess or onError, so the latter two can't rely on any
+// channel
+func (ppl Pipeline) validTransitDataflow() field.ErrorList {
+	errs := field.ErrorList{}
+
+	// channels that are an output of any stage
+	writtenByAny := map[string]bool{}
+	for _, s := range ppl.stagePaths() {
+		for _, channel := range outResourcesNames(s.stage) {
+			writtenByAny[channel] = true
+		}
+	}
+
+	// written contains the channels that exist when the stage starts --
+	// failurePath explains why they might not exist if it is set
+	checkInputs := func(s stagePath, written map[string]bool, failurePath string) {
+		for _, channel := range inResourcesNames(s.stage) {
+			switch {
+			case !isTransit(channel) || written[channel]:
+			case !writtenByAny[channel]:
+				errs = append(errs, notFound(s.annotation(inResourceAnnotationKey), channel, "there is no stage with this transit channel as output"))
+			case failurePath != "":
+				errs = append(errs, field.Invalid(s.annotation(inResourceAnnotationKey), channel, fmt.Sprintf("transit channel might not exist as %s -- stages only write their outputs if they succeed", failurePath)))
+			default:
+				errs = append(errs, field.Invalid(s.annotation(inResourceAnnotationKey), channel, "transit channel is not an output of an earlier stage"))
+			}
+		}
+	}
+
+	stages := ppl.stagePaths()
+	written := map[string]bool{}
+	for _, s := range stages[:len(ppl.Spec.Stages)] {
+		checkInputs(s, written, "")
+		for _, channel := range outResourcesNames(s.stage) {
+			written[channel] = true
+		}
+	}
+
+	for _, s := range stages[len(ppl.Spec.Stages):] {
+		switch s.path.String() {
+		case "spec.onSuccess":
+			checkInputs(s, written, "")
+		case "spec.onError":
+			checkInputs(s, map[string]bool{}, "onError runs after the first failing stage")
+		default:
+			checkInputs(s, map[string]bool{}, "final also runs if a stage failed")
+		}
+	}
+
+	valLog.Info("validated validTransitDataflow", "pipeline", ppl.Name)
+	return errs
+}
+
+// ValidationWarnings returns the problems of the Pipeline object that don't
+// make it invalid, e.g. transit channels that are written but never read
+// (they might only be written for the artifacts of the stage)
+func (ppl Pipeline) ValidationWarnings() []string {
+	warnings := []string{}
+	for _, err := range ppl.unreadTransitChannels() {
+		warnings = append(warnings, err.Error())
+	}
+
+	return warnings
+}
+
+// unreadTransitChannels returns the transit channels that are not read by a
+// stage that runs after the stage that writes them
+func (ppl Pipeline) unreadTransitChannels() field.ErrorList {
+	errs := field.ErrorList{}
+
+	// readers returns the channels the stages read
+	readers := func(stages ...*core.Pod) map[string]bool {
+		read := map[string]bool{}
+		for _, p := range stages {
+			if p == nil {
+				continue
+			}
+			for _, channel := range inResourcesNames(*p) {
+				read[channel] = true
+			}
+		}
+		return read
+	}
+
+	stages := ppl.stagePaths()
+	for i, s := range stages[:len(ppl.Spec.Stages)] {
+		later := []*core.Pod{ppl.Spec.OnSuccess, ppl.Spec.OnError, ppl.Spec.Final}
+		for j := i + 1; j < len(ppl.Spec.Stages); j++ {
+			later = append(later, &ppl.Spec.Stages[j])
+		}
+		errs = append(errs, unreadChannels(s, readers(later...))...)
+	}
+
+	for _, s := range stages[len(ppl.Spec.Stages):] {
+		switch s.path.String() {
+		case "spec.onSuccess", "spec.onError":
+			errs = append(errs, unreadChannels(s, readers(ppl.Spec.Final))...)
+		default:
+			errs = append(errs, unreadChannels(s, readers())...)
+		}
+	}
+
+	return errs
+}
+
+// unreadChannels returns errors for the transit channels the stage writes
+// that are not in read
+func unreadChannels(s stagePath, read map[string]bool) field.ErrorList {
+	errs := field.ErrorList{}
+	for _, channel := range outResourcesNames(s.stage) {
+		if isTransit(channel) && !read[channel] {
+			errs = append(errs, field.Invalid(s.annotation(outResourceAnnotationKey), channel, "transit channel is not an input of any stage that runs later"))
+		}
+	}
+
+	return errs
+}
+
+func (ppl Pipeline) validCaches() field.ErrorList {
+	errs := field.ErrorList{}
+	maxNameLength := validation.DNS1123LabelMaxLength - len(cacheRestoreContainerNamePrefix)
+	names := map[string]bool{}
+	for i, cache := range ppl.Spec.Caches {
+		cachePath := field.NewPath("spec", "caches").Index(i)
+		for _, msg := range validation.IsDNS1123Label(cache.Name) {
+			errs = append(errs, field.Invalid(cachePath.Child("name"), cache.Name, msg))
+		}
+
+		if len(cache.Name) > maxNameLength {
+			errs = append(errs, field.Invalid(cachePath.Child("name"), cache.Name, fmt.Sprintf("must be no more than %d characters", maxNameLength)))
+		}
+
+		if names[cache.Name] {
+			errs = append(errs, field.Duplicate(cachePath.Child("name"), cache.Name))
+		}
+		names[cache.Name] = true
+
+		if !path.IsAbs(cache.Path) {
+			errs = append(errs, field.Invalid(cachePath.Child("path"), cache.Path, "must be absolute"))
+		}
+
+		for j, file := range cache.KeyFiles {
+			if path.IsAbs(file) || strings.HasPrefix(path.Clean(file), "..") {
+				errs = append(errs, field.Invalid(cachePath.Child("keyFiles").Index(j), file, fmt.Sprintf("must be relative to %s", resourcesPrefixPath)))
+			}
+		}
+	}
+
+	for _, s := range ppl.stagePaths() {
+		for _, name := range cacheNames(s.stage) {
+			if !names[name] {
+				errs = append(errs, notFound(s.annotation(cachesAnnotationKey), name, "cache is not defined in spec.caches"))
+			}
+		}
+	}
+
+	valLog.Info("validated validCaches", "pipeline", ppl.Name)
+	return errs
+}
+
+func (ppl Pipeline) validObjectStores() field.ErrorList {
+	errs := field.ErrorList{}
+	for _, objectStore := range []struct {
+		path  *field.Path
+		store ObjectStore
+	}{
+		{field.NewPath("spec", "cacheStore"), ppl.Spec.CacheStore},
+		{field.NewPath("spec", "artifacts", "store"), ppl.Spec.Artifacts.Store},
+	} {
+		storePath, store := objectStore.path, objectStore.store
+		switch store.Backend {
+		case "", pvcObjectStoreBackend, s3ObjectStoreBackend:
+		default:
+			errs = append(errs, field.NotSupported(storePath.Child("backend"), store.Backend, []string{pvcObjectStoreBackend, s3ObjectStoreBackend}))
+		}
+
+		if store.S3 != nil && store.Backend != s3ObjectStoreBackend {
+			errs = append(errs, field.Forbidden(storePath.Child("s3"), fmt.Sprintf("must only be set for backend '%s'", s3ObjectStoreBackend)))
+		}
+
+		if store.ClaimName != "" && store.Backend == s3ObjectStoreBackend {
+			errs = append(errs, field.Forbidden(storePath.Child("claimName"), fmt.Sprintf("must only be set for backend '%s'", pvcObjectStoreBackend)))
+		}
+
+		if store.Backend == s3ObjectStoreBackend {
+			errs = append(errs, requiredS3Fields(storePath.Child("s3"), store.S3, fmt.Sprintf("backend '%s'", s3ObjectStoreBackend))...)
+		}
+	}
+
+	valLog.Info("validated validObjectStores", "pipeline", ppl.Name)
+	return errs
+}
+
+func (ppl Pipeline) validArtifacts() field.ErrorList {
+	errs := field.ErrorList{}
+	artifactsPath := field.NewPath("spec", "artifacts")
+	if ppl.Spec.Artifacts.KeepRuns < 0 {
+		errs = append(errs, field.Invalid(artifactsPath.Child("keepRuns"), ppl.Spec.Artifacts.KeepRuns, "must not be negative"))
+	}
+
+	if ppl.Spec.Artifacts.MaxAge != nil && ppl.Spec.Artifacts.MaxAge.Duration <= 0 {
+		errs = append(errs, field.Invalid(artifactsPath.Child("maxAge"), ppl.Spec.Artifacts.MaxAge.Duration.String(), "must be positive"))
+	}
+
+	for _, s := range ppl.stagePaths() {
+		dirs := map[string]bool{}
+		for _, name := range resourceNames(s.stage) {
+			dirs[resourceDir(name)] = true
+		}
+
+		for _, artifact := range artifactPaths(s.stage) {
+			rel := strings.TrimPrefix(path.Clean(artifact), resourcesPrefixPath+"/")
+			if !path.IsAbs(artifact) || rel == path.Clean(artifact) {
+				errs = append(errs, field.Invalid(s.annotation(artifactsAnnotationKey), artifact, fmt.Sprintf("must be a path below %s", resourcesPrefixPath)))
+				continue
+			}
+
+			if dir := strings.Split(rel, "/")[0]; !dirs[dir] {
+				errs = append(errs, field.Invalid(s.annotation(artifactsAnnotationKey), artifact, fmt.Sprintf("must be below a resource of the stage ('%s' is neither an input nor an output)", dir)))
+			}
+		}
+	}
+
+	valLog.Info("validated validArtifacts", "pipeline", ppl.Name)
+	return errs
+}
+
+// findDuplicates returns every word that is in words more than once
+func findDuplicates(words []string) []string {
+	duplicates := []string{}
+	count := map[string]int{}
 
 	for _, word := range words {
-		if wordSet[word] {
-			return word
+		count[word]++
+		if count[word] == 2 {
+			duplicates = append(duplicates, word)
 		}
-		wordSet[word] = true
 	}
 
-	return ""
+	return duplicates
 }
 
 func arrayToSet(words []string) map[string]bool {
@@ -212,6 +1003,131 @@ func arrayToSet(words []string) map[string]bool {
 	return set
 }
 
+func (ppl Pipeline) validVariables() field.ErrorList {
+	errs := field.ErrorList{}
+	names := map[string]bool{}
+	checkName := func(namePath *field.Path, name string) {
+		for _, msg := range validation.IsConfigMapKey(name) {
+			errs = append(errs, field.Invalid(namePath, name, msg))
+		}
+		if names[name] {
+			errs = append(errs, field.Duplicate(namePath, name))
+		}
+		names[name] = true
+	}
+
+	for i, v := range ppl.Spec.Variables {
+		checkName(field.NewPath("spec", "variables").Index(i).Child("name"), v.Name)
+	}
+
+	for i, s := range ppl.Spec.Secrets {
+		secretPath := field.NewPath("spec", "secrets").Index(i)
+		checkName(secretPath.Child("name"), s.Name)
+		switch ppl.Spec.SecretProvider.Backend {
+		case vaultSecretProvider:
+			if s.Vault == nil || s.Vault.Path == "" || s.Vault.Key == "" {
+				errs = append(errs, field.Required(secretPath.Child("vault"), fmt.Sprintf("secrets of secret provider '%s' need vault.path and vault.key", vaultSecretProvider)))
+			}
+		default:
+			if s.SecretKeyRef == nil || s.SecretKeyRef.Name == "" || s.SecretKeyRef.Key == "" {
+				errs = append(errs, field.Required(secretPath.Child("secretKeyRef"), "secrets need secretKeyRef.name and secretKeyRef.key"))
+			}
+		}
+	}
+
+	valLog.Info("validated validVariables", "pipeline", ppl.Name)
+	return errs
+}
+
+func (ppl Pipeline) validSecretProvider() field.ErrorList {
+	errs := field.ErrorList{}
+	provider := ppl.Spec.SecretProvider
+	providerPath := field.NewPath("spec", "secretProvider")
+
+	switch provider.Backend {
+	case "", kubernetesSecretProvider, vaultSecretProvider:
+	default:
+		errs = append(errs, field.NotSupported(providerPath.Child("backend"), provider.Backend, []string{kubernetesSecretProvider, vaultSecretProvider}))
+	}
+
+	if provider.Vault != nil && provider.Backend != vaultSecretProvider {
+		errs = append(errs, field.Forbidden(providerPath.Child("vault"), fmt.Sprintf("must only be set for secret provider '%s'", vaultSecretProvider)))
+	}
+
+	if provider.Backend == vaultSecretProvider {
+		if provider.Vault == nil {
+			errs = append(errs, field.Required(providerPath.Child("vault"), fmt.Sprintf("secret provider '%s' needs address and role", vaultSecretProvider)))
+			return errs
+		}
+		if provider.Vault.Address == "" {
+			errs = append(errs, field.Required(providerPath.Child("vault", "address"), fmt.Sprintf("secret provider '%s' needs the address of vault", vaultSecretProvider)))
+		}
+		if provider.Vault.Role == "" {
+			errs = append(errs, field.Required(providerPath.Child("vault", "role"), fmt.Sprintf("secret provider '%s' needs the role of jindra in vault", vaultSecretProvider)))
+		}
+		if provider.Vault.KVVersion != 0 && provider.Vault.KVVersion != 1 && provider.Vault.KVVersion != 2 {
+			errs = append(errs, field.NotSupported(providerPath.Child("vault", "kvVersion"), provider.Vault.KVVersion, []string{"1", "2"}))
+		}
+	}
+
+	valLog.Info("validated validSecretProvider", "pipeline", ppl.Name)
+	return errs
+}
+
+func (ppl Pipeline) validReferences() field.ErrorList {
+	errs := field.ErrorList{}
+	// secrets of providers that are read at run time can only be used by
+	// resources, as only their containers resolve references
+	runtimeSecrets := ppl.Spec.SecretProvider.Backend == vaultSecretProvider
+	checkValue := func(valuePath *field.Path, value string, resource bool) {
+		for _, name := range references(value) {
+			_, isVariable := ppl.variable(name)
+			_, isSecret := ppl.secret(name)
+			if !isVariable && !isSecret {
+				errs = append(errs, field.Invalid(valuePath, value, fmt.Sprintf("undefined variable or secret '((%s))'", name)))
+				continue
+			}
+			if secretName, ok := secretReference(value); isSecret && (!ok || secretName != name) {
+				errs = append(errs, field.Invalid(valuePath, value, fmt.Sprintf("secret '((%s))' must be the whole value", name)))
+			}
+			if isSecret && runtimeSecrets && !resource {
+				errs = append(errs, field.Forbidden(valuePath, fmt.Sprintf("secret '((%s))' of secret provider '%s' can only be used in env vars of resources", name, ppl.Spec.SecretProvider.Backend)))
+			}
+		}
+	}
+	checkContainers := func(containersPath *field.Path, containers []core.Container, resource bool) {
+		for i, c := range containers {
+			for j, env := range c.Env {
+				checkValue(containersPath.Index(i).Child("env").Index(j).Child("value"), env.Value, resource)
+			}
+		}
+	}
+
+	checkContainers(field.NewPath("spec", "resources", "containers"), ppl.Spec.Resources.Containers, true)
+
+	for _, s := range ppl.stagePaths() {
+		checkContainers(s.path.Child("spec", "initContainers"), s.stage.Spec.InitContainers, false)
+		checkContainers(s.path.Child("spec", "containers"), s.stage.Spec.Containers, false)
+
+		for _, key := range []string{inResourceEnvAnnotationKey, outResourceEnvAnnotationKey} {
+			envs := annotationToEnv(s.stage.Annotations[key])
+			resources := []string{}
+			for resource := range envs {
+				resources = append(resources, resource)
+			}
+			sort.Strings(resources)
+			for _, resource := range resources {
+				for _, env := range envs[resource] {
+					checkValue(s.annotation(key), env.Value, true)
+				}
+			}
+		}
+	}
+
+	valLog.Info("validated validReferences", "pipeline", ppl.Name)
+	return errs
+}
+
 func (ppl Pipeline) allPods() []core.Pod {
 	pods := ppl.Spec.Stages
 
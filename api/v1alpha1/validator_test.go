@@ -16,22 +16,32 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
+	admissionv1 "k8s.io/api/admission/v1"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/kesselborn/jindra/resources"
 )
 
 func TestTriggerInResourceExists(t *testing.T) {
 	ppl := getExamplePipeline(t)
 	ppl.Annotations[imagePullPolicyAnnotationKey] = "xxx"
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := errors.New("invalid pull policy 'xxx'")
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New(`metadata.annotations[jindra.io/image-pull-policy]: Unsupported value: "xxx": supported values: "Always", "IfNotPresent", "Never"`)
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "pull policy must be empty, Always, IfNotPresent oder Never", errMsg(t, expected.Error(), err.Error()))
 	}
 
@@ -44,10 +54,10 @@ func TestInvalidPullPolicy(t *testing.T) {
 		Schedule: "* * * * *",
 	})
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := errors.New("there is no resource for trigger 'xxx'")
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New(`spec.resources.triggers[1].name: Not found: "xxx": there is no resource with this name`)
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "trigger needs a resource", errMsg(t, expected.Error(), err.Error()))
 	}
 
@@ -60,10 +70,10 @@ func TestTriggerIsInResourceOfFirstStage(t *testing.T) {
 		Schedule: "* * * * *",
 	})
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := errors.New("invalid trigger 'slack': every trigger needs to be an input resource of the first stage")
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New(`spec.resources.triggers[1].name: Invalid value: "slack": every trigger needs to be an input resource of the first stage`)
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "trigger needs to be an in-resource of stage 1", errMsg(t, expected.Error(), err.Error()))
 	}
 }
@@ -72,22 +82,22 @@ func TestNoDuplicateInputs(t *testing.T) {
 	ppl := getExamplePipeline(t)
 	ppl.Spec.Stages[0].Annotations[inResourceAnnotationKey] = "slack,git,slack"
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := errors.New("stage 'build-go-binary' uses the input resource 'slack' twice")
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New(`spec.stages[0].metadata.annotations[jindra.io/inputs]: Duplicate value: "slack"`)
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "input resources must not have duplicates", errMsg(t, expected.Error(), err.Error()))
 	}
 }
 
 func TestNoDuplicateOutputs(t *testing.T) {
 	ppl := getExamplePipeline(t)
-	ppl.Spec.Stages[0].Annotations[outResourceAnnotationKey] = "slack,git,slack"
+	ppl.Spec.Stages[0].Annotations[outResourceAnnotationKey] = "slack,git,slack,transit"
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := errors.New("stage 'build-go-binary' uses the output resource 'slack' twice")
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New(`spec.stages[0].metadata.annotations[jindra.io/outputs]: Duplicate value: "slack"`)
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "input resources must not have duplicates", errMsg(t, expected.Error(), err.Error()))
 	}
 }
@@ -96,10 +106,13 @@ func TestNoDuplicateResourceName(t *testing.T) {
 	ppl := getExamplePipeline(t)
 	ppl.Spec.Resources.Containers[2].Name = ppl.Spec.Resources.Containers[0].Name
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := fmt.Errorf("resource name '%s' is used twice", ppl.Spec.Resources.Containers[0].Name)
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New(`[spec.resources.containers[2].name: Duplicate value: "git", ` +
+		`spec.onSuccess.metadata.annotations[jindra.io/outputs]: Not found: "slack": there is no resource with this name, ` +
+		`spec.onError.metadata.annotations[jindra.io/outputs]: Not found: "slack": there is no resource with this name, ` +
+		`spec.final.metadata.annotations[jindra.io/outputs]: Not found: "slack": there is no resource with this name]`)
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "resoure names must not be used twice", errMsg(t, expected.Error(), err.Error()))
 	}
 }
@@ -108,34 +121,34 @@ func TestInResourcesExist(t *testing.T) {
 	ppl := getExamplePipeline(t)
 	ppl.Spec.Stages[0].Annotations[inResourceAnnotationKey] = "git,xxx"
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := fmt.Errorf("input resource '%s' referenced in stage '%s' does not exist", "xxx", ppl.Spec.Stages[0].Name)
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New(`spec.stages[0].metadata.annotations[jindra.io/inputs]: Not found: "xxx": there is no resource with this name`)
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "in resource exists", errMsg(t, expected.Error(), err.Error()))
 	}
 }
 
 func TestOutResourcesExist(t *testing.T) {
 	ppl := getExamplePipeline(t)
-	ppl.Spec.Stages[1].Annotations[outResourceAnnotationKey] = "git,xxx"
+	ppl.Spec.Stages[1].Annotations[outResourceAnnotationKey] += ",xxx"
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := fmt.Errorf("output resource '%s' referenced in stage '%s' does not exist", "xxx", ppl.Spec.Stages[1].Name)
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New(`spec.stages[1].metadata.annotations[jindra.io/outputs]: Not found: "xxx": there is no resource with this name`)
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "out resource exists", errMsg(t, expected.Error(), err.Error()))
 	}
 }
 
 func TestOnSuccessOutResourceExists(t *testing.T) {
 	ppl := getExamplePipeline(t)
-	ppl.Spec.OnSuccess.Annotations[outResourceAnnotationKey] = "git,xxx"
+	ppl.Spec.OnSuccess.Annotations[outResourceAnnotationKey] += ",xxx"
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := fmt.Errorf("output resource '%s' referenced in stage '%s' does not exist", "xxx", ppl.Spec.OnSuccess.Name)
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New(`spec.onSuccess.metadata.annotations[jindra.io/outputs]: Not found: "xxx": there is no resource with this name`)
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "out resource exists", errMsg(t, expected.Error(), err.Error()))
 	}
 }
@@ -145,10 +158,10 @@ func TestServiceExists(t *testing.T) {
 	ppl.Spec.Stages[0].Spec.Containers = append(ppl.Spec.Stages[0].Spec.Containers, ppl.Spec.Stages[1].Spec.Containers...)
 	ppl.Spec.Stages[0].Annotations[servicesAnnotationKey] = "build-go-binary,xxx"
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := fmt.Errorf("service container '%s' referenced in stage '%s' does not exist", "xxx", ppl.Spec.Stages[0].Name)
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New(`spec.stages[0].metadata.annotations[jindra.io/services]: Not found: "xxx": there is no container with this name`)
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "service exists", errMsg(t, expected.Error(), err.Error()))
 	}
 }
@@ -159,10 +172,10 @@ func TestNoOwnerReferences(t *testing.T) {
 		{Name: "foo"},
 	}
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := fmt.Errorf("stage '%s' must not have an owner reference", ppl.Spec.Stages[0].Name)
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New("spec.stages[0].metadata.ownerReferences: Forbidden: stages must not have owner references")
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "no owner reference", errMsg(t, expected.Error(), err.Error()))
 	}
 }
@@ -171,10 +184,664 @@ func TestRestartPolicyIsNeverOrNotSet(t *testing.T) {
 	ppl := getExamplePipeline(t)
 	ppl.Spec.Stages[0].Spec.RestartPolicy = "Always"
 
-	err := emptyErrorWrapper(ppl.Validate())
-	expected := fmt.Errorf(`restartPolicy of stage '%s' must not be set or set to "Never"`, ppl.Spec.Stages[0].Name)
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	expected := errors.New(`spec.stages[0].spec.restartPolicy: Unsupported value: "Always": supported values: "Never"`)
 
-	if !reflect.DeepEqual(expected, err) {
+	if expected.Error() != err.Error() {
 		t.Fatalf("\t%2d: %-80s %s", 0, "restartPolicy must be never or empty", errMsg(t, expected.Error(), err.Error()))
 	}
 }
+
+func TestResourceOptionsAnnotation(t *testing.T) {
+	for i, test := range []struct {
+		resource   string
+		annotation string
+		expected   error
+		desc       string
+	}{
+		{"git", "timeout=5m\nretries=3\nretry-backoff=10s", errors.New("<nil>"), "valid options"},
+		{"transit", "retries=3", errors.New("<nil>"), "transit channels have options"},
+		{"xxx", "timeout=5m", errors.New(`metadata.annotations[jindra.io/resource-options.xxx]: Not found: "xxx": there is no resource with this name`), "resource must exist"},
+		{"git", "timout=5m", errors.New(`metadata.annotations[jindra.io/resource-options.git]: Unsupported value: "timout": supported values: "timeout", "retries", "retry-backoff"`), "option must be known"},
+		{"git", "retries=-1", errors.New(`metadata.annotations[jindra.io/resource-options.git]: Invalid value: "retries=-1": strconv.ParseUint: parsing "-1": invalid syntax`), "retries must be a positive number"},
+		{"git", "timeout=5", errors.New(`metadata.annotations[jindra.io/resource-options.git]: Invalid value: "timeout=5": time: missing unit in duration "5"`), "timeout must be a duration"},
+	} {
+		ppl := getExamplePipeline(t)
+		ppl.Annotations[resourceOptionsAnnotationKeyPrefix+test.resource] = test.annotation
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestNativeResourceExists(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Spec.Resources.Containers[0].Image = "native:xxx"
+	defer SetNativeResourceRegistry(nil)
+
+	for i, test := range []struct {
+		registry NativeResourceRegistry
+		expected error
+		desc     string
+	}{
+		{resources.Registry{}, errors.New(`spec.resources.containers[0].image: Unsupported value: "native:xxx": supported values: "native:git", "native:http", "native:s3", "native:time"`),
+			"native resource must exist"},
+		{nil, errors.New("<nil>"), "native resources are not checked without a registry"},
+	} {
+		SetNativeResourceRegistry(test.registry)
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+
+		if test.expected.Error() != err.Error() {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestRequiredResourceParams(t *testing.T) {
+	for i, test := range []struct {
+		modify   func(ppl *Pipeline)
+		expected error
+		desc     string
+	}{
+		{func(ppl *Pipeline) {}, errors.New("<nil>"), "all required params set"},
+		{func(ppl *Pipeline) { ppl.Spec.Resources.Containers[0].Env = nil },
+			errors.New("spec.stages[0].metadata.annotations[jindra.io/inputs-envs]: Required value: resource 'git' of type 'type:github' needs the parameter 'source.uri'"),
+			"required param must be set"},
+		{func(ppl *Pipeline) {
+			ppl.Spec.Resources.Containers[0].Env = nil
+			ppl.Spec.Stages[0].Annotations[inResourceEnvAnnotationKey] = "git.source.uri=git@github.com:kesselborn/http-fs"
+		}, errors.New("<nil>"), "required param can be set via stage annotation"},
+		{func(ppl *Pipeline) {
+			ppl.Spec.Resources.Containers[2].Env = ppl.Spec.Resources.Containers[2].Env[:1]
+			ppl.Spec.OnSuccess.Annotations[outResourceEnvAnnotationKey] += "\nslack.params.channel=jindra"
+		}, errors.New("[spec.onError.metadata.annotations[jindra.io/outputs-envs]: Required value: resource 'slack' of type 'clustertype:slack' needs the parameter 'params.channel', " +
+			"spec.final.metadata.annotations[jindra.io/outputs-envs]: Required value: resource 'slack' of type 'clustertype:slack' needs the parameter 'params.channel']"),
+			"required param must be set in every stage using the resource"},
+		{func(ppl *Pipeline) { ppl.ResourceTypes = nil },
+			errors.New(`[spec.resources.containers[0].image: Invalid value: "type:github": resource type 'type:github' of resource 'git' has not been resolved, ` +
+				`spec.resources.containers[2].image: Invalid value: "clustertype:slack": resource type 'clustertype:slack' of resource 'slack' has not been resolved]`),
+			"resource types must be resolved"},
+	} {
+		ppl := getExamplePipelineWithResourceTypes(t)
+		test.modify(&ppl)
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestTransit(t *testing.T) {
+	for i, test := range []struct {
+		transit  Transit
+		expected error
+		desc     string
+	}{
+		{Transit{}, errors.New("<nil>"), "rsync is the default"},
+		{Transit{Backend: "pvc", PVC: &PVCTransit{}}, errors.New("<nil>"), "pvc backend"},
+		{Transit{Backend: "nfs"}, errors.New(`spec.transit.backend: Unsupported value: "nfs": supported values: "rsync", "pvc", "s3"`), "backend must be known"},
+		{Transit{Backend: "rsync", PVC: &PVCTransit{}}, errors.New("spec.transit.pvc: Forbidden: must only be set for transit backend 'pvc'"), "pvc config only for pvc backend"},
+		{Transit{Rsync: &RsyncTransit{PerStageKeys: true}}, errors.New("<nil>"), "rsync config for default backend"},
+		{Transit{Backend: "pvc", Rsync: &RsyncTransit{}}, errors.New("spec.transit.rsync: Forbidden: must only be set for transit backend 'rsync'"), "rsync config only for rsync backend"},
+		{Transit{Backend: "s3", S3: &S3Transit{Endpoint: "http://minio:9000", Bucket: "jindra"}},
+			errors.New("spec.transit.s3.credentialsSecret: Required value: transit backend 's3' needs credentialsSecret"), "s3 needs credentials"},
+	} {
+		ppl := getExamplePipeline(t)
+		ppl.Spec.Transit = test.transit
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestTransitChannelNames(t *testing.T) {
+	for i, test := range []struct {
+		outputs  string
+		expected error
+		desc     string
+	}{
+		{"transit:binaries", errors.New("<nil>"), "valid channel"},
+		{"transit:Binaries", errors.New("spec.stages[0].metadata.annotations[jindra.io/outputs]: Invalid value: \"transit:Binaries\": a DNS-1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')"), "channel must be a dns label"},
+		{"transit:git", errors.New("<nil>"), "channel may have the name of a resource"},
+	} {
+		ppl := getExamplePipeline(t)
+		ppl.Spec.Stages[0].Annotations[outResourceAnnotationKey] = test.outputs
+		ppl.Spec.Stages[1].Annotations[inResourceAnnotationKey] = test.outputs
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+
+	ppl := getExamplePipeline(t)
+	ppl.Spec.Resources.Containers[0].Name = "transit-binaries"
+	ppl.Spec.Stages[0].Annotations[inResourceAnnotationKey] = "transit-binaries"
+	ppl.Spec.Stages[0].Annotations[outResourceAnnotationKey] = "transit:binaries"
+	ppl.Spec.Stages[1].Annotations[inResourceAnnotationKey] = "transit:binaries"
+	ppl.Spec.Resources.Triggers = nil
+
+	err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+	if expected := `spec.stages[0].metadata.annotations[jindra.io/outputs]: Invalid value: "transit:binaries": transit channel conflicts with resource 'transit-binaries'`; err.Error() != expected {
+		t.Fatalf("\t%2d: %-80s %s", 3, "channel directory must not clash with a resource", errMsg(t, expected, err.Error()))
+	}
+}
+
+func TestTransitDataflow(t *testing.T) {
+	for i, test := range []struct {
+		annotations map[string]map[string]string // stage -> annotation -> value
+		expected    error
+		desc        string
+	}{
+		{map[string]map[string]string{}, errors.New("<nil>"), "example pipeline"},
+		{map[string]map[string]string{"stage0": {outResourceAnnotationKey: "transit,transit:reports"}, "onSuccess": {inResourceAnnotationKey: "transit,transit:reports"}},
+			errors.New("<nil>"), "channel read by onSuccess"},
+		{map[string]map[string]string{"stage0": {outResourceAnnotationKey: "transit,transit:reports"}, "final": {inResourceAnnotationKey: "transit:reports"}},
+			errors.New(`spec.final.metadata.annotations[jindra.io/inputs]: Invalid value: "transit:reports": transit channel might not exist as final also runs if a stage failed -- stages only write their outputs if they succeed`), "final can't rely on stage outputs"},
+		{map[string]map[string]string{"stage1": {inResourceAnnotationKey: "transit:binaries"}},
+			errors.New(`spec.stages[1].metadata.annotations[jindra.io/inputs]: Not found: "transit:binaries": there is no stage with this transit channel as output`), "input without producer"},
+		{map[string]map[string]string{"stage0": {inResourceAnnotationKey: "git,transit", outResourceAnnotationKey: ""}},
+			errors.New(`[spec.stages[0].metadata.annotations[jindra.io/inputs]: Invalid value: "transit": transit channel is not an output of an earlier stage, spec.stages[1].metadata.annotations[jindra.io/inputs]: Invalid value: "transit": transit channel is not an output of an earlier stage]`), "input only produced by the reading and later stages"},
+		{map[string]map[string]string{"onSuccess": {inResourceAnnotationKey: ""}, "onError": {inResourceAnnotationKey: "transit"}},
+			errors.New(`spec.onError.metadata.annotations[jindra.io/inputs]: Invalid value: "transit": transit channel might not exist as onError runs after the first failing stage -- stages only write their outputs if they succeed`), "onError can't rely on stage outputs"},
+		{map[string]map[string]string{"onError": {outResourceAnnotationKey: "slack,transit:report"}, "final": {inResourceAnnotationKey: "transit:report"}},
+			errors.New(`spec.final.metadata.annotations[jindra.io/inputs]: Invalid value: "transit:report": transit channel might not exist as final also runs if a stage failed -- stages only write their outputs if they succeed`), "final can't rely on onError outputs"},
+	} {
+		ppl := getExamplePipeline(t)
+		stages := map[string]*core.Pod{"stage0": &ppl.Spec.Stages[0], "stage1": &ppl.Spec.Stages[1], "onSuccess": ppl.Spec.OnSuccess, "onError": ppl.Spec.OnError, "final": ppl.Spec.Final}
+		for stage, annotations := range test.annotations {
+			for k, v := range annotations {
+				stages[stage].Annotations[k] = v
+			}
+		}
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestTransitDataflowWarnings(t *testing.T) {
+	for i, test := range []struct {
+		annotations map[string]map[string]string // stage -> annotation -> value
+		expected    []string
+		desc        string
+	}{
+		{map[string]map[string]string{}, []string{}, "example pipeline"},
+		{map[string]map[string]string{"onSuccess": {inResourceAnnotationKey: ""}},
+			[]string{`spec.stages[1].metadata.annotations[jindra.io/outputs]: Invalid value: "transit": transit channel is not an input of any stage that runs later`}, "output never read"},
+		{map[string]map[string]string{"onSuccess": {outResourceAnnotationKey: "slack,transit:report"}},
+			[]string{`spec.onSuccess.metadata.annotations[jindra.io/outputs]: Invalid value: "transit:report": transit channel is not an input of any stage that runs later`}, "onSuccess output never read"},
+		{map[string]map[string]string{"final": {outResourceAnnotationKey: "slack,transit:report", artifactsAnnotationKey: "/jindra/resources/transit-report"}},
+			[]string{`spec.final.metadata.annotations[jindra.io/outputs]: Invalid value: "transit:report": transit channel is not an input of any stage that runs later`}, "final output only written for its artifacts"},
+	} {
+		ppl := getExamplePipeline(t)
+		stages := map[string]*core.Pod{"stage0": &ppl.Spec.Stages[0], "stage1": &ppl.Spec.Stages[1], "onSuccess": ppl.Spec.OnSuccess, "onError": ppl.Spec.OnError, "final": ppl.Spec.Final}
+		for stage, annotations := range test.annotations {
+			for k, v := range annotations {
+				stages[stage].Annotations[k] = v
+			}
+		}
+
+		// unread channels are no errors: pipelines that write them are valid
+		if err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate()); err.Error() != "<nil>" {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, "<nil>", err.Error()))
+		}
+		if warnings := ppl.ValidationWarnings(); !reflect.DeepEqual(test.expected, warnings) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected, warnings))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestCacheValidation(t *testing.T) {
+	goMod := Cache{Name: "go-mod", Path: "/go/pkg/mod", KeyFiles: []string{"git/go.sum"}}
+	for i, test := range []struct {
+		caches   []Cache
+		store    ObjectStore
+		uses     string
+		expected error
+		desc     string
+	}{
+		{[]Cache{goMod}, ObjectStore{}, "go-mod", errors.New("<nil>"), "pvc is the default store"},
+		{[]Cache{goMod}, ObjectStore{}, "npm", errors.New(`spec.stages[0].metadata.annotations[jindra.io/caches]: Not found: "npm": cache is not defined in spec.caches`), "used caches must be defined"},
+		{[]Cache{goMod, goMod}, ObjectStore{}, "", errors.New(`spec.caches[1].name: Duplicate value: "go-mod"`), "cache names must be unique"},
+		{[]Cache{{Name: "Go", Path: "/go"}}, ObjectStore{}, "", errors.New("spec.caches[0].name: Invalid value: \"Go\": a DNS-1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')"), "cache name must be a dns label"},
+		{[]Cache{{Name: strings.Repeat("a", 43), Path: "/go"}}, ObjectStore{}, "", fmt.Errorf("spec.caches[0].name: Invalid value: \"%s\": must be no more than 42 characters", strings.Repeat("a", 43)), "cache name must fit into the container name"},
+		{[]Cache{{Name: "go-mod", Path: "go/pkg/mod"}}, ObjectStore{}, "", errors.New(`spec.caches[0].path: Invalid value: "go/pkg/mod": must be absolute`), "path must be absolute"},
+		{[]Cache{{Name: "go-mod", Path: "/go", KeyFiles: []string{"../go.sum"}}}, ObjectStore{}, "", errors.New(`spec.caches[0].keyFiles[0]: Invalid value: "../go.sum": must be relative to /jindra/resources`), "key files must be below the resources"},
+		{[]Cache{goMod}, ObjectStore{Backend: "nfs"}, "", errors.New(`spec.cacheStore.backend: Unsupported value: "nfs": supported values: "pvc", "s3"`), "backend must be known"},
+		{[]Cache{goMod}, ObjectStore{Backend: "s3", ClaimName: "caches"}, "", errors.New("[spec.cacheStore.claimName: Forbidden: must only be set for backend 'pvc', spec.cacheStore.s3: Required value: backend 's3' needs endpoint, bucket and credentialsSecret]"), "claim name only for pvc backend"},
+		{[]Cache{goMod}, ObjectStore{Backend: "s3", S3: &S3Transit{Endpoint: "http://minio:9000", Bucket: "jindra"}},
+			"", errors.New("spec.cacheStore.s3.credentialsSecret: Required value: backend 's3' needs credentialsSecret"), "s3 needs credentials"},
+	} {
+		ppl := getExamplePipeline(t)
+		ppl.Spec.Caches = test.caches
+		ppl.Spec.CacheStore = test.store
+		if test.uses != "" {
+			ppl.Spec.Stages[0].Annotations[cachesAnnotationKey] = test.uses
+		}
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestArtifactValidation(t *testing.T) {
+	for i, test := range []struct {
+		artifacts string
+		config    Artifacts
+		expected  error
+		desc      string
+	}{
+		{"/jindra/resources/transit/http-fs-linux", Artifacts{}, errors.New("<nil>"), "artifact below an output"},
+		{"/jindra/resources/git/reports", Artifacts{Store: ObjectStore{Backend: "s3"}}, errors.New("spec.artifacts.store.s3: Required value: backend 's3' needs endpoint, bucket and credentialsSecret"), "store is validated"},
+		{"/tmp/reports", Artifacts{}, errors.New(`spec.stages[0].metadata.annotations[jindra.io/artifacts]: Invalid value: "/tmp/reports": must be a path below /jindra/resources`), "artifacts must be below the resources"},
+		{"transit/reports", Artifacts{}, errors.New(`spec.stages[0].metadata.annotations[jindra.io/artifacts]: Invalid value: "transit/reports": must be a path below /jindra/resources`), "artifacts must be absolute"},
+		{"/jindra/resources/slack/x", Artifacts{}, errors.New(`spec.stages[0].metadata.annotations[jindra.io/artifacts]: Invalid value: "/jindra/resources/slack/x": must be below a resource of the stage ('slack' is neither an input nor an output)`), "artifacts must be below a resource of the stage"},
+		{"", Artifacts{KeepRuns: -1}, errors.New("spec.artifacts.keepRuns: Invalid value: -1: must not be negative"), "keepRuns must not be negative"},
+	} {
+		ppl := getExamplePipeline(t)
+		ppl.Spec.Artifacts = test.config
+		ppl.Spec.Stages[0].Annotations[artifactsAnnotationKey] = test.artifacts
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestVariableValidation(t *testing.T) {
+	registry := Variable{Name: "registry", Value: "registry.example.com"}
+	password := Secret{Name: "registry-password", SecretKeyRef: &core.SecretKeySelector{LocalObjectReference: core.LocalObjectReference{Name: "dockerhub"}, Key: "password"}}
+	for i, test := range []struct {
+		variables []Variable
+		secrets   []Secret
+		outputs   string
+		expected  error
+		desc      string
+	}{
+		{[]Variable{registry}, []Secret{password}, "registry-image.source.repository=((registry))/jindra\nregistry-image.source.password=((registry-password))", errors.New("<nil>"), "variables and secrets are resolvable"},
+		{[]Variable{registry}, nil, "registry-image.source.repository=(( registry ))", errors.New("<nil>"), "references may contain spaces"},
+		{nil, nil, "registry-image.source.repository=((registry))/jindra", errors.New(`spec.stages[1].metadata.annotations[jindra.io/outputs-envs]: Invalid value: "((registry))/jindra": undefined variable or secret '((registry))'`), "references must be defined"},
+		{nil, []Secret{password}, "registry-image.source.password=pre-((registry-password))", errors.New(`spec.stages[1].metadata.annotations[jindra.io/outputs-envs]: Invalid value: "pre-((registry-password))": secret '((registry-password))' must be the whole value`), "secrets must be the whole value"},
+		{[]Variable{registry, registry}, nil, "", errors.New(`spec.variables[1].name: Duplicate value: "registry"`), "names must be unique"},
+		{[]Variable{{Name: "registry-password"}}, []Secret{password}, "", errors.New(`spec.secrets[0].name: Duplicate value: "registry-password"`), "names must be unique across variables and secrets"},
+		{[]Variable{{Name: "my registry"}}, nil, "", errors.New("spec.variables[0].name: Invalid value: \"my registry\": a valid config key must consist of alphanumeric characters, '-', '_' or '.' (e.g. 'key.name',  or 'KEY_NAME',  or 'key-name', regex used for validation is '[-._a-zA-Z0-9]+')"), "names must be valid keys"},
+		{nil, []Secret{{Name: "token"}}, "", errors.New("spec.secrets[0].secretKeyRef: Required value: secrets need secretKeyRef.name and secretKeyRef.key"), "secrets need a secret key ref"},
+	} {
+		ppl := getExamplePipeline(t)
+		ppl.Spec.Variables = test.variables
+		ppl.Spec.Secrets = test.secrets
+		if test.outputs != "" {
+			ppl.Spec.Stages[1].Annotations[outResourceEnvAnnotationKey] = test.outputs
+		}
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+
+	ppl := getExamplePipeline(t)
+	ppl.Spec.Stages[0].Spec.Containers[0].Env = []core.EnvVar{{Name: "GOPROXY", Value: "((goproxy))"}}
+	if err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate()); err.Error() != `spec.stages[0].spec.containers[0].env[0].value: Invalid value: "((goproxy))": undefined variable or secret '((goproxy))'` {
+		t.Fatalf("\t%2d: %-80s %s", 8, "references in stage containers must be defined", err)
+	}
+}
+
+func TestSecretProviderValidation(t *testing.T) {
+	vault := &VaultProvider{Address: "https://vault:8200", Role: "jindra"}
+	password := Secret{Name: "registry-password", Vault: &VaultSecret{Path: "secret/ci/dockerhub", Key: "password"}}
+	for i, test := range []struct {
+		provider SecretProvider
+		secrets  []Secret
+		env      string
+		expected error
+		desc     string
+	}{
+		{SecretProvider{Backend: "vault", Vault: vault}, []Secret{password}, "", errors.New("<nil>"), "vault secrets in resource env"},
+		{SecretProvider{Backend: "aws"}, nil, "", errors.New(`spec.secretProvider.backend: Unsupported value: "aws": supported values: "kubernetes", "vault"`), "provider must be known"},
+		{SecretProvider{Vault: vault}, nil, "", errors.New("spec.secretProvider.vault: Forbidden: must only be set for secret provider 'vault'"), "vault config only for vault provider"},
+		{SecretProvider{Backend: "vault", Vault: &VaultProvider{Address: "https://vault:8200"}}, nil, "", errors.New("spec.secretProvider.vault.role: Required value: secret provider 'vault' needs the role of jindra in vault"), "vault needs a role"},
+		{SecretProvider{Backend: "vault", Vault: &VaultProvider{Address: "https://vault:8200", Role: "jindra", KVVersion: 3}}, nil, "", errors.New(`spec.secretProvider.vault.kvVersion: Unsupported value: 3: supported values: "1", "2"`), "kv version must be known"},
+		{SecretProvider{Backend: "vault", Vault: vault}, []Secret{{Name: "registry-password", SecretKeyRef: &core.SecretKeySelector{Key: "password"}}}, "", errors.New("spec.secrets[0].vault: Required value: secrets of secret provider 'vault' need vault.path and vault.key"), "secrets need a vault path"},
+		{SecretProvider{}, []Secret{password}, "", errors.New("spec.secrets[0].secretKeyRef: Required value: secrets need secretKeyRef.name and secretKeyRef.key"), "kubernetes is the default provider"},
+		{SecretProvider{Backend: "vault", Vault: vault}, []Secret{password}, "((registry-password))", errors.New("spec.stages[0].spec.containers[0].env[0].value: Forbidden: secret '((registry-password))' of secret provider 'vault' can only be used in env vars of resources"), "vault secrets only in resource env"},
+	} {
+		ppl := getExamplePipeline(t)
+		ppl.Spec.SecretProvider = test.provider
+		ppl.Spec.Secrets = test.secrets
+		ppl.Spec.Resources.Containers[1].Env[4].ValueFrom = nil
+		ppl.Spec.Resources.Containers[1].Env[4].Value = "((registry-password))"
+		if test.secrets == nil {
+			ppl.Spec.Resources.Containers[1].Env[4].Value = "password"
+		}
+		if test.env != "" {
+			ppl.Spec.Stages[0].Spec.Containers[0].Env = []core.EnvVar{{Name: "PASSWORD", Value: test.env}}
+		}
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestPodSecurityValidation(t *testing.T) {
+	privileged := true
+	for i, test := range []struct {
+		pipeline func(*testing.T) Pipeline
+		security string
+		stage    func(*core.Pod)
+		expected error
+		desc     string
+	}{
+		{getRestrictedExamplePipeline, podSecurityRestricted, func(*core.Pod) {}, errors.New("<nil>"), "compliant stages pass restricted pod security"},
+		{getExamplePipeline, "", func(*core.Pod) {}, errors.New("<nil>"), "violations are no error by default"},
+		{getExamplePipeline, podSecurityRelaxed, func(*core.Pod) {}, errors.New("<nil>"), "violations are no error with relaxed pod security"},
+		{getExamplePipeline, "strict", func(*core.Pod) {}, errors.New(`metadata.annotations[jindra.io/pod-security]: Unsupported value: "strict": supported values: "relaxed", "restricted"`), "pod security must be known"},
+		{getRestrictedExamplePipeline, podSecurityRestricted, func(p *core.Pod) {
+			p.Spec.Containers[0].SecurityContext.Privileged = &privileged
+			p.Spec.Containers[0].SecurityContext.Capabilities.Add = []core.Capability{"SYS_ADMIN"}
+		}, errors.New("spec.stages[0]: Forbidden: violates the restricted pod security standard: container 'build-go-binary': must not be privileged, container 'build-go-binary': capability SYS_ADMIN must not be added"), "violating stages are reported"},
+		{getRestrictedExamplePipeline, podSecurityRestricted, func(p *core.Pod) {
+			p.Spec.HostNetwork = true
+		}, errors.New("spec.stages[0]: Forbidden: violates the restricted pod security standard: host namespaces must not be used"), "generated containers don't violate the standard"},
+	} {
+		ppl := test.pipeline(t)
+		ppl.Annotations[podSecurityAnnotationKey] = test.security
+		test.stage(&ppl.Spec.Stages[0])
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestStageServiceAccountValidation(t *testing.T) {
+	for i, test := range []struct {
+		name     string
+		expected error
+		desc     string
+	}{
+		{"", errors.New("<nil>"), "stage service account is optional"},
+		{"ci-builds", errors.New("<nil>"), "valid service account name"},
+		{"CI_builds", errors.New("spec.stageServiceAccountName: Invalid value: \"CI_builds\": a DNS-1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')"), "service account name must be a dns subdomain"},
+	} {
+		ppl := getExamplePipeline(t)
+		ppl.Spec.StageServiceAccountName = test.name
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestAllValidationErrors(t *testing.T) {
+	ppl := getExamplePipeline(t)
+	ppl.Annotations[imagePullPolicyAnnotationKey] = "xxx"
+	ppl.Spec.Stages[0].Spec.RestartPolicy = "Always"
+	ppl.Spec.Stages[1].Annotations[inResourceAnnotationKey] += ",xxx"
+
+	err := ppl.Validate()
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("\t%2d: %-80s %s", 0, "validation errors are api errors with reason Invalid", errMsg(t, "StatusReasonInvalid", fmt.Sprintf("%#v", err)))
+	}
+	t.Logf("\t%2d: %-80s %s", 0, "validation errors are api errors with reason Invalid", ok())
+
+	causes := []string{}
+	for _, cause := range err.(apierrors.APIStatus).Status().Details.Causes {
+		causes = append(causes, fmt.Sprintf("%s: %s", cause.Field, cause.Type))
+	}
+	expected := []string{
+		"spec.stages[0].spec.restartPolicy: FieldValueNotSupported",
+		"spec.stages[1].metadata.annotations[jindra.io/inputs]: FieldValueNotFound",
+		"metadata.annotations[jindra.io/image-pull-policy]: FieldValueNotSupported",
+	}
+	if !reflect.DeepEqual(expected, causes) {
+		t.Fatalf("\t%2d: %-80s %s", 1, "all errors are reported with their field paths", errMsg(t, expected, causes))
+	}
+	t.Logf("\t%2d: %-80s %s", 1, "all errors are reported with their field paths", ok())
+}
+
+func TestValidatingWebhookResponse(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatalf("error creating scheme: %s", err)
+	}
+	decoder, err := admission.NewDecoder(scheme)
+	if err != nil {
+		t.Fatalf("error creating decoder: %s", err)
+	}
+	handler := &validatingHandler{}
+	handler.InjectDecoder(decoder)
+
+	ppl := getExamplePipeline(t)
+	ppl.Spec.Stages[0].Spec.RestartPolicy = "Always"
+	ppl.Spec.Stages[1].Annotations[inResourceAnnotationKey] += ",xxx"
+	raw, err := json.Marshal(ppl)
+	if err != nil {
+		t.Fatalf("error marshalling pipeline: %s", err)
+	}
+
+	resp := handler.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}})
+
+	if resp.Allowed || resp.Result.Reason != metav1.StatusReasonInvalid {
+		t.Fatalf("\t%2d: %-80s %s", 0, "invalid pipelines are denied with reason Invalid", errMsg(t, metav1.StatusReasonInvalid, resp.Result))
+	}
+	t.Logf("\t%2d: %-80s %s", 0, "invalid pipelines are denied with reason Invalid", ok())
+
+	if len(resp.Result.Details.Causes) != 2 {
+		t.Fatalf("\t%2d: %-80s %s", 1, "the response contains all errors", errMsg(t, 2, resp.Result.Details.Causes))
+	}
+	t.Logf("\t%2d: %-80s %s", 1, "the response contains all errors", ok())
+
+	ppl = getExamplePipeline(t)
+	ppl.Spec.Final.Annotations[outResourceAnnotationKey] = "slack,transit:report"
+	raw, err = json.Marshal(ppl)
+	if err != nil {
+		t.Fatalf("error marshalling pipeline: %s", err)
+	}
+
+	resp = handler.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}})
+
+	if !resp.Allowed || len(resp.Warnings) != 1 {
+		t.Fatalf("\t%2d: %-80s %s", 2, "pipelines with unread transit channels are allowed with a warning", errMsg(t, 1, resp.Warnings))
+	}
+	t.Logf("\t%2d: %-80s %s", 2, "pipelines with unread transit channels are allowed with a warning", ok())
+}
+
+// staticRunLister is a RunLister with fixed active runs
+type staticRunLister []int
+
+func (l staticRunLister) ActiveRuns(ppl Pipeline) ([]int, error) {
+	return l, nil
+}
+
+func TestUpdateValidation(t *testing.T) {
+	defer func() { runLister = nil }()
+
+	for i, test := range []struct {
+		activeRuns []int
+		update     func(old, ppl *Pipeline)
+		expected   error
+		desc       string
+	}{
+		{[]int{41, 42}, func(_, p *Pipeline) { p.Spec.Stages[0].Spec.Containers[0].Image = "golang:1.14" }, errors.New("<nil>"), "stage changes apply to the next run"},
+		{[]int{}, func(_, p *Pipeline) { p.Annotations[buildNoOffsetAnnotationKey] = "100" }, errors.New("<nil>"), "build number offset without active runs"},
+		{[]int{41, 42}, func(_, p *Pipeline) { p.Annotations[buildNoOffsetAnnotationKey] = "100" },
+			errors.New(`Pipeline.ci.jindra.io "http-fs" is invalid: metadata.annotations[jindra.io/build-no-offset]: Forbidden: must not be changed while runs are active (41, 42)`), "build number offset with active runs"},
+		{[]int{}, func(_, p *Pipeline) { p.Spec.Stages[0].Spec.RestartPolicy = "Always" },
+			errors.New(`Pipeline.ci.jindra.io "http-fs" is invalid: spec.stages[0].spec.restartPolicy: Unsupported value: "Always": supported values: "Never"`), "updates are validated"},
+		{[]int{42}, func(old, p *Pipeline) {
+			old.Spec.Stages[0].Spec.RestartPolicy = "Always"
+			p.Spec.Stages[0].Spec.RestartPolicy = "Always"
+			p.Finalizers = []string{}
+		}, errors.New("<nil>"), "finalizer changes of invalid pipelines"},
+		{[]int{42}, func(_, p *Pipeline) { p.DeletionTimestamp = &metav1.Time{}; p.Finalizers = []string{} }, errors.New("<nil>"), "pipelines that are being deleted"},
+	} {
+		runLister = staticRunLister(test.activeRuns)
+		old := getExamplePipeline(t)
+		old.Finalizers = []string{"jindra.io/cancel-runs"}
+		ppl := old.DeepCopy()
+		test.update(&old, ppl)
+
+		err := emptyErrorWrapper(ppl.ValidateUpdate(&old))
+		if test.expected.Error() != err.Error() {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestTriggerScheduleValidation(t *testing.T) {
+	for i, test := range []struct {
+		schedule string
+		expected error
+		desc     string
+	}{
+		{"*/5 * * * *", errors.New("<nil>"), "steps"},
+		{"0,30 8-18 * jan-jun MON-FRI", errors.New("<nil>"), "lists, ranges and names"},
+		{"@hourly", errors.New("<nil>"), "macros"},
+		{"", errors.New("spec.resources.triggers[0].schedule: Required value: triggers need a cron schedule"), "schedule is required"},
+		{"/5 * * * *", errors.New(`spec.resources.triggers[0].schedule: Invalid value: "/5 * * * *": invalid minute '/5': '' must be a number between 0 and 59`), "step needs a range"},
+		{"* * * *", errors.New(`spec.resources.triggers[0].schedule: Invalid value: "* * * *": expected 5 fields (minute hour day-of-month month day-of-week), found 4`), "five fields"},
+		{"0 24 * * *", errors.New(`spec.resources.triggers[0].schedule: Invalid value: "0 24 * * *": invalid hour '24': '24' must be a number between 0 and 23`), "values must be in range"},
+		{"0 18-8 * * *", errors.New(`spec.resources.triggers[0].schedule: Invalid value: "0 18-8 * * *": invalid hour '18-8': range '18-8' must not end before it starts`), "ranges must be ordered"},
+		{"*/0 * * * *", errors.New(`spec.resources.triggers[0].schedule: Invalid value: "*/0 * * * *": invalid minute '*/0': step '0' must be a positive number`), "steps must be positive"},
+		{"@every5m", errors.New(`spec.resources.triggers[0].schedule: Invalid value: "@every5m": unknown macro '@every5m' (must be one of: @yearly, @annually, @monthly, @weekly, @daily, @midnight, @hourly)`), "macros must be known"},
+	} {
+		ppl := getExamplePipeline(t)
+		ppl.Spec.Resources.Triggers[0].Schedule = test.schedule
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+
+	ppl := getExamplePipeline(t)
+	ppl.Spec.Resources.Triggers[0].Schedule = ""
+	ppl.SetDefaults()
+	if err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate()); err.Error() != "<nil>" {
+		t.Fatalf("\t%2d: %-80s %s", 10, "default schedule is valid", errMsg(t, "<nil>", err.Error()))
+	}
+	t.Logf("\t%2d: %-80s %s", 10, "default schedule is valid", ok())
+}
+
+func TestAnnotationValidation(t *testing.T) {
+	for i, test := range []struct {
+		modify   func(ppl *Pipeline)
+		expected error
+		desc     string
+	}{
+		{func(ppl *Pipeline) {}, errors.New("<nil>"), "example annotations are valid"},
+		{func(ppl *Pipeline) { ppl.Annotations["example.com/jindra.io-inputs"] = "x" }, errors.New("<nil>"), "foreign annotations are ignored"},
+		{func(ppl *Pipeline) { ppl.Spec.Stages[0].Annotations["jindra.io/input"] = "git" },
+			errors.New(`spec.stages[0].metadata.annotations: Invalid value: "jindra.io/input": unknown jindra annotation (did you mean 'jindra.io/inputs'?)`), "unknown keys with suggestion"},
+		{func(ppl *Pipeline) { ppl.Annotations["jindra.io/image-pul-policy"] = "Never" },
+			errors.New(`metadata.annotations: Invalid value: "jindra.io/image-pul-policy": unknown jindra annotation (did you mean 'jindra.io/image-pull-policy'?)`), "unknown pipeline keys with suggestion"},
+		{func(ppl *Pipeline) { ppl.Annotations["jindra.io/foo"] = "bar" },
+			errors.New(`metadata.annotations: Invalid value: "jindra.io/foo": unknown jindra annotation`), "unknown keys without suggestion"},
+		{func(ppl *Pipeline) { ppl.Annotations[inResourceAnnotationKey] = "git" },
+			errors.New(`metadata.annotations: Invalid value: "jindra.io/inputs": must be set on the stages`), "stage annotations on the pipeline"},
+		{func(ppl *Pipeline) { ppl.Spec.Stages[0].Annotations[podSecurityAnnotationKey] = "relaxed" },
+			errors.New(`spec.stages[0].metadata.annotations: Invalid value: "jindra.io/pod-security": must be set on the pipeline`), "pipeline annotations on a stage"},
+		{func(ppl *Pipeline) { ppl.Spec.Stages[0].Annotations[waitForAnnotationKey] = "git" },
+			errors.New("spec.stages[0].metadata.annotations[jindra.io/wait-for]: Forbidden: is set by jindra"), "generated annotations"},
+		{func(ppl *Pipeline) { ppl.Annotations[buildNoOffsetAnnotationKey] = "-1" },
+			errors.New(`metadata.annotations[jindra.io/build-no-offset]: Invalid value: "-1": must be a non-negative number`), "build no offset must be a number"},
+		{func(ppl *Pipeline) {
+			ppl.Annotations[resourceOptionsAnnotationKeyPrefix+"git"] = "retries=3\ntimeout 5m"
+		},
+			errors.New(`metadata.annotations[jindra.io/resource-options.git]: Invalid value: "timeout 5m": lines must have the format '<option>=<value>'`), "resource option syntax"},
+		{func(ppl *Pipeline) {
+			ppl.Spec.Stages[0].Annotations[resourceOptionsAnnotationKeyPrefix+"git"] = "retries=3"
+		},
+			errors.New(`spec.stages[0].metadata.annotations: Invalid value: "jindra.io/resource-options.git": must be set on the pipeline`), "resource options on a stage"},
+		{func(ppl *Pipeline) { ppl.Spec.Stages[0].Annotations[firstInitContainers] = "hello,,kubernetes" },
+			errors.New(`spec.stages[0].metadata.annotations[jindra.io/first-init-containers]: Invalid value: "hello,,kubernetes": must be a comma separated list without spaces or empty entries`), "lists without empty entries"},
+		{func(ppl *Pipeline) { ppl.Spec.Stages[0].Annotations[debugResourcesAnnotationKey] = "true" },
+			errors.New(`spec.stages[0].metadata.annotations[jindra.io/debug-resources]: Unsupported value: "true": supported values: "enable"`), "debug flags must be 'enable'"},
+		{func(ppl *Pipeline) {
+			ppl.Spec.Stages[1].Annotations[outResourceEnvAnnotationKey] = "registry-image.params.image=./image.tar\nregistry-image.tag\nslack.params.text=hi"
+		}, errors.New(`[spec.stages[1].metadata.annotations[jindra.io/outputs-envs]: Invalid value: "registry-image.tag": lines must have the format '<resource>.<param>=<value>', ` +
+			`spec.stages[1].metadata.annotations[jindra.io/outputs-envs]: Not found: "slack": is not an output resource of the stage]`), "env annotation syntax"},
+		{func(ppl *Pipeline) { ppl.Spec.Stages[0].Annotations[firstInitContainers] = "hello,kubernets,hello" },
+			errors.New(`[spec.stages[0].metadata.annotations[jindra.io/first-init-containers]: Not found: "kubernets": there is no init container with this name, ` +
+				`spec.stages[0].metadata.annotations[jindra.io/first-init-containers]: Duplicate value: "hello"]`), "first init containers must exist"},
+	} {
+		ppl := getExamplePipeline(t)
+		test.modify(&ppl)
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestGeneratedObjectValidation(t *testing.T) {
+	for i, test := range []struct {
+		modify   func(ppl *Pipeline)
+		expected error
+		desc     string
+	}{
+		{func(ppl *Pipeline) {}, errors.New("<nil>"), "generated objects of the example are valid"},
+		{func(ppl *Pipeline) { ppl.Spec.Stages[0].Name = strings.Repeat("a", 64) },
+			fmt.Errorf(`spec.stages[0].metadata.name: Invalid value: "%s": must be no more than 63 characters`, strings.Repeat("a", 64)), "stage names must be dns labels"},
+		{func(ppl *Pipeline) { ppl.Name = strings.Repeat("a", 64) },
+			fmt.Errorf(`metadata.name: Invalid value: "%s": must be no more than 63 characters`, strings.Repeat("a", 64)), "pipeline names must be valid label values"},
+		{func(ppl *Pipeline) {
+			ppl.Spec.Stages[1].Spec.Containers = append(ppl.Spec.Stages[1].Spec.Containers, core.Container{Name: "jindra-watcher", Image: "alpine"})
+		}, errors.New(`spec.stages[1].spec.containers[jindra-watcher].name: Duplicate value: "jindra-watcher"`), "container names must not collide with generated containers"},
+		{func(ppl *Pipeline) {
+			ppl.Spec.Stages[0].Spec.Containers[0].VolumeMounts = append(ppl.Spec.Stages[0].Spec.Containers[0].VolumeMounts, core.VolumeMount{Name: "stage-volume", MountPath: "/jindra/resources/git"})
+		}, errors.New(`[spec.stages[0].spec.containers[build-go-binary].volumeMounts[/jindra/resources/git].name: Not found: "stage-volume": there is no volume with this name, ` +
+			`spec.stages[0].spec.containers[build-go-binary].volumeMounts[/jindra/resources/git].mountPath: Invalid value: "/jindra/resources/git": must be unique]`), "mount paths must not collide with resource mounts"},
+		{func(ppl *Pipeline) {
+			ppl.Spec.Stages[0].Spec.Volumes = append(ppl.Spec.Stages[0].Spec.Volumes, core.Volume{Name: "jindra-tools", VolumeSource: core.VolumeSource{EmptyDir: &core.EmptyDirVolumeSource{}}})
+		}, errors.New(`spec.stages[0].spec.volumes[jindra-tools].name: Duplicate value: "jindra-tools"`), "volume names must not collide with generated volumes"},
+	} {
+		ppl := getExamplePipeline(t)
+		test.modify(&ppl)
+
+		err := emptyErrorWrapper(ppl.ValidationErrors().ToAggregate())
+		if !reflect.DeepEqual(test.expected.Error(), err.Error()) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected.Error(), err.Error()))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
@@ -0,0 +1,110 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"regexp"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+)
+
+// referenceRegexp matches references to variables and secrets: ((name))
+var referenceRegexp = regexp.MustCompile(`\(\(\s*([^()\s]+)\s*\)\)`)
+
+// references returns the names of all variables and secrets value references
+func references(value string) []string {
+	names := []string{}
+	for _, match := range referenceRegexp.FindAllStringSubmatch(value, -1) {
+		names = append(names, match[1])
+	}
+
+	return names
+}
+
+// secretReference returns the name of the secret if value consists of a
+// single reference only
+func secretReference(value string) (string, bool) {
+	match := referenceRegexp.FindStringSubmatch(value)
+	if match == nil || strings.TrimSpace(value) != match[0] {
+		return "", false
+	}
+
+	return match[1], true
+}
+
+func (ppl Pipeline) variable(name string) (Variable, bool) {
+	for _, v := range ppl.Spec.Variables {
+		if v.Name == name {
+			return v, true
+		}
+	}
+
+	return Variable{}, false
+}
+
+func (ppl Pipeline) secret(name string) (Secret, bool) {
+	for _, s := range ppl.Spec.Secrets {
+		if s.Name == name {
+			return s, true
+		}
+	}
+
+	return Secret{}, false
+}
+
+// resolveEnv replaces references to variables in the values of envs and
+// env vars that reference a secret with the env var of the secret provider
+// -- unknown references are kept
+func (ppl Pipeline) resolveEnv(envs []core.EnvVar) []core.EnvVar {
+	if len(envs) == 0 {
+		return envs
+	}
+
+	resolved := []core.EnvVar{}
+	for _, env := range envs {
+		if name, ok := secretReference(env.Value); ok {
+			if secret, ok := ppl.secret(name); ok {
+				if secretEnv, ok := ppl.secretProvider().env(env.Name, secret); ok {
+					resolved = append(resolved, secretEnv)
+					continue
+				}
+			}
+		}
+
+		env.Value = referenceRegexp.ReplaceAllStringFunc(env.Value, func(reference string) string {
+			if v, ok := ppl.variable(referenceRegexp.FindStringSubmatch(reference)[1]); ok {
+				return v.Value
+			}
+			return reference
+		})
+		resolved = append(resolved, env)
+	}
+
+	return resolved
+}
+
+// resolveContainers returns containers with the references in their env
+// vars resolved
+func (ppl Pipeline) resolveContainers(containers []core.Container) []core.Container {
+	resolved := []core.Container{}
+	for _, c := range containers {
+		c.Env = ppl.resolveEnv(c.Env)
+		resolved = append(resolved, c)
+	}
+
+	return resolved
+}
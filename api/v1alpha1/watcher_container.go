@@ -29,14 +29,18 @@ func (ppl Pipeline) watcherContainer(stageName, waitFor string, semaphoreMount c
 		ImagePullPolicy: ppl.imagePullPolicy(),
 		Args: []string{"sh", "-c", fmt.Sprintf(`printf "waiting for steps to finish "
 containers=$(echo "%s"|sed "s/[,]*%s//g")
-while ! wget -qO- ${MY_IP}:8080/pod/${MY_NAME}.%s?containers=${containers}|grep Completed &>/dev/null
+while ! wget -qO- ${MY_IP}:8080/pod/${MY_NAME}.%s?containers=${containers}|grep "Completed\|Failed" &>/dev/null
 do
   printf "."
   sleep 3
 done
 echo
-rm %s
-`, waitFor, debugContainerName, stageName, path.Join(semaphoresPrefixPath, "steps-running")),
+rm -f %s
+if wget -qO- ${MY_IP}:8080/pod/${MY_NAME}.%s?containers=${containers}|grep Completed &>/dev/null
+then
+  rm %s
+fi
+`, waitFor, debugContainerName, stageName, path.Join(semaphoresPrefixPath, StepsFinishedSemaphore), stageName, path.Join(semaphoresPrefixPath, StepsRunningSemaphore)),
 		},
 		Env: []core.EnvVar{
 			{Name: "JOB_IP", Value: "${MY_IP}"},
@@ -0,0 +1,328 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/kesselborn/jindra/api/v1alpha1"
+)
+
+// annotations of v1alpha1 stages that are typed fields of v1beta1 stages
+const (
+	debugContainerAnnotationKey = "jindra.io/debug-container"
+	debugResourcesAnnotationKey = "jindra.io/debug-resources"
+	inResourceAnnotationKey     = "jindra.io/inputs"
+	inResourceEnvAnnotationKey  = "jindra.io/inputs-envs"
+	outResourceAnnotationKey    = "jindra.io/outputs"
+	outResourceEnvAnnotationKey = "jindra.io/outputs-envs"
+	servicesAnnotationKey       = "jindra.io/services"
+
+	debugEnabled = "enable"
+)
+
+var _ conversion.Convertible = &Pipeline{}
+
+// NewPipelineFromYaml creates a pipeline object from yaml source code
+func NewPipelineFromYaml(yamlData []byte) (Pipeline, error) {
+	jsonData, err := yaml.YAMLToJSON(yamlData)
+	if err != nil {
+		return Pipeline{}, fmt.Errorf("cannot convert yaml to json data: %s", err)
+	}
+
+	var p Pipeline
+	if err := json.Unmarshal(jsonData, &p); err != nil {
+		return Pipeline{}, fmt.Errorf("cannot unmarshal json data %s: %s", string(jsonData), err)
+	}
+
+	return p, nil
+}
+
+// ConvertTo converts the pipeline to the hub version v1alpha1 -- the typed
+// settings of stages become annotations, replacing annotations with the same
+// key
+func (ppl *Pipeline) ConvertTo(hub conversion.Hub) error {
+	dst, ok := hub.(*v1alpha1.Pipeline)
+	if !ok {
+		return fmt.Errorf("cannot convert pipeline to %T", hub)
+	}
+
+	dst.ObjectMeta = *ppl.ObjectMeta.DeepCopy()
+	spec := ppl.Spec.DeepCopy()
+	dst.Spec = v1alpha1.PipelineSpec{
+		Resources:               spec.Resources,
+		Variables:               spec.Variables,
+		Secrets:                 spec.Secrets,
+		SecretProvider:          spec.SecretProvider,
+		Transit:                 spec.Transit,
+		Caches:                  spec.Caches,
+		CacheStore:              spec.CacheStore,
+		Artifacts:               spec.Artifacts,
+		StageServiceAccountName: spec.StageServiceAccountName,
+	}
+
+	if spec.Stages != nil {
+		dst.Spec.Stages = []core.Pod{}
+	}
+	for _, s := range spec.Stages {
+		dst.Spec.Stages = append(dst.Spec.Stages, s.toPod())
+	}
+	for _, s := range []struct {
+		stage *Stage
+		pod   **core.Pod
+	}{
+		{spec.OnSuccess, &dst.Spec.OnSuccess},
+		{spec.OnError, &dst.Spec.OnError},
+		{spec.Final, &dst.Spec.Final},
+	} {
+		*s.pod = nil
+		if s.stage != nil {
+			pod := s.stage.toPod()
+			*s.pod = &pod
+		}
+	}
+
+	dst.Status = v1alpha1.PipelineStatus{BuildNo: ppl.Status.BuildNo}
+
+	return nil
+}
+
+// ConvertFrom converts the hub version v1alpha1 to this version -- stage
+// annotations that can't be converted to typed settings without changing
+// them (e.g. lists with spaces or env of resources the stage doesn't use)
+// are kept as they are
+func (ppl *Pipeline) ConvertFrom(hub conversion.Hub) error {
+	src, ok := hub.(*v1alpha1.Pipeline)
+	if !ok {
+		return fmt.Errorf("cannot convert pipeline from %T", hub)
+	}
+
+	ppl.ObjectMeta = *src.ObjectMeta.DeepCopy()
+	spec := src.Spec.DeepCopy()
+	ppl.Spec = PipelineSpec{
+		Resources:               spec.Resources,
+		Variables:               spec.Variables,
+		Secrets:                 spec.Secrets,
+		SecretProvider:          spec.SecretProvider,
+		Transit:                 spec.Transit,
+		Caches:                  spec.Caches,
+		CacheStore:              spec.CacheStore,
+		Artifacts:               spec.Artifacts,
+		StageServiceAccountName: spec.StageServiceAccountName,
+	}
+
+	if spec.Stages != nil {
+		ppl.Spec.Stages = []Stage{}
+	}
+	for _, p := range spec.Stages {
+		ppl.Spec.Stages = append(ppl.Spec.Stages, stageFromPod(p))
+	}
+	for _, s := range []struct {
+		pod   *core.Pod
+		stage **Stage
+	}{
+		{spec.OnSuccess, &ppl.Spec.OnSuccess},
+		{spec.OnError, &ppl.Spec.OnError},
+		{spec.Final, &ppl.Spec.Final},
+	} {
+		*s.stage = nil
+		if s.pod != nil {
+			stage := stageFromPod(*s.pod)
+			*s.stage = &stage
+		}
+	}
+
+	ppl.Status = PipelineStatus{BuildNo: src.Status.BuildNo}
+
+	return nil
+}
+
+// toPod returns the v1alpha1 stage
+func (s Stage) toPod() core.Pod {
+	pod := core.Pod{TypeMeta: metav1.TypeMeta{APIVersion: s.APIVersion, Kind: s.Kind}, ObjectMeta: s.ObjectMeta, Spec: s.Spec}
+
+	annotations := map[string]string{}
+	for k, v := range s.Annotations {
+		annotations[k] = v
+	}
+
+	set := func(key, value string) {
+		if value != "" {
+			annotations[key] = value
+		}
+	}
+	set(inResourceAnnotationKey, resourceNames(s.Inputs))
+	set(inResourceEnvAnnotationKey, envLines(s.Inputs))
+	set(outResourceAnnotationKey, resourceNames(s.Outputs))
+	set(outResourceEnvAnnotationKey, envLines(s.Outputs))
+	set(servicesAnnotationKey, strings.Join(s.Services, ","))
+	if s.Debug != nil && s.Debug.Container {
+		set(debugContainerAnnotationKey, debugEnabled)
+	}
+	if s.Debug != nil && s.Debug.Resources {
+		set(debugResourcesAnnotationKey, debugEnabled)
+	}
+
+	if len(annotations) > 0 {
+		pod.Annotations = annotations
+	}
+
+	return pod
+}
+
+// stageFromPod returns the v1beta1 stage of a v1alpha1 stage
+func stageFromPod(p core.Pod) Stage {
+	s := Stage{APIVersion: p.APIVersion, Kind: p.Kind, ObjectMeta: p.ObjectMeta, Spec: p.Spec}
+	if p.Annotations == nil {
+		return s
+	}
+
+	annotations := map[string]string{}
+	for k, v := range p.Annotations {
+		annotations[k] = v
+	}
+
+	s.Inputs = takeResources(annotations, inResourceAnnotationKey, inResourceEnvAnnotationKey)
+	s.Outputs = takeResources(annotations, outResourceAnnotationKey, outResourceEnvAnnotationKey)
+	if services, ok := takeList(annotations, servicesAnnotationKey); ok {
+		s.Services = services
+	}
+
+	debug := Debug{}
+	if annotations[debugContainerAnnotationKey] == debugEnabled {
+		debug.Container = true
+		delete(annotations, debugContainerAnnotationKey)
+	}
+	if annotations[debugResourcesAnnotationKey] == debugEnabled {
+		debug.Resources = true
+		delete(annotations, debugResourcesAnnotationKey)
+	}
+	if debug != (Debug{}) {
+		s.Debug = &debug
+	}
+
+	s.Annotations = annotations
+	if len(annotations) == 0 {
+		s.Annotations = nil
+	}
+
+	return s
+}
+
+// takeResources removes the list of resources key and the env of the
+// resources envKey from the annotations and returns them as stage resources
+// -- annotations that can't be converted without changing them are kept
+func takeResources(annotations map[string]string, key, envKey string) []StageResource {
+	names, ok := takeList(annotations, key)
+	if !ok {
+		return nil
+	}
+
+	resources := []StageResource{}
+	for _, name := range names {
+		resources = append(resources, StageResource{Name: name})
+	}
+
+	env, ok := annotations[envKey]
+	if !ok {
+		return resources
+	}
+
+	withEnv, ok := parseEnvLines(resources, env)
+	if !ok || envLines(withEnv) != env {
+		return resources
+	}
+	delete(annotations, envKey)
+
+	return withEnv
+}
+
+// takeList removes the comma separated list key from the annotations and
+// returns its entries -- lists with empty entries or entries with spaces are
+// kept
+func takeList(annotations map[string]string, key string) ([]string, bool) {
+	value, ok := annotations[key]
+	if !ok {
+		return nil, false
+	}
+
+	entries := strings.Split(value, ",")
+	for _, entry := range entries {
+		if entry == "" || strings.TrimSpace(entry) != entry {
+			return nil, false
+		}
+	}
+	delete(annotations, key)
+
+	return entries, true
+}
+
+// resourceNames returns the comma separated names of the resources
+func resourceNames(resources []StageResource) string {
+	names := []string{}
+	for _, r := range resources {
+		names = append(names, r.Name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+// envLines returns the env of the resources as lines of the format
+// '<resource>.<name>=<value>'
+func envLines(resources []StageResource) string {
+	lines := ""
+	for _, r := range resources {
+		for _, e := range r.Env {
+			lines += r.Name + "." + e.Name + "=" + e.Value + "\n"
+		}
+	}
+
+	return lines
+}
+
+// parseEnvLines adds the env of the lines to copies of the resources -- it
+// returns false if a line has the wrong format or refers to a resource that
+// is not in resources
+func parseEnvLines(resources []StageResource, lines string) ([]StageResource, bool) {
+	withEnv := []StageResource{}
+	index := map[string]int{}
+	for i, r := range resources {
+		withEnv = append(withEnv, StageResource{Name: r.Name})
+		index[r.Name] = i
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(lines, "\n"), "\n") {
+		tokens := strings.SplitN(line, "=", 2)
+		if len(tokens) != 2 {
+			return nil, false
+		}
+		name := strings.SplitN(tokens[0], ".", 2)
+		i, ok := index[name[0]]
+		if len(name) != 2 || !ok {
+			return nil, false
+		}
+		withEnv[i].Env = append(withEnv[i].Env, ResourceEnv{Name: name[1], Value: tokens[1]})
+	}
+
+	return withEnv, true
+}
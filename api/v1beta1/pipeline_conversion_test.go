@@ -0,0 +1,250 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"io/ioutil"
+	"math/rand"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/ghodss/yaml"
+	fuzz "github.com/google/gofuzz"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kesselborn/jindra/api/v1alpha1"
+)
+
+const fixtureDir = "../../tests/fixtures"
+
+func readFixture(t *testing.T, name string) []byte {
+	data, err := ioutil.ReadFile(path.Join(fixtureDir, name))
+	if err != nil {
+		t.Fatalf("error reading fixture %s: %s", name, err)
+	}
+
+	return data
+}
+
+func examplePipelines(t *testing.T) (v1alpha1.Pipeline, Pipeline) {
+	alpha, err := v1alpha1.NewPipelineFromYaml(readFixture(t, "pipeline-example.yaml"))
+	if err != nil {
+		t.Fatalf("cannot convert yaml to v1alpha1 pipeline: %s", err)
+	}
+
+	beta, err := NewPipelineFromYaml(readFixture(t, "pipeline-example-v1beta1.yaml"))
+	if err != nil {
+		t.Fatalf("cannot convert yaml to v1beta1 pipeline: %s", err)
+	}
+
+	// apiVersion and kind are set by the conversion webhook
+	alpha.TypeMeta, beta.TypeMeta = v1alpha1.Pipeline{}.TypeMeta, Pipeline{}.TypeMeta
+
+	return alpha, beta
+}
+
+func ok() string {
+	return " [OK]"
+}
+
+func errMsg(t *testing.T, expected interface{}, got interface{}) string {
+	expectedString, err := yaml.Marshal(expected)
+	if err != nil {
+		t.Fatalf("error marshalling %#v: %s", expected, err)
+	}
+	gotString, err := yaml.Marshal(got)
+	if err != nil {
+		t.Fatalf("error marshalling %#v: %s", got, err)
+	}
+
+	return " [FAILED]\n\t\texpected:\n" + string(expectedString) + "\n\t\tgot:\n" + string(gotString)
+}
+
+func TestExampleConversion(t *testing.T) {
+	alpha, beta := examplePipelines(t)
+
+	converted := v1alpha1.Pipeline{}
+	if err := beta.ConvertTo(&converted); err != nil {
+		t.Fatalf("\t%2d: %-80s %s", 0, "v1beta1 example converts to v1alpha1 example", errMsg(t, nil, err.Error()))
+	}
+	if !equality.Semantic.DeepEqual(alpha, converted) {
+		t.Fatalf("\t%2d: %-80s %s", 0, "v1beta1 example converts to v1alpha1 example", errMsg(t, alpha, converted))
+	}
+	t.Logf("\t%2d: %-80s %s", 0, "v1beta1 example converts to v1alpha1 example", ok())
+
+	convertedBack := Pipeline{}
+	if err := convertedBack.ConvertFrom(&alpha); err != nil {
+		t.Fatalf("\t%2d: %-80s %s", 1, "v1alpha1 example converts to v1beta1 example", errMsg(t, nil, err.Error()))
+	}
+	if !equality.Semantic.DeepEqual(beta, convertedBack) {
+		t.Fatalf("\t%2d: %-80s %s", 1, "v1alpha1 example converts to v1beta1 example", errMsg(t, beta, convertedBack))
+	}
+	t.Logf("\t%2d: %-80s %s", 1, "v1alpha1 example converts to v1beta1 example", ok())
+}
+
+func TestStageAnnotationConversion(t *testing.T) {
+	for i, test := range []struct {
+		annotations map[string]string
+		expected    Stage
+		desc        string
+	}{
+		{map[string]string{"jindra.io/inputs": "git,transit:bin", "jindra.io/inputs-envs": "git.source.branch=develop\ngit.params.depth=1\n"},
+			Stage{Inputs: []StageResource{{Name: "git", Env: []ResourceEnv{{"source.branch", "develop"}, {"params.depth", "1"}}}, {Name: "transit:bin"}}},
+			"inputs with env"},
+		{map[string]string{"jindra.io/outputs": "git, slack"},
+			Stage{ObjectMeta: objectMeta(map[string]string{"jindra.io/outputs": "git, slack"})},
+			"lists with spaces are kept"},
+		{map[string]string{"jindra.io/inputs": "git,,slack"},
+			Stage{ObjectMeta: objectMeta(map[string]string{"jindra.io/inputs": "git,,slack"})},
+			"lists with empty entries are kept"},
+		{map[string]string{"jindra.io/inputs": "git", "jindra.io/inputs-envs": "slack.params.text=hi\n"},
+			Stage{ObjectMeta: objectMeta(map[string]string{"jindra.io/inputs-envs": "slack.params.text=hi\n"}), Inputs: []StageResource{{Name: "git"}}},
+			"env of other resources is kept"},
+		{map[string]string{"jindra.io/outputs": "slack", "jindra.io/outputs-envs": "  slack.params.text=hi"},
+			Stage{ObjectMeta: objectMeta(map[string]string{"jindra.io/outputs-envs": "  slack.params.text=hi"}), Outputs: []StageResource{{Name: "slack"}}},
+			"env that is formatted differently is kept"},
+		{map[string]string{"jindra.io/services": "db,cache", "jindra.io/debug-container": "enable", "jindra.io/debug-resources": "true"},
+			Stage{ObjectMeta: objectMeta(map[string]string{"jindra.io/debug-resources": "true"}), Services: []string{"db", "cache"}, Debug: &Debug{Container: true}},
+			"services and debug settings"},
+		{map[string]string{"jindra.io/caches": "go-mod", "jindra.io/inputs": ""},
+			Stage{ObjectMeta: objectMeta(map[string]string{"jindra.io/caches": "go-mod", "jindra.io/inputs": ""})},
+			"other and empty annotations are kept"},
+	} {
+		pod := core.Pod{ObjectMeta: objectMeta(test.annotations)}
+		stage := stageFromPod(pod)
+		if !equality.Semantic.DeepEqual(test.expected, stage) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected, stage))
+		}
+
+		if roundTrip := stage.toPod(); !equality.Semantic.DeepEqual(pod, roundTrip) {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc+" (round trip)", errMsg(t, pod, roundTrip))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func objectMeta(annotations map[string]string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Annotations: annotations}
+}
+
+// annotationValues are values of the converted stage annotations that can
+// and can't be converted to typed fields
+var annotationValues = []string{"", "git", "git,transit", "git,,transit", "git, transit", "enable", "true", ",",
+	"git.source.branch=develop\n", "git.source.branch=develop", "transit.x=y\ngit.params.depth=1\n", "git.params\n", "=\n"}
+
+func TestFuzzedV1alpha1RoundTrip(t *testing.T) {
+	f := fuzz.New().NilChance(0.3).NumElements(0, 3).MaxDepth(8).RandSource(rand.NewSource(42)).Funcs(
+		func(p *core.Pod, c fuzz.Continue) {
+			c.FuzzNoCustom(p)
+			p.Status = core.PodStatus{} // not part of v1beta1 stages
+			for _, key := range []string{inResourceAnnotationKey, inResourceEnvAnnotationKey, outResourceAnnotationKey, outResourceEnvAnnotationKey,
+				servicesAnnotationKey, debugContainerAnnotationKey, debugResourcesAnnotationKey} {
+				if c.RandBool() {
+					if p.Annotations == nil {
+						p.Annotations = map[string]string{}
+					}
+					p.Annotations[key] = annotationValues[c.Intn(len(annotationValues))]
+				}
+			}
+		},
+	)
+
+	for i := 0; i < 200; i++ {
+		original := v1alpha1.Pipeline{}
+		f.Fuzz(&original)
+		original.TypeMeta = v1alpha1.Pipeline{}.TypeMeta
+		original.ResourceTypes = nil // not serialized
+
+		beta := Pipeline{}
+		if err := beta.ConvertFrom(original.DeepCopy()); err != nil {
+			t.Fatalf("\t%2d: %-80s %s", i, "v1alpha1 -> v1beta1 -> v1alpha1", errMsg(t, nil, err.Error()))
+		}
+		roundTrip := v1alpha1.Pipeline{}
+		if err := beta.ConvertTo(&roundTrip); err != nil {
+			t.Fatalf("\t%2d: %-80s %s", i, "v1alpha1 -> v1beta1 -> v1alpha1", errMsg(t, nil, err.Error()))
+		}
+
+		if !equality.Semantic.DeepEqual(original, roundTrip) {
+			t.Fatalf("\t%2d: %-80s %s", i, "v1alpha1 -> v1beta1 -> v1alpha1", errMsg(t, original, roundTrip))
+		}
+	}
+	t.Logf("\t%2d: %-80s %s", 0, "v1alpha1 -> v1beta1 -> v1alpha1", ok())
+}
+
+func TestFuzzedV1beta1RoundTrip(t *testing.T) {
+	// names and values are restricted to what the annotations can hold:
+	// lists of names without commas and spaces and lines without newlines
+	// names are unique, as resources with the same name can't be told apart
+	names := 0
+	name := func(c fuzz.Continue) string {
+		names++
+		return strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) || strings.ContainsRune(",.=", r) {
+				return -1
+			}
+			return r
+		}, "r"+strconv.Itoa(names)+c.RandString())
+	}
+	f := fuzz.New().NilChance(0.3).NumElements(0, 3).MaxDepth(8).RandSource(rand.NewSource(42)).Funcs(
+		func(s *Stage, c fuzz.Continue) {
+			c.FuzzNoCustom(s)
+			// typed settings replace the annotations
+			for _, key := range []string{inResourceAnnotationKey, inResourceEnvAnnotationKey, outResourceAnnotationKey, outResourceEnvAnnotationKey,
+				servicesAnnotationKey, debugContainerAnnotationKey, debugResourcesAnnotationKey} {
+				delete(s.Annotations, key)
+			}
+			if s.Debug != nil && *s.Debug == (Debug{}) {
+				s.Debug = nil
+			}
+			for i := range s.Services {
+				s.Services[i] = name(c)
+			}
+		},
+		func(r *StageResource, c fuzz.Continue) {
+			c.FuzzNoCustom(r)
+			r.Name = name(c)
+		},
+		func(e *ResourceEnv, c fuzz.Continue) {
+			e.Name = strings.NewReplacer("=", "", "\n", "").Replace(c.RandString())
+			e.Value = strings.Replace(c.RandString(), "\n", "", -1)
+		},
+	)
+
+	for i := 0; i < 200; i++ {
+		original := Pipeline{}
+		f.Fuzz(&original)
+		original.TypeMeta = Pipeline{}.TypeMeta
+
+		alpha := v1alpha1.Pipeline{}
+		if err := original.DeepCopy().ConvertTo(&alpha); err != nil {
+			t.Fatalf("\t%2d: %-80s %s", i, "v1beta1 -> v1alpha1 -> v1beta1", errMsg(t, nil, err.Error()))
+		}
+		roundTrip := Pipeline{}
+		if err := roundTrip.ConvertFrom(&alpha); err != nil {
+			t.Fatalf("\t%2d: %-80s %s", i, "v1beta1 -> v1alpha1 -> v1beta1", errMsg(t, nil, err.Error()))
+		}
+
+		if !equality.Semantic.DeepEqual(original, roundTrip) {
+			t.Fatalf("\t%2d: %-80s %s", i, "v1beta1 -> v1alpha1 -> v1beta1", errMsg(t, original, roundTrip))
+		}
+	}
+	t.Logf("\t%2d: %-80s %s", 0, "v1beta1 -> v1alpha1 -> v1beta1", ok())
+}
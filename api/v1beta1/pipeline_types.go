@@ -0,0 +1,183 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/kesselborn/jindra/api/v1alpha1"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// Pipeline is the Schema for the pipelines API -- the settings of stages are
+// typed fields instead of the jindra.io annotations of v1alpha1
+type Pipeline struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PipelineSpec   `json:"spec,omitempty"`
+	Status PipelineStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// PipelineList contains a list of Pipeline
+type PipelineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Pipeline `json:"items"`
+}
+
+// PipelineSpec defines the desired state of Pipeline -- the settings that
+// didn't change since v1alpha1 use the v1alpha1 types
+type PipelineSpec struct {
+	// Resources that can be used within the pipeline
+	// +optional
+	Resources v1alpha1.Resources `json:"resources,omitempty"`
+
+	// Constants that are referenced as ((name)) in env vars of resources and
+	// stages and in the env of stage inputs and outputs
+	// +optional
+	Variables []v1alpha1.Variable `json:"variables,omitempty"`
+
+	// Secrets that are referenced as ((name)) like variables -- a secret must
+	// be the whole value of an env var
+	// +optional
+	Secrets []v1alpha1.Secret `json:"secrets,omitempty"`
+
+	// Store the values of spec.secrets are read from (default: kubernetes)
+	// +optional
+	SecretProvider v1alpha1.SecretProvider `json:"secretProvider,omitempty"`
+
+	// Storage backend that is used to pass the transit resource between stages
+	// +optional
+	Transit v1alpha1.Transit `json:"transit,omitempty"`
+
+	// Caches that are kept between pipeline runs
+	// +optional
+	Caches []v1alpha1.Cache `json:"caches,omitempty"`
+
+	// Store that keeps the cache entries (default claim: jindra-caches)
+	// +optional
+	CacheStore v1alpha1.ObjectStore `json:"cacheStore,omitempty"`
+
+	// Store and retention of the artifacts of stages
+	// +optional
+	Artifacts v1alpha1.Artifacts `json:"artifacts,omitempty"`
+
+	// Service account of the stage pods that don't set one (default: the
+	// default service account of the namespace) -- it is not created by jindra
+	// +optional
+	StageServiceAccountName string `json:"stageServiceAccountName,omitempty"`
+
+	// Stages of this pipeline, executed in this order
+	Stages []Stage `json:"stages"`
+
+	// Stage that should be executed if all stages finished successfully
+	// +optional
+	OnSuccess *Stage `json:"onSuccess,omitempty"`
+
+	// Stage that should be executed if an error ocurred in any stage
+	// +optional
+	OnError *Stage `json:"onError,omitempty"`
+
+	// Stage that should be executed if the pipeline finished (regardless
+	// whether it was successful or not)
+	// +optional
+	Final *Stage `json:"final,omitempty"`
+}
+
+// Stage is a pod of the pipeline with its inputs, outputs, services and
+// debug settings -- the annotations jindra.io/caches,
+// jindra.io/artifacts and jindra.io/first-init-containers are set in the
+// metadata like in v1alpha1; the status of v1alpha1 stage pods has no
+// meaning and is not converted
+type Stage struct {
+	// apiVersion and kind of stages converted from v1alpha1 pods
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Resources and transit channels the stage reads
+	// +optional
+	Inputs []StageResource `json:"inputs,omitempty"`
+
+	// Resources and transit channels the stage writes
+	// +optional
+	Outputs []StageResource `json:"outputs,omitempty"`
+
+	// Containers that provide services for the stage (e.g. a database for
+	// testing) and are not waited for to finish
+	// +optional
+	Services []string `json:"services,omitempty"`
+
+	// +optional
+	Debug *Debug `json:"debug,omitempty"`
+
+	// Pod spec of the stage -- stages that only use resources don't need
+	// containers
+	// +optional
+	Spec core.PodSpec `json:"spec,omitempty"`
+}
+
+// StageResource is an input or output of a stage
+type StageResource struct {
+	// Name of the resource or transit channel (transit, transit:<channel>)
+	Name string `json:"name"`
+
+	// Settings of the resource that are added or changed for this stage
+	// +optional
+	Env []ResourceEnv `json:"env,omitempty"`
+}
+
+// ResourceEnv is a setting of a resource, e.g. params.image=./image.tar --
+// values are not interpolated and must not contain newlines
+type ResourceEnv struct {
+	// Name of the setting without the resource name, e.g. params.image
+	Name string `json:"name"`
+
+	Value string `json:"value"`
+}
+
+// Debug configures the debugging aids of a stage
+type Debug struct {
+	// Add the container jindra-debug-container with all shared volumes -- the
+	// stage doesn't finish until the file /tmp/DELETE_ME_TO_STOP_DEBUG_CONTAINER
+	// is deleted in the container
+	// +optional
+	Container bool `json:"container,omitempty"`
+
+	// Keep failing resource containers running for 5 more minutes
+	// +optional
+	Resources bool `json:"resources,omitempty"`
+}
+
+// +kubebuilder:printcolumn:JSONPath=".status.buildNo",name=BuildNo,type=int
+
+// PipelineStatus defines the observed state of Pipeline
+type PipelineStatus struct {
+	BuildNo int `json:"buildNo"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Pipeline{}, &PipelineList{})
+}
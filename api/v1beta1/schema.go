@@ -0,0 +1,63 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"reflect"
+
+	"github.com/kesselborn/jindra/api/v1alpha1"
+	"github.com/kesselborn/jindra/jsonschema"
+)
+
+// PipelineSchema returns the JSON schema of pipelines of both API versions
+// -- pipelines with apiVersion ci.jindra.io/v1beta1 are validated as
+// v1beta1 pipelines, all others as v1alpha1 pipelines
+func PipelineSchema() *jsonschema.Schema {
+	s := jsonschema.Reflect(Pipeline{})
+
+	ppl := s.Definition(Pipeline{})
+	ppl.Properties["apiVersion"] = &jsonschema.Schema{Type: "string", Enum: []string{GroupVersion.String()}}
+	ppl.Properties["kind"] = &jsonschema.Schema{Type: "string", Enum: []string{"Pipeline"}}
+	ppl.Properties["metadata"] = v1alpha1.WithAnnotations(ppl.Properties["metadata"], v1alpha1.PipelineAnnotationsSchema())
+
+	// annotations that are typed fields in v1beta1 are only documented as
+	// fields
+	stageAnnotations := v1alpha1.StageAnnotationsSchema()
+	for _, key := range []string{debugContainerAnnotationKey, debugResourcesAnnotationKey, inResourceAnnotationKey,
+		inResourceEnvAnnotationKey, outResourceAnnotationKey, outResourceEnvAnnotationKey, servicesAnnotationKey} {
+		delete(stageAnnotations.Properties, key)
+	}
+	stage := s.Definition(Stage{})
+	stage.Properties["metadata"] = v1alpha1.WithAnnotations(stage.Properties["metadata"], stageAnnotations)
+
+	v1alpha1Schema := v1alpha1.PipelineSchema()
+	for name, def := range v1alpha1Schema.Definitions {
+		if _, ok := s.Definitions[name]; !ok {
+			s.Definitions[name] = def
+		}
+	}
+
+	return &jsonschema.Schema{
+		Schema: jsonschema.Draft,
+		If: &jsonschema.Schema{
+			Properties: map[string]*jsonschema.Schema{"apiVersion": {Enum: []string{GroupVersion.String()}}},
+			Required:   []string{"apiVersion"},
+		},
+		Then:        jsonschema.RefTo(jsonschema.DefinitionName(reflect.TypeOf(Pipeline{}))),
+		Else:        jsonschema.RefTo(jsonschema.DefinitionName(reflect.TypeOf(v1alpha1.Pipeline{}))),
+		Definitions: s.Definitions,
+	}
+}
@@ -0,0 +1,123 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+)
+
+func TestPublishedSchema(t *testing.T) {
+	published, err := ioutil.ReadFile("../../config/schema/pipeline.json")
+	if err != nil {
+		t.Fatalf("error reading published schema: %s", err)
+	}
+
+	schema, err := json.MarshalIndent(PipelineSchema(), "", "  ")
+	if err != nil {
+		t.Fatalf("error marshalling schema: %s", err)
+	}
+
+	if string(published) != string(schema)+"\n" {
+		t.Fatalf("config/schema/pipeline.json is outdated -- run 'make schema'")
+	}
+}
+
+// maxObjectSize is the maximum size of objects stored in etcd
+const maxObjectSize = 1536 * 1024
+
+func TestCRDSize(t *testing.T) {
+	crd, err := ioutil.ReadFile("../../config/crd/bases/ci.jindra.io_pipelines.yaml")
+	if err != nil {
+		t.Fatalf("error reading crd: %s", err)
+	}
+
+	compact, err := yaml.YAMLToJSON(crd)
+	if err != nil {
+		t.Fatalf("error converting crd to json: %s", err)
+	}
+
+	if len(compact) > maxObjectSize {
+		t.Fatalf("crd has %d bytes, but etcd only stores objects up to %d bytes", len(compact), maxObjectSize)
+	}
+}
+
+const minimalPipeline = `
+apiVersion: ci.jindra.io/VERSION
+kind: Pipeline
+spec:
+  stages:
+    - inputs: [{name: git}]
+      spec: {containers: [{name: build, image: alpine}]}
+`
+
+func TestSchemaValidation(t *testing.T) {
+	for i, test := range []struct {
+		fixture  string
+		replacer *strings.Replacer
+		expected []string
+		desc     string
+	}{
+		{"pipeline-example.yaml", strings.NewReplacer(), []string{},
+			"v1alpha1 example is valid"},
+		{"pipeline-example-v1beta1.yaml", strings.NewReplacer(), []string{},
+			"v1beta1 example is valid"},
+		{"pipeline-example.yaml", strings.NewReplacer(`jindra.io/build-no-offset: "42"`, `jindra.io/build-no-offset: 42`),
+			[]string{`metadata.annotations[jindra.io/build-no-offset]: Invalid value: "number": must be of type string`},
+			"annotations must be strings"},
+		{"pipeline-example.yaml", strings.NewReplacer("jindra.io/inputs: git\n", "jindra.io/inputs: git, transit\n"),
+			[]string{`spec.stages[0].metadata.annotations[jindra.io/inputs]: Invalid value: "git, transit": must match the pattern ` + `^[^,\s]+(,[^,\s]+)*$`},
+			"lists in annotations must not contain spaces"},
+		{"pipeline-example.yaml", strings.NewReplacer("jindra.io/debug-resources: enable", "jindra.io/debug-resources: enabled"),
+			[]string{`spec.stages[0].metadata.annotations[jindra.io/debug-resources]: Unsupported value: "enabled": supported values: "enable"`},
+			"debug annotations can only be enabled"},
+		{"pipeline-example.yaml", strings.NewReplacer("workingDir:", "workdir:"),
+			[]string{`spec.stages[0].spec.containers[0].workdir: Forbidden: unknown field`},
+			"unknown fields of stage pods are reported"},
+		{"pipeline-example-v1beta1.yaml", strings.NewReplacer("        resources: true", "        resource: true"),
+			[]string{`spec.stages[0].debug.resource: Forbidden: unknown field`},
+			"unknown fields of v1beta1 stages are reported"},
+		{"", strings.NewReplacer("VERSION", "v1alpha1"),
+			[]string{`spec.stages[0].inputs: Forbidden: unknown field`},
+			"pipelines are validated with the schema of their api version (v1alpha1)"},
+		{"", strings.NewReplacer("VERSION", "v1beta1"), []string{},
+			"pipelines are validated with the schema of their api version (v1beta1)"},
+	} {
+		pipelineYaml := minimalPipeline
+		if test.fixture != "" {
+			pipelineYaml = string(readFixture(t, test.fixture))
+		}
+
+		var doc interface{}
+		if err := yaml.Unmarshal([]byte(test.replacer.Replace(pipelineYaml)), &doc); err != nil {
+			t.Fatalf("error unmarshalling fixture %s: %s", test.fixture, err)
+		}
+
+		got := []string{}
+		for _, err := range PipelineSchema().Validate(doc) {
+			got = append(got, err.Error())
+		}
+
+		if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected, got))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
@@ -0,0 +1,192 @@
+// Package artifacts keeps files of pipeline runs (test reports, binaries,
+// ...) beyond the lifetime of the run. Every artifact is a path below the
+// resources directory of a stage that is archived to a store when the stage
+// ends -- regardless whether it succeeded. Artifacts are indexed by run and
+// stage (the store is per pipeline): '<run>/<stage>/<name>.tar.gz'.
+package artifacts
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kesselborn/jindra/store"
+)
+
+const archiveSuffix = ".tar.gz"
+
+// Artifact describes an uploaded artifact
+type Artifact struct {
+	Run   int
+	Stage string
+	// Name is the path of the artifact relative to the resources directory,
+	// e.g. 'transit/http-fs-linux'
+	Name    string
+	Size    int64
+	Created time.Time
+}
+
+// Key returns the key of the artifact in the store
+func (a Artifact) Key() string {
+	return fmt.Sprintf("%d/%s/%s%s", a.Run, a.Stage, a.Name, archiveSuffix)
+}
+
+// Retention limits how long artifacts are kept
+type Retention struct {
+	// KeepRuns is the number of most recent runs whose artifacts are kept (0
+	// means: all)
+	KeepRuns int
+	// MaxAge is the age after which the artifacts of a run are deleted (0
+	// means: never)
+	MaxAge time.Duration
+}
+
+// Upload archives every file or directory of paths (relative to dir) as an
+// artifact of stage -- paths that don't exist are skipped, as failed stages
+// often don't produce all of them. It returns the uploaded artifacts.
+func Upload(s store.Store, run int, stage, dir string, paths []string) ([]Artifact, error) {
+	uploaded := []Artifact{}
+	for _, p := range paths {
+		name := path.Clean(filepath.ToSlash(p))
+		if _, err := os.Lstat(filepath.Join(dir, name)); os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "warning: artifact %s does not exist, skipping\n", name)
+			continue
+		}
+
+		artifact := Artifact{Run: run, Stage: stage, Name: name}
+		parent, base := path.Split(name)
+		if err := store.PutArchive(s, artifact.Key(), filepath.Join(dir, parent), base); err != nil {
+			return uploaded, err
+		}
+		uploaded = append(uploaded, artifact)
+	}
+
+	return uploaded, nil
+}
+
+// List returns the artifacts of run and stage sorted by run, stage and name
+// -- run 0 lists all runs, an empty stage all stages of the run
+func List(s store.Store, run int, stage string) ([]Artifact, error) {
+	prefix := ""
+	if run != 0 {
+		prefix = fmt.Sprintf("%d/", run)
+		if stage != "" {
+			prefix += stage + "/"
+		}
+	}
+
+	entries, err := s.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := []Artifact{}
+	for _, entry := range entries {
+		tokens := strings.SplitN(entry.Key, "/", 3)
+		if len(tokens) != 3 || !strings.HasSuffix(tokens[2], archiveSuffix) {
+			continue
+		}
+
+		entryRun, err := strconv.Atoi(tokens[0])
+		if err != nil || (stage != "" && tokens[1] != stage) {
+			continue
+		}
+
+		artifacts = append(artifacts, Artifact{
+			Run:     entryRun,
+			Stage:   tokens[1],
+			Name:    strings.TrimSuffix(tokens[2], archiveSuffix),
+			Size:    entry.Size,
+			Created: entry.LastUsed,
+		})
+	}
+
+	sort.Slice(artifacts, func(i, j int) bool {
+		if artifacts[i].Run != artifacts[j].Run {
+			return artifacts[i].Run < artifacts[j].Run
+		}
+		if artifacts[i].Stage != artifacts[j].Stage {
+			return artifacts[i].Stage < artifacts[j].Stage
+		}
+		return artifacts[i].Name < artifacts[j].Name
+	})
+
+	return artifacts, nil
+}
+
+// Download extracts artifact into dir -- the artifact keeps its base name,
+// i.e. 'transit/reports' is extracted to '<dir>/reports'
+func Download(s store.Store, artifact Artifact, dir string) error {
+	r, err := s.Get(artifact.Key())
+	if err == store.ErrNotFound {
+		return fmt.Errorf("artifact %s of stage %s of run %d does not exist", artifact.Name, artifact.Stage, artifact.Run)
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := store.Extract(r, dir); err != nil {
+		return fmt.Errorf("error extracting %s: %s", artifact.Key(), err)
+	}
+
+	return nil
+}
+
+// Prune deletes the artifacts of all runs that exceed the retention limits
+// -- the artifacts of run current are always kept. It returns the runs whose
+// artifacts were deleted.
+func Prune(s store.Store, retention Retention, current int, now time.Time) ([]int, error) {
+	artifacts, err := List(s, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []int{}
+	created := map[int]time.Time{}
+	for _, artifact := range artifacts {
+		if _, ok := created[artifact.Run]; !ok {
+			runs = append(runs, artifact.Run)
+		}
+		if artifact.Created.After(created[artifact.Run]) {
+			created[artifact.Run] = artifact.Created
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(runs)))
+
+	expired := map[int]bool{}
+	for i, run := range runs {
+		if run == current {
+			continue
+		}
+		if retention.KeepRuns > 0 && i >= retention.KeepRuns {
+			expired[run] = true
+		}
+		if retention.MaxAge > 0 && now.Sub(created[run]) > retention.MaxAge {
+			expired[run] = true
+		}
+	}
+
+	pruned := []int{}
+	for _, run := range runs {
+		if !expired[run] {
+			continue
+		}
+		for _, artifact := range artifacts {
+			if artifact.Run != run {
+				continue
+			}
+			if err := s.Delete(artifact.Key()); err != nil {
+				return pruned, err
+			}
+		}
+		pruned = append(pruned, run)
+	}
+
+	return pruned, nil
+}
@@ -0,0 +1,125 @@
+package artifacts
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kesselborn/jindra/store"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "jindra-artifacts")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %s", err)
+	}
+
+	return dir
+}
+
+func names(artifacts []Artifact) []string {
+	names := []string{}
+	for _, a := range artifacts {
+		names = append(names, a.Key())
+	}
+
+	return names
+}
+
+func TestUploadListDownload(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s := store.NewDirStore(path.Join(dir, "store"))
+	resources := path.Join(dir, "resources")
+	os.MkdirAll(path.Join(resources, "git", "reports"), 0755)
+	os.MkdirAll(path.Join(resources, "transit"), 0755)
+	ioutil.WriteFile(path.Join(resources, "git", "reports", "junit.xml"), []byte("<testsuite/>"), 0644)
+	ioutil.WriteFile(path.Join(resources, "git", "main.go"), []byte("package main"), 0644)
+	ioutil.WriteFile(path.Join(resources, "transit", "http-fs-linux"), []byte("binary"), 0755)
+
+	uploaded, err := Upload(s, 42, "01-build-go-binary", resources, []string{"git/reports", "transit/http-fs-linux", "transit/missing"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if expected := []string{"42/01-build-go-binary/git/reports.tar.gz", "42/01-build-go-binary/transit/http-fs-linux.tar.gz"}; !reflect.DeepEqual(expected, names(uploaded)) {
+		t.Fatalf("expected %v to be uploaded, got %v", expected, names(uploaded))
+	}
+
+	Upload(s, 43, "02-build-docker-image", resources, []string{"transit/http-fs-linux"})
+
+	for i, test := range []struct {
+		run      int
+		stage    string
+		expected []string
+	}{
+		{0, "", []string{"42/01-build-go-binary/git/reports.tar.gz", "42/01-build-go-binary/transit/http-fs-linux.tar.gz", "43/02-build-docker-image/transit/http-fs-linux.tar.gz"}},
+		{43, "", []string{"43/02-build-docker-image/transit/http-fs-linux.tar.gz"}},
+		{42, "02-build-docker-image", []string{}},
+	} {
+		artifacts, err := List(s, test.run, test.stage)
+		if err != nil || !reflect.DeepEqual(test.expected, names(artifacts)) {
+			t.Errorf("%d: expected %v, got %v (%v)", i, test.expected, names(artifacts), err)
+		}
+	}
+
+	dst := path.Join(dir, "dst")
+	if err := Download(s, uploaded[0], dst); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if content, _ := ioutil.ReadFile(path.Join(dst, "reports", "junit.xml")); string(content) != "<testsuite/>" {
+		t.Errorf("expected reports/junit.xml to be downloaded, got '%s'", string(content))
+	}
+	if _, err := os.Stat(path.Join(dst, "main.go")); !os.IsNotExist(err) {
+		t.Errorf("expected only the artifact path to be archived")
+	}
+
+	if err := Download(s, Artifact{Run: 1, Stage: "x", Name: "y"}, dst); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("expected error for missing artifact, got %v", err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s := store.NewDirStore(dir)
+	now := time.Now()
+	for _, run := range []struct {
+		key string
+		age time.Duration
+	}{
+		{"1/01-build/report.tar.gz", 50 * time.Hour},
+		{"2/01-build/report.tar.gz", 30 * time.Hour},
+		{"3/01-build/report.tar.gz", 20 * time.Hour},
+		{"4/01-build/report.tar.gz", 10 * time.Hour},
+		{"5/01-build/report.tar.gz", time.Hour},
+	} {
+		s.Put(run.key, strings.NewReader("report"), 6)
+		os.Chtimes(path.Join(dir, run.key), now.Add(-run.age), now.Add(-run.age))
+	}
+
+	for i, test := range []struct {
+		retention Retention
+		current   int
+		expected  []int
+	}{
+		{Retention{}, 5, []int{}},
+		{Retention{MaxAge: 40 * time.Hour}, 5, []int{1}},
+		{Retention{KeepRuns: 2}, 2, []int{3}},
+		{Retention{KeepRuns: 1, MaxAge: time.Minute}, 5, []int{4, 2}},
+	} {
+		pruned, err := Prune(s, test.retention, test.current, now)
+		if err != nil || !reflect.DeepEqual(test.expected, pruned) {
+			t.Fatalf("%d: expected runs %v to be pruned, got %v (%v)", i, test.expected, pruned, err)
+		}
+	}
+
+	if artifacts, _ := List(s, 0, ""); !reflect.DeepEqual([]string{"5/01-build/report.tar.gz"}, names(artifacts)) {
+		t.Errorf("unexpected remaining artifacts %v", names(artifacts))
+	}
+}
@@ -13,6 +13,16 @@ function get_logs() {
   kubectl get pod ${pod} -ojson|jq .status
 }
 
+# replaces the values of all secrets the stages reference in the logs
+function mask_secrets() {
+  if test -s /tmp/secrets.json
+  then
+    jindra-mask -secrets-file /tmp/secrets.json
+  else
+    cat
+  fi
+}
+
 function wait_for_init_containers() {
   local pod=$1
 
@@ -49,17 +59,11 @@ function wait_for_containers() {
 block_until_finished() {
   local name=$1
   local wait_for=$(kubectl get pod ${name} -ojson |jq -r ".metadata.annotations[\"${WAIT_FOR_ANNOTATION_KEY}\"] // empty")
-  local outputs=$(kubectl get pod ${name} -ojson |jq -r ".metadata.annotations[\"${OUT_RESOURCE_ANNOTATION_KEY}\"] // empty")
-
-  local outputs_container_names=""
-  for c in $(echo "${outputs}"|tr "," " ")
-  do
-    if [ -n "${outputs_container_names}" ]
-    then
-      outputs_container_names="${outputs_container_names},"
-    fi
-    outputs_container_names="${outputs_container_names}${OUT_RESOURCE_CONTAINER_NAME_PREFIX}${c}"
-  done
+  # out resource containers (resources with a directly mounted volume don't have one), cache save containers and
+  # the artifacts container
+  local outputs_container_names=$(kubectl get pod ${name} -ojson |jq -r "[.spec.containers[].name|select(startswith(\"${OUT_RESOURCE_CONTAINER_NAME_PREFIX}\") or startswith(\"${CACHE_SAVE_CONTAINER_NAME_PREFIX}\") or . == \"${ARTIFACTS_CONTAINER_NAME}\")]|join(\",\")")
+  # artifacts are uploaded even if the steps failed
+  local artifacts_container_name=$(kubectl get pod ${name} -ojson |jq -r "[.spec.containers[].name|select(. == \"${ARTIFACTS_CONTAINER_NAME}\")]|join(\",\")")
 
   if ! wait_for_init_containers ${name}
   then
@@ -78,6 +82,7 @@ block_until_finished() {
     fi
   else
     echo "waiting for steps ${name}/${wait_for} failed"
+    test -n "${artifacts_container_name}" && wait_for_containers ${name} ${artifacts_container_name}
     return 3
   fi
 }
@@ -95,7 +100,7 @@ EOF
   kubectl patch pod ${name} --patch "$(cat /tmp/patch.yaml)"
   block_until_finished ${name}
   local pod_res=$?
-  get_logs ${name}
+  get_logs ${name} 2>&1 | mask_secrets
   kubectl delete --wait=false pod ${name}
   if [ "${pod_res}" = "0" ]
   then
@@ -106,6 +111,11 @@ EOF
   fi
 }
 
+if test -n "${JINDRA_MASKED_SECRETS}"
+then
+  kubectl get secret ${JINDRA_MASKED_SECRETS} -ojson > /tmp/secrets.json || echo "warning: not all secrets could be read -- their values are not masked in the logs"
+fi
+
 cat<<EOF >/tmp/patch.yaml
 metadata:
   labels:
@@ -113,6 +123,14 @@ metadata:
     ${PIPELINE_LABEL_KEY}: "${JINDRA_PIPELINE_NAME}"
 EOF
 
+# the role of the run is created by the operator if it wasn't created with the runner pod
+for attempt in $(seq 1 30)
+do
+  kubectl auth can-i patch pod/${MY_NAME} >/dev/null 2>&1 && break
+  echo "waiting for the role of the run"
+  sleep 2
+done
+
 kubectl patch pod ${MY_NAME} --patch "$(cat /tmp/patch.yaml)"
 
 cat<<EOF >>/tmp/patch.yaml
@@ -125,7 +143,10 @@ cat<<EOF >>/tmp/patch.yaml
 EOF
 
 (set -x;
-kubectl patch secret    $(printf "${RSYNC_KEY_NAME_FORMAT_STRING}"  "${JINDRA_PIPELINE_NAME}" ${JINDRA_PIPELINE_RUN_NO}) --patch "$(cat /tmp/patch.yaml)"
+for run_object in ${JINDRA_TRANSIT_OBJECTS} ${JINDRA_RBAC_OBJECTS}
+do
+  kubectl patch ${run_object} --patch "$(cat /tmp/patch.yaml)"
+done
 kubectl patch configmap $(printf "${CONFIG_MAP_NAME_FORMAT_STRING}" "${JINDRA_PIPELINE_NAME}" ${JINDRA_PIPELINE_RUN_NO}) --patch "$(cat /tmp/patch.yaml)"
 )
 
@@ -1,10 +1,22 @@
 #!/bin/sh -x
-mkdir -p /root/.ssh
-cat /mnt/ssh/authorized_keys > /etc/authorized_keys/root
-/entry.sh "$@" &
+# sshd runs as the unprivileged user jindra, which is the only user that can
+# log in -- host keys, config and authorized keys live in /tmp
+mkdir -p /tmp/ssh
+ssh-keygen -q -t ed25519 -N "" -f /tmp/ssh/ssh_host_ed25519_key
+cat /mnt/ssh/authorized_keys > /tmp/ssh/authorized_keys
+cat<<EOC > /tmp/ssh/sshd_config
+Port ${JINDRA_RSYNC_PORT}
+HostKey /tmp/ssh/ssh_host_ed25519_key
+AuthorizedKeysFile /tmp/ssh/authorized_keys
+PasswordAuthentication no
+StrictModes no
+PidFile /tmp/ssh/sshd.pid
+EOC
+test -n "${JINDRA_TRANSIT_DIRS}" && mkdir -p ${JINDRA_TRANSIT_DIRS}
+"$@" &
 pid=$!
 
 echo "waiting for stages to finish"
-while test -e ${STAGES_RUNNING_SEMAPHORE}; do printf "."; sleep 1; done
+wait-for-semaphore ${STAGES_RUNNING_SEMAPHORE}
 
 kill -9 $pid
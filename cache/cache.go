@@ -0,0 +1,120 @@
+// Package cache implements jindra's cross-run caches: a cache directory is
+// archived to a store after a stage succeeded and restored before the stage
+// runs the next time. Entries are keyed by the hash of files like go.sum, so
+// a changed dependency list leads to a new entry -- old entries are evicted
+// once the entries of a cache exceed their maximum size.
+package cache
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/kesselborn/jindra/store"
+)
+
+// Key returns the key of cache name: the sha256 of the content of files (in
+// the given order) -- caches without key files have a single key
+func Key(name string, files []string) (string, error) {
+	if len(files) == 0 {
+		return path.Join(name, "default.tar.gz"), nil
+	}
+
+	digest := sha256.New()
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return "", fmt.Errorf("error opening key file: %s", err)
+		}
+
+		fmt.Fprintf(digest, "%s\n", path.Base(file))
+		_, err = io.Copy(digest, f)
+		f.Close()
+		if err != nil {
+			return "", fmt.Errorf("error reading key file %s: %s", file, err)
+		}
+	}
+
+	return path.Join(name, fmt.Sprintf("%x", digest.Sum(nil))[:16]+".tar.gz"), nil
+}
+
+// Restore extracts entry key of cache name into dir. If there is no such
+// entry, the most recently used entry of the cache is restored instead. It
+// returns the key of the restored entry (empty if the cache is empty).
+func Restore(s store.Store, name, key, dir string) (string, error) {
+	restored := key
+	r, err := s.Get(key)
+	if err == store.ErrNotFound {
+		entries, err := s.List(name + "/")
+		if err != nil {
+			return "", err
+		}
+		if len(entries) == 0 {
+			return "", nil
+		}
+
+		sortByLastUsed(entries)
+		restored = entries[0].Key
+		if r, err = s.Get(restored); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	if err := store.Extract(r, dir); err != nil {
+		return "", fmt.Errorf("error extracting %s: %s", restored, err)
+	}
+
+	return restored, nil
+}
+
+// Save archives dir as entry key
+func Save(s store.Store, key, dir string) error {
+	return store.PutArchive(s, key, dir)
+}
+
+// Evict deletes the least recently used entries of cache name until all
+// entries fit into maxSize bytes -- the entry keep is never deleted. It
+// returns the keys of the deleted entries.
+func Evict(s store.Store, name, keep string, maxSize int64) ([]string, error) {
+	entries, err := s.List(name + "/")
+	if err != nil {
+		return nil, err
+	}
+	sortByLastUsed(entries)
+
+	total := int64(0)
+	for _, entry := range entries {
+		if entry.Key == keep {
+			total += entry.Size
+		}
+	}
+
+	deleted := []string{}
+	for _, entry := range entries {
+		if entry.Key == keep {
+			continue
+		}
+		if total+entry.Size <= maxSize {
+			total += entry.Size
+			continue
+		}
+
+		if err := s.Delete(entry.Key); err != nil {
+			return deleted, fmt.Errorf("error deleting %s: %s", entry.Key, err)
+		}
+		deleted = append(deleted, entry.Key)
+	}
+
+	return deleted, nil
+}
+
+// sortByLastUsed sorts entries by their last usage, most recent first
+func sortByLastUsed(entries []store.Entry) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].LastUsed.After(entries[j].LastUsed) })
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kesselborn/jindra/store"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "jindra-cache")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %s", err)
+	}
+
+	return dir
+}
+
+func TestKey(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	goSum := path.Join(dir, "go.sum")
+	ioutil.WriteFile(goSum, []byte("github.com/foo/bar v1.0.0 h1:abc"), 0644)
+
+	key, err := Key("go-mod", []string{goSum})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(key, "go-mod/") || !strings.HasSuffix(key, ".tar.gz") {
+		t.Errorf("expected key of the form go-mod/<hash>.tar.gz, got %s", key)
+	}
+
+	if again, _ := Key("go-mod", []string{goSum}); again != key {
+		t.Errorf("expected key to be stable, got %s and %s", key, again)
+	}
+
+	ioutil.WriteFile(goSum, []byte("github.com/foo/bar v1.1.0 h1:def"), 0644)
+	if changed, _ := Key("go-mod", []string{goSum}); changed == key {
+		t.Errorf("expected key to change with the content of the key file")
+	}
+
+	if key, _ := Key("go-mod", nil); key != "go-mod/default.tar.gz" {
+		t.Errorf("expected default key for caches without key files, got %s", key)
+	}
+
+	if _, err := Key("go-mod", []string{path.Join(dir, "missing")}); err == nil {
+		t.Errorf("expected error for missing key file")
+	}
+}
+
+func TestSaveAndRestore(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s := store.NewDirStore(path.Join(dir, "store"))
+
+	src := path.Join(dir, "src")
+	os.MkdirAll(path.Join(src, "pkg", "mod"), 0755)
+	ioutil.WriteFile(path.Join(src, "pkg", "mod", "module.zip"), []byte("zip"), 0644)
+	ioutil.WriteFile(path.Join(src, "tool"), []byte("#!/bin/sh"), 0755)
+	os.Symlink("tool", path.Join(src, "link"))
+
+	if key, err := Restore(s, "go-mod", "go-mod/a.tar.gz", path.Join(dir, "empty")); err != nil || key != "" {
+		t.Fatalf("expected empty cache to restore nothing, got '%s' (%v)", key, err)
+	}
+
+	if err := Save(s, "go-mod/a.tar.gz", src); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	dst := path.Join(dir, "dst")
+	if key, err := Restore(s, "go-mod", "go-mod/a.tar.gz", dst); err != nil || key != "go-mod/a.tar.gz" {
+		t.Fatalf("expected go-mod/a.tar.gz to be restored, got '%s' (%v)", key, err)
+	}
+
+	if content, _ := ioutil.ReadFile(path.Join(dst, "pkg", "mod", "module.zip")); string(content) != "zip" {
+		t.Errorf("expected pkg/mod/module.zip to be restored, got '%s'", string(content))
+	}
+	if info, err := os.Stat(path.Join(dst, "tool")); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("expected file mode to be preserved, got %v (%v)", info, err)
+	}
+	if link, err := os.Readlink(path.Join(dst, "link")); err != nil || link != "tool" {
+		t.Errorf("expected symlink to be preserved, got '%s' (%v)", link, err)
+	}
+
+	// a key without entry falls back to the most recently used entry
+	fallback := path.Join(dir, "fallback")
+	if key, err := Restore(s, "go-mod", "go-mod/b.tar.gz", fallback); err != nil || key != "go-mod/a.tar.gz" {
+		t.Fatalf("expected fallback to go-mod/a.tar.gz, got '%s' (%v)", key, err)
+	}
+
+	if key, err := Restore(s, "npm", "npm/a.tar.gz", path.Join(dir, "npm")); err != nil || key != "" {
+		t.Errorf("expected entries of other caches to be ignored, got '%s' (%v)", key, err)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	s := store.NewDirStore(dir)
+	now := time.Now()
+	for i, key := range []string{"go-mod/old.tar.gz", "go-mod/mid.tar.gz", "go-mod/new.tar.gz", "npm/other.tar.gz"} {
+		s.Put(key, strings.NewReader(strings.Repeat("x", 100)), 100)
+		used := now.Add(time.Duration(i-4) * time.Hour)
+		os.Chtimes(path.Join(dir, key), used, used)
+	}
+
+	deleted, err := Evict(s, "go-mod", "go-mod/old.tar.gz", 250)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if expected := []string{"go-mod/mid.tar.gz"}; !reflect.DeepEqual(expected, deleted) {
+		t.Errorf("expected %v to be evicted, got %v", expected, deleted)
+	}
+
+	entries, _ := s.List("")
+	keys := []string{}
+	for _, entry := range entries {
+		keys = append(keys, entry.Key)
+	}
+	if expected := []string{"go-mod/new.tar.gz", "go-mod/old.tar.gz", "npm/other.tar.gz"}; !reflect.DeepEqual(expected, keys) {
+		t.Errorf("expected entries %v, got %v", expected, keys)
+	}
+}
@@ -1,18 +1,23 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"text/template"
 	"time"
 
 	"github.com/kesselborn/jindra/crij"
+	"github.com/kesselborn/jindra/secrets"
+	"github.com/kesselborn/jindra/semaphore"
 )
 
 func formattedJson(jsonString string, prefix string) string {
@@ -75,70 +80,146 @@ EOF
 	}
 }
 
-func callScript(jsonString, prefix string, waitOnFail bool, stdoutFile, stderrFile string, debugOut string, args []string) {
-	cmd := exec.Command(args[0], args[1:]...)
-	stdin, err := cmd.StdinPipe()
+// output returns a writer that writes to the console (with every line prefixed
+// by linePrefix) and to file -- if file is the console itself, it is only
+// written once. As resources often demand an empty resource directory, the
+// output is written live to a temporary file outside of the resource
+// directory which is moved to file by the returned finish function.
+func output(console *os.File, file, linePrefix string) (io.Writer, func(), error) {
+	consoleWriter := crij.NewPrefixWriter(console, linePrefix)
+	if file == "" || file == console.Name() {
+		return consoleWriter, func() {}, nil
+	}
+
+	f, err := ioutil.TempFile("", "crij-"+filepath.Base(file))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	finished := false
+	finish := func() {
+		if finished {
+			return
+		}
+		finished = true
+
+		defer os.Remove(f.Name())
+		f.Close()
+		if err := moveFile(f.Name(), file); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing output file %s: %s\n", file, err)
+		}
+	}
+
+	return io.MultiWriter(f, consoleWriter), finish, nil
+}
+
+// moveFile renames src to dst -- if they are on different file systems (the
+// resource directory is a volume), src is copied
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func callScript(script crij.Script, prefix string, waitOnFail bool, stdoutFile, stderrFile string, debugOut string) {
+	linePrefix := ""
+	if prefix != "" {
+		linePrefix = "[" + prefix + "] "
+	}
+
+	stdout, finishStdout, err := output(os.Stdout, stdoutFile, linePrefix)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error executing %s: %s\n", cmd.String(), err)
+		dumpDebugInfo(debugOut, prefix, script.Stdin)
+		fmt.Fprintf(os.Stderr, "error opening stdout file %s: %s\n", stdoutFile, err)
 		os.Exit(1)
 	}
+	defer finishStdout()
 
-	_, err = io.WriteString(stdin, jsonString)
+	stderr, finishStderr, err := output(os.Stderr, stderrFile, linePrefix)
 	if err != nil {
-		dumpDebugInfo(debugOut, prefix, jsonString)
-		fmt.Fprintf(os.Stderr, "error writing json to stdin %s\n", err)
+		dumpDebugInfo(debugOut, prefix, script.Stdin)
+		fmt.Fprintf(os.Stderr, "error opening stderr file %s: %s\n", stderrFile, err)
 		os.Exit(1)
 	}
-	stdin.Close()
+	defer finishStderr()
 
-	var outbuf, errbuf bytes.Buffer
-	cmd.Stdout = &outbuf
-	cmd.Stderr = &errbuf
+	script.Stdout = stdout
+	script.Stderr = stderr
 
-	dumpInfo := func() {
-		err = ioutil.WriteFile(stdoutFile, outbuf.Bytes(), 0644)
-		if err != nil {
-			dumpDebugInfo(debugOut, prefix, jsonString)
-			fmt.Fprintf(os.Stderr, "error writing stdout file %s: %s\n", stdoutFile, err)
-			os.Exit(1)
-		}
-		fmt.Fprintf(os.Stdout, "%s", string(outbuf.Bytes()))
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT)
+	defer signal.Stop(signals)
+	script.Signals = signals
 
-		err = ioutil.WriteFile(stderrFile, errbuf.Bytes(), 0644)
-		if err != nil {
-			dumpDebugInfo(debugOut, prefix, jsonString)
-			fmt.Fprintf(os.Stderr, "error writing stderr file %s: %s\n", stderrFile, err)
-			os.Exit(1)
-		}
-		fmt.Fprintf(os.Stderr, "%s", string(errbuf.Bytes()))
-	}
+	cmdString := strings.Join(script.Args, " ")
 
-	if err := cmd.Run(); err != nil {
+	if err := script.Run(context.Background()); err != nil {
 		fmt.Printf("error executing script: %s ... execute with options '-wait-on-fail' to leave the container running for 5 more minutes) \n", err)
-		dumpInfo()
+		finishStdout()
+		finishStderr()
 		if waitOnFail {
-			dumpDebugInfo(debugOut, prefix, jsonString)
+			dumpDebugInfo(debugOut, prefix, script.Stdin)
 			time.Sleep(5 * time.Minute)
 		}
-		fmt.Fprintf(os.Stderr, "error executing %s: %s\n", cmd.String(), err)
+		fmt.Fprintf(os.Stderr, "error executing %s: %s\n", cmdString, err)
 		os.Exit(1)
 	}
-	dumpInfo()
 
-	fmt.Printf("successfully called %s!\n", cmd.String())
+	fmt.Printf("successfully called %s!\n", cmdString)
 
 }
 
+// resolveVaultSecrets replaces references to vault secrets in the env vars of
+// the resource with their values
+func resolveVaultSecrets(prefix, address string, kvVersion int, authPath, role, jwtFile string) error {
+	vault := secrets.NewVault(address, kvVersion, os.Getenv("VAULT_TOKEN"))
+	if os.Getenv("VAULT_TOKEN") == "" {
+		jwt, err := ioutil.ReadFile(jwtFile)
+		if err != nil {
+			return fmt.Errorf("error reading service account token: %s", err)
+		}
+		if err := vault.Login(authPath, role, strings.TrimSpace(string(jwt))); err != nil {
+			return err
+		}
+	}
+
+	return crij.ResolveSecrets(prefix, map[string]secrets.Provider{"vault": vault})
+}
+
 func main() {
 	prefix := flag.String("env-prefix", "", "only env vars with this prefix will be used -- prefix is separated by a '.' (i.e. prefix for env var 'git.source.url' would be git)")
 	waitOnFail := flag.Bool("wait-on-fail", false, "leave container live for 5 more minutes if the script fails (for debugging purposes)")
 	semaphoreFile := flag.String("semaphore-file", "", "file to watch ... program will start once this file DOES NOT EXIST")
 	envFile := flag.String("env-file", "", "file with simple env variables (no interpolation, no multiline values)")
 	ignoreMissingEnvFile := flag.Bool("ignore-missing-env-file", false, "don't file, if provided env file does not exist")
-	stdoutFile := flag.String("stdout-file", "/dev/stdout", "where to print resources stdout output")
-	stderrFile := flag.String("stderr-file", "/dev/stderr", "where to print resources stdout output")
+	stdoutFile := flag.String("stdout-file", "", "file to additionally write the resources stdout output to (output is always streamed to stdout)")
+	stderrFile := flag.String("stderr-file", "", "file to additionally write the resources stderr output to (output is always streamed to stderr)")
 	debugOut := flag.String("debug-out", "", "dump debugging information into the specified file (*NOTE*: this can contain sensitive data like passwords, etc.)")
 	justJSON := flag.Bool("just-print-json", false, "don't execute resource, just print the json that would be passed to the resource")
+	timeout := flag.Duration("timeout", 0, "terminate the resource script if a single attempt takes longer than this (0 means: no timeout)")
+	retries := flag.Int("retries", 0, "number of retries if the resource script fails")
+	retryBackoff := flag.Duration("retry-backoff", 5*time.Second, "time to wait before the first retry -- doubled for every further retry")
+	cleanDirOnRetry := flag.Bool("clean-dir-on-retry", false, "empty the directory (last argument) before every retry -- in scripts expect an empty directory")
+	vaultAddress := flag.String("vault-address", "", "address of the vault server that resolves references to secrets ('((vault:<path>#<key>))') -- uses the env var VAULT_TOKEN or logs in with the kubernetes auth method")
+	vaultRole := flag.String("vault-role", "", "role of the kubernetes auth method")
+	vaultAuthPath := flag.String("vault-auth-path", "kubernetes", "mount path of the kubernetes auth method")
+	vaultKVVersion := flag.Int("vault-kv-version", 2, "version of the KV secrets engine")
+	vaultJWTFile := flag.String("vault-jwt-file", "/var/run/secrets/kubernetes.io/serviceaccount/token", "service account token used to log in with the kubernetes auth method")
 	deleteEnvFileAfterRead := flag.Bool("delete-env-file-after-read", false, "delete env file after it was read: this can be necessary if the env file resides in the resource directory as resources sometimes demand an empty directory")
 	flag.Parse()
 
@@ -169,19 +250,18 @@ func main() {
 	}
 
 	fmt.Fprintf(os.Stderr, "waiting for %s to go away, writing stdout to %s, stderr to %s ", *semaphoreFile, *stdoutFile, *stderrFile)
-	for {
-		_, err := os.Stat(*semaphoreFile)
-		if err != nil {
-			if os.IsNotExist(err) {
-				break
-			}
-			fmt.Fprintf(os.Stderr, "error stating %s: %s, continuing anyways", *semaphoreFile, err)
-		}
-		fmt.Printf(".")
-		time.Sleep(1 * time.Second)
+	if err := semaphore.WaitForDeletion(context.Background(), 0, *semaphoreFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error waiting for %s: %s, continuing anyways", *semaphoreFile, err)
 	}
 	fmt.Println(" done")
 
+	if *vaultAddress != "" {
+		if err := resolveVaultSecrets(*prefix, *vaultAddress, *vaultKVVersion, *vaultAuthPath, *vaultRole, *vaultJWTFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+
 	s, err := crij.EnvToJSON(*prefix)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error converting env to json: %s\n", err)
@@ -193,5 +273,15 @@ func main() {
 		os.Exit(0)
 	}
 
-	callScript(s, *prefix, *waitOnFail, *stdoutFile, *stderrFile, *debugOut, flag.Args())
+	script := crij.Script{
+		Args:         flag.Args(),
+		Stdin:        s,
+		Timeout:      *timeout,
+		Retries:      *retries,
+		RetryBackoff: *retryBackoff,
+	}
+	if *cleanDirOnRetry && flag.NArg() > 1 {
+		script.CleanDir = flag.Arg(flag.NArg() - 1)
+	}
+	callScript(script, *prefix, *waitOnFail, *stdoutFile, *stderrFile, *debugOut)
 }
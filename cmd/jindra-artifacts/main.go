@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/kesselborn/jindra/artifacts"
+	"github.com/kesselborn/jindra/store"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `
+Uploads the artifacts of a jindra stage and prunes the artifacts of old runs
+
+%s [OPTIONS] upload <path> [<path> ...]
+
+paths are relative to the resources directory; s3 credentials are read from
+the env vars JINDRA_ARTIFACTS_ACCESS_KEY_ID and JINDRA_ARTIFACTS_SECRET_ACCESS_KEY.
+
+OPTIONS:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	run := flag.Int("run", 0, "number of the pipeline run")
+	stage := flag.String("stage", "", "name of the stage")
+	dir := flag.String("dir", "/jindra/resources", "resources directory")
+	keepRuns := flag.Int("keep-runs", 0, "number of most recent runs whose artifacts are kept (0 means: all)")
+	maxAge := flag.Duration("max-age", 0, "delete the artifacts of runs older than this (0 means: never)")
+	storeDir := flag.String("store-dir", "", "directory of the store")
+	s3Endpoint := flag.String("s3-endpoint", "", "url of the s3 store")
+	s3Bucket := flag.String("s3-bucket", "", "bucket of the s3 store")
+	s3Prefix := flag.String("s3-prefix", "", "prefix of all objects of the s3 store")
+	s3Region := flag.String("s3-region", "", "region of the s3 store (default: us-east-1)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 2 || flag.Arg(0) != "upload" || *run == 0 || *stage == "" || (*storeDir == "") == (*s3Endpoint == "") {
+		usage()
+		os.Exit(1)
+	}
+
+	var s store.Store
+	if *storeDir != "" {
+		s = store.NewDirStore(*storeDir)
+	} else {
+		var err error
+		s, err = store.NewS3Store(*s3Endpoint, *s3Bucket, *s3Prefix, *s3Region,
+			os.Getenv("JINDRA_ARTIFACTS_ACCESS_KEY_ID"), os.Getenv("JINDRA_ARTIFACTS_SECRET_ACCESS_KEY"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	uploaded, err := artifacts.Upload(s, *run, *stage, *dir, flag.Args()[1:])
+	for _, a := range uploaded {
+		fmt.Printf("uploaded artifact %s\n", a.Name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error uploading artifacts: %s\n", err)
+		os.Exit(1)
+	}
+
+	pruned, err := artifacts.Prune(s, artifacts.Retention{KeepRuns: *keepRuns, MaxAge: *maxAge}, *run, time.Now())
+	for _, r := range pruned {
+		fmt.Printf("deleted artifacts of run %d\n", r)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error pruning artifacts: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/kesselborn/jindra/cache"
+	"github.com/kesselborn/jindra/store"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `
+Restores and saves jindra cross-run caches
+
+%s [OPTIONS] restore|save
+
+restore extracts the entry of the current key (or the most recently used
+entry of the cache) into the cache directory; save archives the cache
+directory unless the entry of the current key was restored and evicts old
+entries afterwards. s3 credentials are read from the env vars
+JINDRA_CACHE_ACCESS_KEY_ID and JINDRA_CACHE_SECRET_ACCESS_KEY.
+
+OPTIONS:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	name := flag.String("name", "", "name of the cache")
+	dir := flag.String("dir", "", "cache directory")
+	keyFiles := flag.String("key-files", "", "comma separated list of files whose content makes up the cache key")
+	maxSize := flag.Int64("max-size", 0, "evict least recently used entries of the cache once they exceed this size in bytes (0 means: never evict)")
+	stateFile := flag.String("state-file", "", "restore writes the key and the restored entry to this file, save reads them from it")
+	storeDir := flag.String("store-dir", "", "directory of the store")
+	s3Endpoint := flag.String("s3-endpoint", "", "url of the s3 store")
+	s3Bucket := flag.String("s3-bucket", "", "bucket of the s3 store")
+	s3Prefix := flag.String("s3-prefix", "", "prefix of all objects of the s3 store")
+	s3Region := flag.String("s3-region", "", "region of the s3 store (default: us-east-1)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 || *name == "" || *dir == "" || (*storeDir == "") == (*s3Endpoint == "") {
+		usage()
+		os.Exit(1)
+	}
+
+	var s store.Store
+	if *storeDir != "" {
+		s = store.NewDirStore(*storeDir)
+	} else {
+		var err error
+		s, err = store.NewS3Store(*s3Endpoint, *s3Bucket, *s3Prefix, *s3Region,
+			os.Getenv("JINDRA_CACHE_ACCESS_KEY_ID"), os.Getenv("JINDRA_CACHE_SECRET_ACCESS_KEY"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	files := []string{}
+	if *keyFiles != "" {
+		files = strings.Split(*keyFiles, ",")
+	}
+
+	var err error
+	switch flag.Arg(0) {
+	case "restore":
+		err = restore(s, *name, *dir, files, *stateFile)
+	case "save":
+		err = save(s, *name, *dir, files, *stateFile, *maxSize)
+	default:
+		usage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func restore(s store.Store, name, dir string, files []string, stateFile string) error {
+	key, err := cache.Key(name, files)
+	if err != nil {
+		return err
+	}
+
+	restored, err := cache.Restore(s, name, key, dir)
+	if err != nil {
+		// a broken store must not fail the stage -- it just runs without cache
+		fmt.Fprintf(os.Stderr, "warning: error restoring cache %s: %s\n", name, err)
+	}
+
+	switch restored {
+	case "":
+		fmt.Printf("cache %s: miss (key %s)\n", name, key)
+	case key:
+		fmt.Printf("cache %s: hit (key %s)\n", name, key)
+	default:
+		fmt.Printf("cache %s: restored %s (key %s)\n", name, restored, key)
+	}
+
+	if stateFile != "" {
+		if err := ioutil.WriteFile(stateFile, []byte(key+"\n"+restored+"\n"), 0644); err != nil {
+			return fmt.Errorf("error writing state file: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func save(s store.Store, name, dir string, files []string, stateFile string, maxSize int64) error {
+	key, restored := "", ""
+	if content, err := ioutil.ReadFile(stateFile); stateFile != "" && err == nil {
+		lines := strings.Split(string(content), "\n")
+		key, restored = lines[0], lines[1]
+	} else {
+		var err error
+		if key, err = cache.Key(name, files); err != nil {
+			return err
+		}
+	}
+
+	// caches without key files are saved after every run as there is no way
+	// to tell whether they changed
+	if restored == key && len(files) > 0 {
+		fmt.Printf("cache %s: entry %s was restored, not saving\n", name, key)
+		return nil
+	}
+
+	if err := cache.Save(s, key, dir); err != nil {
+		return err
+	}
+	fmt.Printf("cache %s: saved %s\n", name, key)
+
+	if maxSize > 0 {
+		evicted, err := cache.Evict(s, name, key, maxSize)
+		for _, e := range evicted {
+			fmt.Printf("cache %s: evicted %s\n", name, e)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,19 +1,35 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path"
 	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/ghodss/yaml"
 	jindra "github.com/kesselborn/jindra/api/v1alpha1"
+	jindrav1beta1 "github.com/kesselborn/jindra/api/v1beta1"
+	"github.com/kesselborn/jindra/artifacts"
+	"github.com/kesselborn/jindra/diff"
+	"github.com/kesselborn/jindra/localrun"
+	"github.com/kesselborn/jindra/resources"
+	"github.com/kesselborn/jindra/store"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
-	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
 func configMap(p jindra.Pipeline, buildNo int) {
@@ -53,6 +69,35 @@ func secret(p jindra.Pipeline, buildNo int) {
 	fmt.Println(interface2yaml(secret))
 }
 
+func transitObjects(p jindra.Pipeline, buildNo int) {
+	objects, err := p.TransitObjects(buildNo)
+	if err != nil {
+		log.Fatalf("error creating transit objects for pipeline run: %s", err)
+	}
+
+	for _, o := range objects {
+		fmt.Println("---")
+		fmt.Println(interface2yaml(o))
+	}
+}
+
+func rbacObjects(p jindra.Pipeline, buildNo int) {
+	runnerObjects, err := p.RunnerRBACObjects()
+	if err != nil {
+		log.Fatalf("error creating rbac objects of the runner: %s", err)
+	}
+
+	runObjects, err := p.RunRBACObjects(buildNo)
+	if err != nil {
+		log.Fatalf("error creating rbac objects for pipeline run: %s", err)
+	}
+
+	for _, o := range append(runnerObjects, runObjects...) {
+		fmt.Println("---")
+		fmt.Println(interface2yaml(o))
+	}
+}
+
 func stage(p jindra.Pipeline, buildNo int, stageKey string) {
 	cm, err := p.PipelineRunConfigMap(buildNo)
 	if err != nil {
@@ -83,22 +128,306 @@ func stageNames(p jindra.Pipeline, buildNo int) {
 	fmt.Println(strings.Join(names, "\n"))
 }
 
-func validate(p jindra.Pipeline) {
-	err := p.Validate()
+func listArtifacts(s store.Store, run int, stage string) error {
+	list, err := artifacts.List(s, run, stage)
+	if err != nil {
+		return fmt.Errorf("error listing artifacts: %s", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "RUN\tSTAGE\tARTIFACT\tSIZE\tCREATED")
+	for _, a := range list {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n", a.Run, a.Stage, a.Name, a.Size, a.Created.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func downloadArtifact(s store.Store, artifact artifacts.Artifact, dir string) error {
+	if err := artifacts.Download(s, artifact, dir); err != nil {
+		return fmt.Errorf("error downloading artifact: %s", err)
+	}
+
+	fmt.Printf("downloaded artifact %s to %s\n", artifact.Name, dir)
+	return nil
+}
+
+// openArtifactStore opens the artifact store of the pipeline -- the claim of
+// a pvc store is read through a short-lived reader pod in the cluster, unless
+// storeDir points to its content (e.g. a local copy); the returned func
+// removes the reader pod
+func openArtifactStore(p jindra.Pipeline, storeDir string) (store.Store, func(), error) {
+	objectStore, location := p.ArtifactStore()
+	if objectStore.S3 != nil {
+		s, err := store.NewS3Store(objectStore.S3.Endpoint, objectStore.S3.Bucket, location, objectStore.S3.Region,
+			os.Getenv("JINDRA_ARTIFACTS_ACCESS_KEY_ID"), os.Getenv("JINDRA_ARTIFACTS_SECRET_ACCESS_KEY"))
+		return s, func() {}, err
+	}
+
+	if storeDir != "" {
+		return store.NewDirStore(path.Join(storeDir, location)), func() {}, nil
+	}
+
+	pod, dir, err := p.ArtifactReaderPod()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name, err := startReaderPod(pod)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading the claim '%s': %s", objectStore.ClaimName, err)
+	}
+
+	return store.NewKubectlStore(pod.Namespace, name, dir), func() { deleteReaderPod(pod.Namespace, name) }, nil
+}
+
+// readerPodTimeout is how long the cli waits for the reader pod of a pvc
+// artifact store to become ready
+const readerPodTimeout = "2m"
+
+// kubectl runs kubectl in namespace (if it is set) and returns its output
+func kubectl(namespace string, stdin []byte, args ...string) (string, error) {
+	if namespace != "" {
+		args = append([]string{"--namespace", namespace}, args...)
+	}
+
+	cmd := exec.Command("kubectl", args...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("kubectl %s: %s", args[0], err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// startReaderPod creates the reader pod and waits until it is ready -- it
+// returns the name of the pod
+func startReaderPod(pod core.Pod) (string, error) {
+	pod.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"}
+	src, err := yaml.Marshal(pod)
+	if err != nil {
+		return "", fmt.Errorf("error converting reader pod to yaml: %s", err)
+	}
 
+	name, err := kubectl(pod.Namespace, src, "create", "-o", "name", "-f", "-")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "pipeline config is invalid: \n%s\n", err)
+		return "", err
+	}
+
+	if _, err := kubectl(pod.Namespace, nil, "wait", "--for=condition=Ready", "--timeout="+readerPodTimeout, name); err != nil {
+		deleteReaderPod(pod.Namespace, name)
+		return "", err
+	}
+
+	return strings.TrimPrefix(name, "pod/"), nil
+}
+
+func deleteReaderPod(namespace, name string) {
+	if _, err := kubectl(namespace, nil, "delete", "pod", strings.TrimPrefix(name, "pod/"), "--wait=false"); err != nil {
+		log.Printf("error deleting reader pod %s: %s", name, err)
+	}
+}
+
+func artifactsCmd(p jindra.Pipeline, storeDir string, args []string) {
+	run := 0
+	if len(args) > 1 {
+		var err error
+		if run, err = strconv.Atoi(args[1]); err != nil {
+			log.Fatalf("invalid run number '%s'", args[1])
+		}
+	}
+
+	var cmd func(store.Store) error
+	switch {
+	case len(args) == 0 || (args[0] == "list" && len(args) <= 3):
+		stage := ""
+		if len(args) > 2 {
+			stage = args[2]
+		}
+		cmd = func(s store.Store) error { return listArtifacts(s, run, stage) }
+	case args[0] == "get" && (len(args) == 4 || len(args) == 5):
+		dir := "."
+		if len(args) == 5 {
+			dir = args[4]
+		}
+		artifact := artifacts.Artifact{Run: run, Stage: args[2], Name: args[3]}
+		cmd = func(s store.Store) error { return downloadArtifact(s, artifact, dir) }
+	default:
+		log.Fatalf("usage: artifacts [list [RUN [STAGE]]] | artifacts get RUN STAGE ARTIFACT [DIR]")
+	}
+
+	s, cleanup, err := openArtifactStore(p, storeDir)
+	if err != nil {
+		log.Fatalf("error opening artifact store: %s", err)
+	}
+
+	err = cmd(s)
+	cleanup()
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+}
+
+func runCmd(p jindra.Pipeline, buildNo int, args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	local := flags.Bool("local", false, "run the stages with a local container runtime (required)")
+	runtime := flags.String("runtime", "", "container runtime: docker or podman (default: the one found in PATH)")
+	dir := flags.String("dir", ".jindra", "directory for the volumes of the stages and the transit channels")
+	secretsDir := flags.String("secrets-dir", "", "directory with the secrets the pipeline references (<dir>/<secret>/<key>)")
+	flags.Parse(args)
+
+	if !*local {
+		log.Fatalf("usage: run -local [-runtime docker|podman] [-dir DIR] [-secrets-dir DIR] (only local runs are supported)")
+	}
+
+	r := &localrun.Run{Pipeline: p, BuildNo: buildNo, Dir: *dir, SecretsDir: *secretsDir, Out: os.Stdout}
+	if *runtime == "" {
+		cli, err := localrun.DetectRuntime()
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		r.Runtime = cli
+	} else {
+		r.Runtime = localrun.CLI{Command: *runtime}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		fmt.Fprintln(os.Stderr, "stopping run ...")
+		r.Stop()
+	}()
+
+	if err := r.Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "run failed: %s\n", err)
 		os.Exit(1)
 	}
 
+	fmt.Println("run succeeded")
+}
+
+func graph(p jindra.Pipeline, format string) {
+	switch format {
+	case "", "dot":
+		fmt.Print(p.DOT())
+	case "mermaid":
+		fmt.Print(p.Mermaid())
+	default:
+		log.Fatalf("unknown graph format '%s' (dot or mermaid)", format)
+	}
+}
+
+// diffTroubleExitCode is the exit code of diff if the pipelines can't be
+// compared -- 1 means that there are changes
+const diffTroubleExitCode = 2
+
+// diffCmd prints the changes of the objects generated for a run of the new
+// pipeline -- it exits with 1 if there are changes and with 2 if the
+// pipelines can't be compared, like diff
+func diffCmd(oldFile, newFile string, resourceTypes string, setDefaults bool, buildNo int) {
+	fail := func(err error) {
+		log.Printf("%s", err)
+		os.Exit(diffTroubleExitCode)
+	}
+
+	pipelines := []jindra.Pipeline{}
+	for _, file := range []string{oldFile, newFile} {
+		yamlData, err := readInput(file)
+		if err != nil {
+			fail(err)
+		}
+		p, err := parsePipeline(yamlData, resourceTypes, setDefaults)
+		if err != nil {
+			fail(err)
+		}
+		pipelines = append(pipelines, p)
+	}
+
+	sections, err := diff.Pipelines(pipelines[0], pipelines[1], buildNo)
+	if err != nil {
+		fail(err)
+	}
+
+	if len(sections) == 0 {
+		fmt.Println("no changes")
+		return
+	}
+
+	for _, section := range sections {
+		switch section.Kind {
+		case diff.Added:
+			fmt.Printf("%s %s (added)\n", diff.Added, section.Name)
+		case diff.Removed:
+			fmt.Printf("%s %s (removed)\n", diff.Removed, section.Name)
+		default:
+			fmt.Printf("%s %s\n", diff.Modified, section.Name)
+			for _, change := range section.Changes {
+				fmt.Printf("  %s\n", strings.Replace(change.String(), "\n", "\n  ", -1))
+			}
+		}
+	}
+	os.Exit(1)
+}
+
+func validate(p jindra.Pipeline) {
+	exitOnErrors(p.ValidationErrors())
+
+	for _, warning := range p.ValidationWarnings() {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", warning)
+	}
+
 	fmt.Println("pipeline config is valid")
 }
 
+// validateStructure checks the pipeline config against the JSON schema --
+// unknown fields or values of the wrong type are reported before the
+// config is parsed
+func validateStructure(yamlData []byte) {
+	jsonData, err := yaml.YAMLToJSON(yamlData)
+	if err != nil {
+		log.Fatalf("cannot convert yaml to json: %s", err)
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		log.Fatalf("cannot parse pipeline config: %s", err)
+	}
+
+	exitOnErrors(jindrav1beta1.PipelineSchema().Validate(doc))
+}
+
+func exitOnErrors(errs field.ErrorList) {
+	if len(errs) == 0 {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "pipeline config is invalid:")
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "  %s\n", err)
+	}
+	os.Exit(1)
+}
+
+func schema() {
+	jsonTxt, err := json.MarshalIndent(jindrav1beta1.PipelineSchema(), "", "  ")
+	if err != nil {
+		log.Fatalf("error marshalling schema to json: %s", err)
+	}
+
+	fmt.Println(string(jsonTxt))
+}
+
 func main() {
+	jindra.SetNativeResourceRegistry(resources.Registry{})
+
 	buildNo := flag.Int("b", 42, "build number")
 	setDefaults := flag.Bool("d", true, "set default values before executing command")
 	runValidator := flag.Bool("v", true, "run validation before executing command")
 	config := flag.String("c", "", "jindra pipeline config (use '-c -' for reading from stdin)")
+	resourceTypes := flag.String("t", "", "file with ResourceType and ClusterResourceType definitions referenced by the pipeline")
+	storeDir := flag.String("store-dir", "", "local copy of the claim of a pvc artifact store (artifacts command; default: read the claim through a reader pod)")
 	help := flag.Bool("h", false, "show help text")
 	verbose := flag.Bool("verbose", false, "verbose output")
 
@@ -110,12 +439,31 @@ Commands:
   all         : print all configs necessary to run the pipeline (can be piped into 'kubectl apply -f-')
   stage STAGE : print stage configuration
   stagenames  : print stage names (which can be used with the stage sub command)
+  graph [dot|mermaid]
+              : print the stages, their order and the resources they use as Graphviz (default) or Mermaid graph
   configmap   : print configmap
   runner      : print runner pod
   secret      : print secret
+  transit     : print objects of the transit backend (rsync: secret, pvc: persistent volume claim)
+  rbac        : print service account, roles and role bindings of the runner (the operator creates the
+                service account and its role for every pipeline, the role of the run is printed as well)
+
+  artifacts [list [RUN [STAGE]]]          : list artifacts (s3 credentials are read from
+                                            JINDRA_ARTIFACTS_ACCESS_KEY_ID and JINDRA_ARTIFACTS_SECRET_ACCESS_KEY)
+  artifacts get RUN STAGE ARTIFACT [DIR] : download an artifact to DIR (default: .)
+
+  run -local [-runtime docker|podman] [-dir DIR] [-secrets-dir DIR]
+              : run the stages with docker or podman -- volumes and transit channels are directories in DIR
+                (default: .jindra), secrets are read from <secrets-dir>/<secret>/<key>
+
+  diff OLD NEW
+              : print the changes of the objects generated for two pipeline files (stage pods, config map, runner
+                pod, transit objects), stages are compared by name (no -c needed, exits with 1 if there are changes,
+                2 on errors)
 
   defaulter   : print config with default values
-  validate    : validate pipeline file
+  validate    : validate pipeline file (structure and content)
+  schema      : print the JSON schema of pipelines (v1alpha1 and v1beta1, no config needed)
 
 Options: 
 `, os.Args[0])
@@ -140,31 +488,31 @@ Options:
 		os.Exit(exitCode)
 	}
 
-	if config == nil || *config == "" || *help {
-		usage(*help)
+	if flag.Arg(0) == "schema" {
+		schema()
+		return
 	}
 
-	var err error
-	var yamlData []byte
-
-	if *config == "-" {
-		yamlData, err = ioutil.ReadAll(os.Stdin)
-	} else {
-		yamlData, err = ioutil.ReadFile(*config)
+	if flag.Arg(0) == "diff" {
+		if flag.NArg() != 3 {
+			flag.CommandLine.SetOutput(os.Stderr)
+			flag.Usage()
+			os.Exit(diffTroubleExitCode)
+		}
+		diffCmd(flag.Arg(1), flag.Arg(2), *resourceTypes, *setDefaults, *buildNo)
+		return
 	}
 
-	if err != nil {
-		log.Fatalf("error reading file %s: %s", *config, err)
+	if config == nil || *config == "" || *help {
+		usage(*help)
 	}
 
-	p, err := jindra.NewPipelineFromYaml(yamlData)
-	if err != nil {
-		log.Fatalf("cannot convert yaml to jindra pipeline: %s", err)
+	yamlData := readFile(*config)
+	if flag.Arg(0) == "validate" {
+		validateStructure(yamlData)
 	}
 
-	if *setDefaults {
-		p.SetDefaults()
-	}
+	p := loadPipeline(yamlData, *resourceTypes, *setDefaults)
 
 	if *runValidator {
 		p.Validate()
@@ -172,17 +520,28 @@ Options:
 
 	switch flag.Arg(0) {
 	case "all":
-		secret(p, *buildNo)
+		rbacObjects(p, *buildNo)
+		transitObjects(p, *buildNo)
 		configMap(p, *buildNo)
 		runner(p, *buildNo)
+	case "artifacts":
+		artifactsCmd(p, *storeDir, flag.Args()[1:])
 	case "configmap":
 		configMap(p, *buildNo)
 	case "defaulter":
 		defaulter(p)
+	case "graph":
+		graph(p, flag.Arg(1))
+	case "rbac":
+		rbacObjects(p, *buildNo)
+	case "run":
+		runCmd(p, *buildNo, flag.Args()[1:])
 	case "runner":
 		runner(p, *buildNo)
 	case "secret":
 		secret(p, *buildNo)
+	case "transit":
+		transitObjects(p, *buildNo)
 	case "stage":
 		if flag.Arg(1) == "" {
 			usage(false)
@@ -201,6 +560,101 @@ Options:
 	}
 }
 
+func readFile(file string) []byte {
+	yamlData, err := readInput(file)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	return yamlData
+}
+
+// readInput reads file -- '-' is stdin
+func readInput(file string) ([]byte, error) {
+	var err error
+	var yamlData []byte
+
+	if file == "-" {
+		yamlData, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		yamlData, err = ioutil.ReadFile(file)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %s", file, err)
+	}
+
+	return yamlData, nil
+}
+
+// loadPipeline parses the pipeline and resolves the resource types it
+// references
+func loadPipeline(yamlData []byte, resourceTypes string, setDefaults bool) jindra.Pipeline {
+	p, err := parsePipeline(yamlData, resourceTypes, setDefaults)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	return p
+}
+
+// parsePipeline is loadPipeline, but returns errors
+func parsePipeline(yamlData []byte, resourceTypes string, setDefaults bool) (jindra.Pipeline, error) {
+	p, err := pipelineFromYaml(yamlData)
+	if err != nil {
+		return p, fmt.Errorf("cannot convert yaml to jindra pipeline: %s", err)
+	}
+
+	if resourceTypes != "" {
+		yamlData, err := ioutil.ReadFile(resourceTypes)
+		if err != nil {
+			return p, fmt.Errorf("error reading file %s: %s", resourceTypes, err)
+		}
+
+		catalog, err := jindra.NewResourceTypeCatalogFromYaml(yamlData)
+		if err != nil {
+			return p, fmt.Errorf("cannot convert yaml to resource types: %s", err)
+		}
+
+		if err := p.ResolveResourceTypes(catalog); err != nil {
+			return p, err
+		}
+	}
+
+	if setDefaults {
+		p.SetDefaults()
+	}
+
+	return p, nil
+}
+
+// pipelineFromYaml reads v1alpha1 and v1beta1 pipelines -- v1beta1 pipelines
+// are converted to v1alpha1 like the conversion webhook does
+func pipelineFromYaml(yamlData []byte) (jindra.Pipeline, error) {
+	var typeMeta struct {
+		APIVersion string `json:"apiVersion"`
+	}
+	if err := yaml.Unmarshal(yamlData, &typeMeta); err != nil {
+		return jindra.Pipeline{}, err
+	}
+
+	if typeMeta.APIVersion != jindrav1beta1.GroupVersion.String() {
+		return jindra.NewPipelineFromYaml(yamlData)
+	}
+
+	v1beta1Ppl, err := jindrav1beta1.NewPipelineFromYaml(yamlData)
+	if err != nil {
+		return jindra.Pipeline{}, err
+	}
+
+	var p jindra.Pipeline
+	if err := v1beta1Ppl.ConvertTo(&p); err != nil {
+		return jindra.Pipeline{}, fmt.Errorf("error converting v1beta1 pipeline: %s", err)
+	}
+
+	return p, nil
+}
+
 func interface2yaml(x interface{}) string {
 	jsonTxt, err := json.Marshal(x)
 	if err != nil {
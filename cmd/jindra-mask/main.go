@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kesselborn/jindra/mask"
+	core "k8s.io/api/core/v1"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `
+Copies stdin to stdout and replaces the values of secrets with %s
+
+%s [OPTIONS]
+
+OPTIONS:
+`, mask.Mask, os.Args[0])
+	flag.PrintDefaults()
+}
+
+// readSecrets returns the values of the secrets of file -- the output of
+// 'kubectl get secret ... -ojson', i.e. a secret or a list of secrets
+func readSecrets(file string) ([]string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading secrets file: %s", err)
+	}
+
+	list := struct {
+		Kind  string        `json:"kind"`
+		Items []core.Secret `json:"items"`
+	}{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("error parsing secrets file: %s", err)
+	}
+
+	if list.Kind == "Secret" {
+		secret := core.Secret{}
+		if err := json.Unmarshal(data, &secret); err != nil {
+			return nil, fmt.Errorf("error parsing secrets file: %s", err)
+		}
+		list.Items = []core.Secret{secret}
+	}
+
+	values := []string{}
+	for _, secret := range list.Items {
+		for _, value := range secret.Data {
+			values = append(values, string(value))
+		}
+	}
+
+	return values, nil
+}
+
+func main() {
+	secretsFile := flag.String("secrets-file", "", "json file with the secrets to mask (output of 'kubectl get secret ... -ojson')")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *secretsFile == "" {
+		usage()
+		os.Exit(1)
+	}
+
+	values, err := readSecrets(*secretsFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+
+	if err := mask.New(values...).Copy(os.Stdout, os.Stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "error masking secrets: %s\n", err)
+		os.Exit(1)
+	}
+}
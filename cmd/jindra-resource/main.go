@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kesselborn/jindra/resources"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `
+Native jindra resources -- they follow the concourse resource contract:
+the request is read from stdin, the response is written to stdout
+
+%s <resource> check
+%s <resource> in  <destination dir>
+%s <resource> out <source dir>
+
+Available resources: %s
+`, os.Args[0], os.Args[0], os.Args[0], strings.Join(resources.Names(), ", "))
+}
+
+func main() {
+	if len(os.Args) < 3 || (os.Args[2] != "check" && len(os.Args) < 4) {
+		usage()
+		os.Exit(1)
+	}
+
+	dir := ""
+	if len(os.Args) > 3 {
+		dir = os.Args[3]
+	}
+
+	if err := resources.Run(os.Args[1], os.Args[2], dir, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
@@ -8,9 +9,9 @@ import (
 	"net/http"
 	"os"
 	"strings"
-	"time"
 
 	"github.com/kesselborn/jindra/k8spodstatus"
+	"github.com/kesselborn/jindra/semaphore"
 )
 
 var debug *log.Logger
@@ -80,15 +81,9 @@ func main() {
 	}
 
 	go func() {
-		for {
-			_, err := os.Stat(*semaphoreFile)
-			if err != nil {
-				if os.IsNotExist(err) {
-					break
-				}
-				fmt.Fprintf(os.Stderr, "error stating %s: %s, continuing anyways", *semaphoreFile, err)
-			}
-			time.Sleep(1 * time.Second)
+		if err := semaphore.WaitForDeletion(context.Background(), 0, *semaphoreFile); err != nil {
+			fmt.Fprintf(os.Stderr, "error waiting for %s: %s -- exiting", *semaphoreFile, err)
+			os.Exit(1)
 		}
 		fmt.Printf("semaphore file %s went away -- exiting\n", *semaphoreFile)
 		os.Exit(0)
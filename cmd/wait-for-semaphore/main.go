@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/kesselborn/jindra/semaphore"
+)
+
+func usage() {
+	fmt.Printf(`
+Utility to block until semaphore files were deleted (default) or created
+
+%s [OPTIONS] <file> [<file> ...]
+
+OPTIONS:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	created := flag.Bool("created", false, "wait for the files to be created instead of deleted")
+	timeout := flag.Duration("timeout", 0, "give up after this duration (0 means: wait forever)")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	event := semaphore.Deleted
+	if *created {
+		event = semaphore.Created
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		cancel()
+	}()
+
+	if err := semaphore.Wait(ctx, event, *timeout, flag.Args()...); err != nil {
+		fmt.Fprintf(os.Stderr, "error waiting for %s of %v: %s\n", event, flag.Args(), err)
+		os.Exit(1)
+	}
+}
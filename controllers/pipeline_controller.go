@@ -17,16 +17,36 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"time"
 
 	"github.com/go-logr/logr"
+	core "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
 	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	jindra "github.com/kesselborn/jindra/api/v1alpha1"
 )
 
+// runsFinalizer makes sure the active runs of a pipeline are cancelled and
+// their objects are deleted before the pipeline is gone
+const runsFinalizer = "jindra.io/cancel-runs"
+
+// activeRunsRequeueInterval is the interval in which pipelines with active
+// runs are reconciled until the runs finished
+const activeRunsRequeueInterval = 10 * time.Second
+
 // PipelineReconciler reconciles a Pipeline object
 type PipelineReconciler struct {
 	client.Client
@@ -43,9 +63,16 @@ func ignoreNotFound(err error) error {
 
 // +kubebuilder:rbac:groups=ci.jindra.io,resources=pipelines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=ci.jindra.io,resources=pipelines/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=ci.jindra.io,resources=resourcetypes;clusterresourcetypes,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=get;list;watch;create;update;patch;delete
+// the manager can only grant permissions it has itself
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;patch;delete;deletecollection
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups="",resources=configmaps;secrets;persistentvolumeclaims,verbs=get;patch;deletecollection
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles;rolebindings,verbs=deletecollection
 
-func (r *PipelineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
-	ctx := context.Background()
+func (r *PipelineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("pipeline", req.NamespacedName, "ctx", ctx)
 
 	var ppl jindra.Pipeline
@@ -57,11 +84,261 @@ func (r *PipelineReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, ignoreNotFound(err)
 	}
 
+	activeRuns, err := jindra.ClientRunLister{Reader: r}.ActiveRuns(ppl)
+	if err != nil {
+		log.Error(err, "unable to list the active runs")
+		return ctrl.Result{}, err
+	}
+
+	if ppl.DeletionTimestamp != nil {
+		return r.finalize(ctx, ppl, activeRuns)
+	}
+
+	if !containsString(ppl.Finalizers, runsFinalizer) {
+		ppl.Finalizers = append(ppl.Finalizers, runsFinalizer)
+		if err := r.Update(ctx, &ppl); err != nil {
+			log.Error(err, "unable to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// resolved resource types are not stored with the pipeline
+	if err := ppl.ResolveResourceTypes(jindra.ClientResourceTypeGetter{Reader: r}); err != nil {
+		log.Error(err, "unable to resolve the resource types")
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileRunnerRBAC(ctx, ppl, len(activeRuns) > 0); err != nil {
+		log.Error(err, "unable to reconcile the rbac objects of the runner")
+		return ctrl.Result{}, err
+	}
+
+	for _, buildNo := range activeRuns {
+		if err := r.reconcileRunRBAC(ctx, ppl, buildNo); err != nil {
+			log.Error(err, "unable to reconcile the rbac objects of the run", "run", buildNo)
+			return ctrl.Result{}, err
+		}
+	}
+
+	// secrets that were removed from the runner role are removed after the
+	// active runs finished
+	if len(activeRuns) > 0 {
+		return ctrl.Result{RequeueAfter: activeRunsRequeueInterval}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// finalize cancels the active runs of a pipeline that is being deleted and
+// deletes their objects -- the finalizer is removed when all pods of the
+// runs are gone
+func (r *PipelineReconciler) finalize(ctx context.Context, ppl jindra.Pipeline, activeRuns []int) (ctrl.Result, error) {
+	log := r.Log.WithValues("pipeline", ppl.Namespace+"/"+ppl.Name)
+	if !containsString(ppl.Finalizers, runsFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	for _, buildNo := range activeRuns {
+		log.Info("cancelling run", "run", buildNo)
+		if err := r.deleteRun(ctx, ppl, buildNo); err != nil {
+			log.Error(err, "unable to cancel run", "run", buildNo)
+			return ctrl.Result{}, err
+		}
+	}
+
+	if len(activeRuns) > 0 {
+		return ctrl.Result{RequeueAfter: activeRunsRequeueInterval}, nil
+	}
+
+	ppl.Finalizers = removeString(ppl.Finalizers, runsFinalizer)
+	if err := r.Update(ctx, &ppl); err != nil {
+		log.Error(err, "unable to remove finalizer")
+		return ctrl.Result{}, err
+	}
+
 	return ctrl.Result{}, nil
 }
 
+// deleteRun deletes the runner pod of run buildNo right away, so it doesn't
+// create further stage pods, and all other objects of the run
+func (r *PipelineReconciler) deleteRun(ctx context.Context, ppl jindra.Pipeline, buildNo int) error {
+	runner := &core.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: ppl.Namespace, Name: ppl.RunnerName(buildNo)}}
+	if err := r.Delete(ctx, runner, client.GracePeriodSeconds(0)); ignoreNotFound(err) != nil {
+		return fmt.Errorf("error deleting runner pod %s: %s", runner.Name, err)
+	}
+
+	for _, obj := range []client.Object{&core.Pod{}, &core.ConfigMap{}, &core.Secret{}, &core.PersistentVolumeClaim{}, &rbac.Role{}, &rbac.RoleBinding{}} {
+		if err := r.DeleteAllOf(ctx, obj, client.InNamespace(ppl.Namespace), client.MatchingLabels(ppl.RunLabels(buildNo))); err != nil {
+			return fmt.Errorf("error deleting %T objects of run %d: %s", obj, buildNo, err)
+		}
+	}
+
+	return nil
+}
+
+// reconcileRunnerRBAC creates or updates the service account of the runner
+// pods of the pipeline and its role and role binding -- they are owned by
+// the pipeline and deleted with it; while runs are active, secrets are only
+// added to the role as the runs mask the values of the secrets they started
+// with
+func (r *PipelineReconciler) reconcileRunnerRBAC(ctx context.Context, ppl jindra.Pipeline, activeRuns bool) error {
+	objects, err := ppl.RunnerRBACObjects()
+	if err != nil {
+		return err
+	}
+
+	for _, desired := range objects {
+		obj := desired.DeepCopyObject().(client.Object)
+		objMeta, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		objMeta.SetNamespace(ppl.Namespace)
+		labels := objMeta.GetLabels()
+
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, obj, func() error {
+			switch o := obj.(type) {
+			case *rbac.Role:
+				rules := desired.(*rbac.Role).Rules
+				if activeRuns {
+					rules = keepSecrets(o.Rules, rules)
+				}
+				o.Rules = rules
+			case *rbac.RoleBinding:
+				o.Subjects = desired.(*rbac.RoleBinding).Subjects
+			}
+			existing := objMeta.GetLabels()
+			if existing == nil {
+				existing = map[string]string{}
+			}
+			for k, v := range labels {
+				existing[k] = v
+			}
+			objMeta.SetLabels(existing)
+			return controllerutil.SetControllerReference(&ppl, objMeta, r.Scheme)
+		}); err != nil {
+			return fmt.Errorf("error reconciling %s %s: %s", desired.GetObjectKind().GroupVersionKind().Kind, objMeta.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// reconcileRunRBAC creates the role and role binding of run buildNo if they
+// don't exist yet (e.g. the runner pod was created without them) -- they are
+// owned by the runner pod and deleted with it; existing objects are never
+// changed, as their rules belong to the pipeline the run was started with and
+// not to its current version
+func (r *PipelineReconciler) reconcileRunRBAC(ctx context.Context, ppl jindra.Pipeline, buildNo int) error {
+	var runner core.Pod
+	if err := r.Get(ctx, client.ObjectKey{Namespace: ppl.Namespace, Name: ppl.RunnerName(buildNo)}, &runner); err != nil {
+		// the runner pod of the run is already gone, only stage pods are left
+		return ignoreNotFound(err)
+	}
+	if runner.DeletionTimestamp != nil {
+		return nil
+	}
+
+	objects, err := ppl.RunRBACObjects(buildNo)
+	if err != nil {
+		return err
+	}
+
+	owner := metav1.OwnerReference{APIVersion: "v1", Kind: "Pod", Name: runner.Name, UID: runner.UID}
+	for _, desired := range objects {
+		obj := desired.DeepCopyObject().(client.Object)
+		objMeta, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		objMeta.SetNamespace(ppl.Namespace)
+		kind := desired.GetObjectKind().GroupVersionKind().Kind
+
+		existing := desired.DeepCopyObject().(client.Object)
+		err = r.Get(ctx, client.ObjectKey{Namespace: ppl.Namespace, Name: objMeta.GetName()}, existing)
+		if err == nil {
+			continue
+		}
+		if !apierrs.IsNotFound(err) {
+			return fmt.Errorf("error getting %s %s: %s", kind, objMeta.GetName(), err)
+		}
+
+		objMeta.SetOwnerReferences(append(objMeta.GetOwnerReferences(), owner))
+		if err := r.Create(ctx, obj); err != nil && !apierrs.IsAlreadyExists(err) {
+			return fmt.Errorf("error creating %s %s: %s", kind, objMeta.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// runPipeline maps the pods of runs to their pipeline, so the rbac objects of
+// a run are created as soon as its runner pod exists
+func runPipeline(o client.Object) []reconcile.Request {
+	name, ok := o.GetLabels()[jindra.PipelineLabelKey]
+	if !ok {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: o.GetNamespace(), Name: name}}}
+}
+
+// keepSecrets returns the rules with the names of the secrets of the existing
+// rules added to the rule for secrets
+func keepSecrets(existing, rules []rbac.PolicyRule) []rbac.PolicyRule {
+	secrets := map[string]bool{}
+	for _, rule := range append(existing, rules...) {
+		if containsString(rule.Resources, "secrets") {
+			for _, name := range rule.ResourceNames {
+				secrets[name] = true
+			}
+		}
+	}
+	if len(secrets) == 0 {
+		return rules
+	}
+
+	names := []string{}
+	for name := range secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	kept := []rbac.PolicyRule{}
+	for _, rule := range rules {
+		if !containsString(rule.Resources, "secrets") {
+			kept = append(kept, rule)
+		}
+	}
+
+	return append(kept, rbac.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: names, Verbs: []string{"get"}})
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func removeString(list []string, s string) []string {
+	result := []string{}
+	for _, item := range list {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 func (r *PipelineReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&jindra.Pipeline{}).
+		Owns(&core.ServiceAccount{}).
+		Owns(&rbac.Role{}).
+		Owns(&rbac.RoleBinding{}).
+		Watches(&source.Kind{Type: &core.Pod{}}, handler.EnqueueRequestsFromMapFunc(runPipeline)).
 		Complete(r)
 }
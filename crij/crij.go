@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"strings"
+
+	"github.com/kesselborn/jindra/secrets"
 )
 
 // SimpleEnvFileToEnv reads a file of the form
@@ -24,6 +26,26 @@ func SimpleEnvFileToEnv(envContent string) {
 	}
 }
 
+// ResolveSecrets replaces the values of all env vars with the given prefix
+// that reference a secret ('((<provider>:<path>#<key>))') with the value of
+// the secret
+func ResolveSecrets(prefix string, providers map[string]secrets.Provider) error {
+	for _, envVar := range os.Environ() {
+		tokens := strings.SplitN(envVar, "=", 2)
+		if prefix != "" && strings.Index(tokens[0], prefix+".") != 0 {
+			continue
+		}
+
+		value, err := secrets.Resolve(providers, tokens[1])
+		if err != nil {
+			return fmt.Errorf("error resolving %s: %s", tokens[0], err)
+		}
+		os.Setenv(tokens[0], value)
+	}
+
+	return nil
+}
+
 // EnvToJSON converts env variables to json structures:
 // foo.bar=baz
 // foo.baz=baz
@@ -5,6 +5,8 @@ import (
 	"os"
 	"reflect"
 	"testing"
+
+	"github.com/kesselborn/jindra/secrets"
 )
 
 func setEnv(env map[string]string) {
@@ -193,3 +195,41 @@ func TestEnvFileToEnv(t *testing.T) {
 		}
 	}
 }
+
+type fakeProvider map[string]string
+
+func (p fakeProvider) Get(path, key string) (string, error) {
+	value, ok := p[path+"#"+key]
+	if !ok {
+		return "", fmt.Errorf("%s does not exist", path)
+	}
+
+	return value, nil
+}
+
+func TestResolveSecrets(t *testing.T) {
+	setEnv(map[string]string{
+		"registry-image.source.password": "((vault:secret/ci/dockerhub#password))",
+		"registry-image.source.tag":      "42",
+		"git.source.private_key":         "((vault:secret/ci/git#key))",
+	})
+	providers := map[string]secrets.Provider{"vault": fakeProvider{"secret/ci/dockerhub#password": "s3cr3t"}}
+
+	if err := ResolveSecrets("registry-image", providers); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for key, expected := range map[string]string{
+		"registry-image.source.password": "s3cr3t",
+		"registry-image.source.tag":      "42",
+		"git.source.private_key":         "((vault:secret/ci/git#key))",
+	} {
+		if got := os.Getenv(key); got != expected {
+			t.Errorf("expected %s to be '%s', got '%s'", key, expected, got)
+		}
+	}
+
+	if err := ResolveSecrets("git", providers); err == nil || err.Error() != "error resolving git.source.private_key: error reading ((vault:secret/ci/git#key)): secret/ci/git does not exist" {
+		t.Errorf("expected error for missing secret, got %v", err)
+	}
+}
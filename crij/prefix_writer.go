@@ -0,0 +1,41 @@
+package crij
+
+import (
+	"bytes"
+	"io"
+)
+
+// PrefixWriter writes everything to the underlying writer and prepends every
+// line with a prefix. Partial lines are written right away, so output is
+// streamed as it comes in.
+type PrefixWriter struct {
+	w           io.Writer
+	prefix      []byte
+	atLineStart bool
+}
+
+// NewPrefixWriter returns a writer that prefixes every line written to w with prefix
+func NewPrefixWriter(w io.Writer, prefix string) *PrefixWriter {
+	return &PrefixWriter{w: w, prefix: []byte(prefix), atLineStart: true}
+}
+
+// Write implements io.Writer
+func (pw *PrefixWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if pw.atLineStart {
+			buf.Write(pw.prefix)
+		}
+		buf.Write(line)
+		pw.atLineStart = line[len(line)-1] == '\n'
+	}
+
+	if _, err := pw.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
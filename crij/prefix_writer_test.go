@@ -0,0 +1,22 @@
+package crij
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewPrefixWriter(&buf, "[git] ")
+
+	for _, chunk := range []string{"cloning", " repo\nfetching", "...\n", "\n", "done\n"} {
+		if _, err := w.Write([]byte(chunk)); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	exp := "[git] cloning repo\n[git] fetching...\n[git] \n[git] done\n"
+	if buf.String() != exp {
+		t.Errorf(errMsg(exp, buf.String(), nil))
+	}
+}
@@ -0,0 +1,160 @@
+package crij
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// KillGracePeriod is the time a resource script gets to terminate after it
+// received a SIGTERM because of a timeout before it is killed
+var KillGracePeriod = 10 * time.Second
+
+// Script describes a call of a resource script (i.e. /opt/resource/in)
+type Script struct {
+	// Args is the command and its arguments
+	Args []string
+	// Stdin is fed to the scripts stdin on every attempt
+	Stdin string
+	// Stdout and Stderr receive the output of all attempts
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Timeout for a single attempt -- 0 means no timeout
+	Timeout time.Duration
+	// Retries is the number of additional attempts if the script fails
+	Retries int
+	// RetryBackoff is the time to wait before the first retry; it is doubled for
+	// every further retry
+	RetryBackoff time.Duration
+	// CleanDir is emptied before every retry, so that a retry starts with the
+	// same directory as the first attempt (e.g. the destination of an in
+	// script, which expects an empty directory)
+	CleanDir string
+
+	// Signals that are received are forwarded to the running script; no more
+	// retries are done once a signal was received
+	Signals <-chan os.Signal
+}
+
+// Run calls the script until it succeeds or all retries were used up
+func (s Script) Run(ctx context.Context) error {
+	if len(s.Args) == 0 {
+		return fmt.Errorf("no script given")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	backoff := s.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= s.Retries; attempt++ {
+		if attempt > 0 {
+			fmt.Fprintf(s.stderr(), "attempt %d/%d failed: %s -- retrying in %s\n", attempt, s.Retries+1, err, backoff)
+			select {
+			case <-ctx.Done():
+				return err
+			case <-s.Signals:
+				return err
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+
+			if cleanErr := s.cleanDir(); cleanErr != nil {
+				return fmt.Errorf("%s (%s)", err, cleanErr)
+			}
+		}
+
+		if err = s.runOnce(ctx, cancel); err == nil || ctx.Err() != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (s Script) runOnce(ctx context.Context, cancel context.CancelFunc) error {
+	cmd := exec.Command(s.Args[0], s.Args[1:]...)
+	cmd.Stdin = strings.NewReader(s.Stdin)
+	cmd.Stdout = s.Stdout
+	cmd.Stderr = s.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting %s: %s", cmd.String(), err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var timeout <-chan time.Time
+	if s.Timeout > 0 {
+		timer := time.NewTimer(s.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	ctxDone := ctx.Done()
+	var kill <-chan time.Time
+	timedOut := false
+	for {
+		select {
+		case err := <-done:
+			if timedOut {
+				return fmt.Errorf("%s timed out after %s", cmd.String(), s.Timeout)
+			}
+			return err
+		case sig := <-s.Signals:
+			// stop retrying and let the script decide how to handle the signal
+			ctxDone = nil
+			cancel()
+			cmd.Process.Signal(sig)
+		case <-ctxDone:
+			// context was cancelled from the outside: treat like a timeout
+			ctxDone = nil
+			cmd.Process.Signal(syscall.SIGTERM)
+			kill = time.After(KillGracePeriod)
+		case <-timeout:
+			timeout = nil
+			timedOut = true
+			cmd.Process.Signal(syscall.SIGTERM)
+			kill = time.After(KillGracePeriod)
+		case <-kill:
+			cmd.Process.Kill()
+		}
+	}
+}
+
+// cleanDir removes the content of CleanDir -- the directory itself is kept,
+// as it usually is a mount point
+func (s Script) cleanDir() error {
+	if s.CleanDir == "" {
+		return nil
+	}
+
+	entries, err := ioutil.ReadDir(s.CleanDir)
+	if err != nil {
+		return fmt.Errorf("error cleaning %s: %s", s.CleanDir, err)
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(s.CleanDir, entry.Name())); err != nil {
+			return fmt.Errorf("error cleaning %s: %s", s.CleanDir, err)
+		}
+	}
+
+	return nil
+}
+
+func (s Script) stderr() io.Writer {
+	if s.Stderr == nil {
+		return os.Stderr
+	}
+
+	return s.Stderr
+}
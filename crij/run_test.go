@@ -0,0 +1,139 @@
+package crij
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunFeedsStdinOnEveryRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crij")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// fail on the first two attempts, succeed on the third
+	counter := path.Join(dir, "counter")
+	var stdout bytes.Buffer
+	s := Script{
+		Args:         []string{"/bin/sh", "-c", `cat; echo x >> ` + counter + `; test $(wc -l < ` + counter + `) -ge 3`},
+		Stdin:        `{"source":{}}`,
+		Stdout:       &stdout,
+		Stderr:       ioutil.Discard,
+		Retries:      2,
+		RetryBackoff: time.Millisecond,
+	}
+
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if expected := strings.Repeat(`{"source":{}}`, 3); stdout.String() != expected {
+		t.Fatalf(errMsg(expected, stdout.String(), nil))
+	}
+}
+
+func TestRunCleansDirBeforeRetry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crij")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	counter := path.Join(dir, "counter")
+	dest := path.Join(dir, "dest")
+	if err := os.Mkdir(dest, 0755); err != nil {
+		t.Fatalf("error creating destination dir: %s", err)
+	}
+
+	// the first attempt leaves a partial clone behind and fails, the second
+	// one only succeeds if it starts with an empty directory
+	s := Script{
+		Args: []string{"/bin/sh", "-c", `echo x >> ` + counter + `; test -z "$(ls -A ` + dest + `)" || exit 2; ` +
+			`mkdir ` + dest + `/.git && touch ` + dest + `/partial; test $(wc -l < ` + counter + `) -ge 2`},
+		Stdout:       ioutil.Discard,
+		Stderr:       ioutil.Discard,
+		Retries:      1,
+		RetryBackoff: time.Millisecond,
+		CleanDir:     dest,
+	}
+
+	if err := s.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := os.Stat(dest); err != nil {
+		t.Fatalf("expected the destination dir to be kept: %s", err)
+	}
+}
+
+func TestRunGivesUpAfterRetries(t *testing.T) {
+	var stderr bytes.Buffer
+	s := Script{
+		Args:         []string{"/bin/sh", "-c", "exit 1"},
+		Stdout:       ioutil.Discard,
+		Stderr:       &stderr,
+		Retries:      2,
+		RetryBackoff: time.Millisecond,
+	}
+
+	if err := s.Run(context.Background()); err == nil {
+		t.Fatalf("expected script to fail")
+	}
+
+	if n := strings.Count(stderr.String(), "retrying in"); n != 2 {
+		t.Fatalf("expected 2 retries, got %d:\n%s", n, stderr.String())
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	s := Script{
+		Args:    []string{"/bin/sh", "-c", "exec sleep 10"},
+		Stdout:  ioutil.Discard,
+		Stderr:  ioutil.Discard,
+		Timeout: 50 * time.Millisecond,
+	}
+
+	start := time.Now()
+	err := s.Run(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected timeout error, got: %v", err)
+	}
+
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("script was not terminated after timeout (took %s)", d)
+	}
+}
+
+func TestRunForwardsSignals(t *testing.T) {
+	signals := make(chan os.Signal, 1)
+	var stdout bytes.Buffer
+	s := Script{
+		Args:         []string{"/bin/sh", "-c", `trap 'echo got term; exit 3' TERM; echo started; while true; do sleep 0.01; done`},
+		Stdout:       &stdout,
+		Stderr:       ioutil.Discard,
+		Retries:      3,
+		RetryBackoff: time.Millisecond,
+		Signals:      signals,
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		signals <- syscall.SIGTERM
+	}()
+
+	if err := s.Run(context.Background()); err == nil {
+		t.Fatalf("expected script to fail")
+	}
+
+	// no retries must happen after a signal was received
+	if expected := "started\ngot term\n"; stdout.String() != expected {
+		t.Fatalf(errMsg(expected, stdout.String(), nil))
+	}
+}
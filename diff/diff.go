@@ -0,0 +1,305 @@
+// Package diff compares the objects jindra generates for two revisions of a
+// pipeline. Objects are compared as trees: changes are reported with the
+// path of the changed value and lists of named items (containers, volumes,
+// env vars, ...) are compared by name, so a new init container doesn't show
+// up as a change of every following container.
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// kinds of changes
+const (
+	Added    = "+"
+	Removed  = "-"
+	Modified = "~"
+)
+
+// Change is a changed value
+type Change struct {
+	Kind string
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+// String returns the change as a line -- changes of multi line strings show
+// the changed lines below the path
+func (c Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("%s %s: %s", Added, c.Path, format(c.New))
+	case Removed:
+		return fmt.Sprintf("%s %s: %s", Removed, c.Path, format(c.Old))
+	}
+
+	oldString, oldOk := c.Old.(string)
+	newString, newOk := c.New.(string)
+	if oldOk && newOk && (strings.Contains(oldString, "\n") || strings.Contains(newString, "\n")) {
+		lines := []string{fmt.Sprintf("%s %s:", Modified, c.Path)}
+		for _, line := range lineDiff(strings.Split(oldString, "\n"), strings.Split(newString, "\n")) {
+			lines = append(lines, "    "+line)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	return fmt.Sprintf("%s %s: %s -> %s", Modified, c.Path, format(c.Old), format(c.New))
+}
+
+// Objects returns the changes between two objects -- the objects are
+// compared in their json representation
+func Objects(old, new interface{}) ([]Change, error) {
+	oldTree, err := tree(old)
+	if err != nil {
+		return nil, err
+	}
+	newTree, err := tree(new)
+	if err != nil {
+		return nil, err
+	}
+
+	return Values("", oldTree, newTree), nil
+}
+
+// Values returns the changes between two json values below path
+func Values(path string, old, new interface{}) []Change {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		return maps(path, oldMap, newMap)
+	}
+
+	oldList, oldIsList := old.([]interface{})
+	newList, newIsList := new.([]interface{})
+	if oldIsList && newIsList {
+		return lists(path, oldList, newList)
+	}
+
+	if reflect.DeepEqual(old, new) {
+		return nil
+	}
+
+	return []Change{{Kind: Modified, Path: path, Old: old, New: new}}
+}
+
+func maps(path string, old, new map[string]interface{}) []Change {
+	keys := []string{}
+	for key := range old {
+		keys = append(keys, key)
+	}
+	for key := range new {
+		if _, ok := old[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := []Change{}
+	for _, key := range keys {
+		oldValue, inOld := old[key]
+		newValue, inNew := new[key]
+		child := childPath(path, key)
+
+		switch {
+		case !inOld:
+			changes = append(changes, Change{Kind: Added, Path: child, New: newValue})
+		case !inNew:
+			changes = append(changes, Change{Kind: Removed, Path: child, Old: oldValue})
+		default:
+			changes = append(changes, Values(child, oldValue, newValue)...)
+		}
+	}
+
+	return changes
+}
+
+// lists compares lists of named items by name and all other lists by index
+func lists(path string, old, new []interface{}) []Change {
+	oldNames, oldNamed := names(old)
+	newNames, newNamed := names(new)
+	if oldNamed && newNamed {
+		return namedLists(path, old, new, oldNames, newNames)
+	}
+
+	if len(old) != len(new) && scalars(old) && scalars(new) {
+		return []Change{{Kind: Modified, Path: path, Old: old, New: new}}
+	}
+
+	changes := []Change{}
+	for i := 0; i < len(old) || i < len(new); i++ {
+		child := fmt.Sprintf("%s[%d]", path, i)
+		switch {
+		case i >= len(old):
+			changes = append(changes, Change{Kind: Added, Path: child, New: new[i]})
+		case i >= len(new):
+			changes = append(changes, Change{Kind: Removed, Path: child, Old: old[i]})
+		default:
+			changes = append(changes, Values(child, old[i], new[i])...)
+		}
+	}
+
+	return changes
+}
+
+func namedLists(path string, old, new []interface{}, oldNames, newNames []string) []Change {
+	oldItems := map[string]interface{}{}
+	for i, name := range oldNames {
+		oldItems[name] = old[i]
+	}
+	newItems := map[string]interface{}{}
+	for i, name := range newNames {
+		newItems[name] = new[i]
+	}
+
+	changes := []Change{}
+
+	// the order of items that are in both lists
+	oldOrder, newOrder := []string{}, []string{}
+	for _, name := range oldNames {
+		if _, ok := newItems[name]; ok {
+			oldOrder = append(oldOrder, name)
+		}
+	}
+	for _, name := range newNames {
+		if _, ok := oldItems[name]; ok {
+			newOrder = append(newOrder, name)
+		}
+	}
+	if !reflect.DeepEqual(oldOrder, newOrder) {
+		changes = append(changes, Change{Kind: Modified, Path: path + " (order)", Old: oldOrder, New: newOrder})
+	}
+
+	for _, name := range oldNames {
+		child := fmt.Sprintf("%s[%s]", path, name)
+		if newItem, ok := newItems[name]; ok {
+			changes = append(changes, Values(child, oldItems[name], newItem)...)
+			continue
+		}
+		changes = append(changes, Change{Kind: Removed, Path: child, Old: oldItems[name]})
+	}
+	for _, name := range newNames {
+		if _, ok := oldItems[name]; !ok {
+			changes = append(changes, Change{Kind: Added, Path: fmt.Sprintf("%s[%s]", path, name), New: newItems[name]})
+		}
+	}
+
+	return changes
+}
+
+// names returns the names of the items of list -- if all items are objects
+// with a unique name
+func names(list []interface{}) ([]string, bool) {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		name, ok := m["name"].(string)
+		if !ok || seen[name] {
+			return nil, false
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	return names, len(list) > 0
+}
+
+func scalars(list []interface{}) bool {
+	for _, item := range list {
+		switch item.(type) {
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+	}
+
+	return true
+}
+
+var identifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// childPath returns the path of key below path -- keys that aren't
+// identifiers (like annotation keys) are written in brackets
+func childPath(path, key string) string {
+	if !identifier.MatchString(key) {
+		return fmt.Sprintf("%s[%s]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+func tree(o interface{}) (interface{}, error) {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling object: %s", err)
+	}
+
+	var t interface{}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("error unmarshalling object: %s", err)
+	}
+
+	return t, nil
+}
+
+func format(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+
+	return string(data)
+}
+
+// lineDiff returns the removed ('- ') and added ('+ ') lines between old and
+// new -- lines of the longest common subsequence are not shown
+func lineDiff(old, new []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of old[i:] and new[j:]
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := []string{}
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case i < len(old) && j < len(new) && old[i] == new[j]:
+			i, j = i+1, j+1
+		case i < len(old) && (j == len(new) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, Removed+" "+old[i])
+			i++
+		default:
+			lines = append(lines, Added+" "+new[j])
+			j++
+		}
+	}
+
+	return lines
+}
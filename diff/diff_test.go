@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+
+	jindra "github.com/kesselborn/jindra/api/v1alpha1"
+)
+
+const fixtureDir = "../tests/fixtures"
+
+func TestValues(t *testing.T) {
+	for i, test := range []struct {
+		old      string
+		new      string
+		expected []string
+		desc     string
+	}{
+		{`{"a": 1, "b": {"c": "x"}}`, `{"a": 1, "b": {"c": "x"}}`, []string{},
+			"equal values have no changes"},
+		{`{"a": 1, "b": {"c": "x"}}`, `{"a": 2, "b": {"d": "x"}}`,
+			[]string{`~ a: 1 -> 2`, `- b.c: "x"`, `+ b.d: "x"`}, "changes of maps are reported with their path"},
+		{`{"annotations": {"jindra.io/inputs": "git"}}`, `{"annotations": {"jindra.io/inputs": "git,transit"}}`,
+			[]string{`~ annotations[jindra.io/inputs]: "git" -> "git,transit"`}, "keys that aren't identifiers are written in brackets"},
+		{`{"c": [{"name": "a", "image": "x"}, {"name": "b", "image": "y"}]}`,
+			`{"c": [{"name": "new", "image": "z"}, {"name": "a", "image": "x"}, {"name": "b", "image": "y2"}]}`,
+			[]string{`~ c[b].image: "y" -> "y2"`, `+ c[new]: {"image":"z","name":"new"}`}, "lists of named items are compared by name"},
+		{`{"c": [{"name": "a"}, {"name": "b"}]}`, `{"c": [{"name": "b"}, {"name": "a"}]}`,
+			[]string{`~ c (order): ["a","b"] -> ["b","a"]`}, "changes of the order of named items are reported"},
+		{`{"args": ["-a", "-b"]}`, `{"args": ["-a", "-c"]}`, []string{`~ args[1]: "-b" -> "-c"`},
+			"other lists are compared by index"},
+		{`{"args": ["-a", "-b"]}`, `{"args": ["-a"]}`, []string{`~ args: ["-a","-b"] -> ["-a"]`},
+			"lists of values of different lengths are reported as a whole"},
+		{`{"script": "set -e\necho a\necho b\n"}`, `{"script": "set -e\necho c\necho b\n"}`,
+			[]string{"~ script:\n    - echo a\n    + echo c"}, "multi line strings show the changed lines"},
+	} {
+		var old, new interface{}
+		if err := json.Unmarshal([]byte(test.old), &old); err != nil {
+			t.Fatalf("error unmarshalling %s: %s", test.old, err)
+		}
+		if err := json.Unmarshal([]byte(test.new), &new); err != nil {
+			t.Fatalf("error unmarshalling %s: %s", test.new, err)
+		}
+
+		got := []string{}
+		for _, change := range Values("", old, new) {
+			got = append(got, change.String())
+		}
+
+		if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+			t.Fatalf("\t%2d: %-80s [FAILED]\n\t\texpected: %v\n\t\tgot: %v", i, test.desc, test.expected, got)
+		}
+		t.Logf("\t%2d: %-80s [OK]", i, test.desc)
+	}
+}
+
+func examplePipeline(t *testing.T) jindra.Pipeline {
+	data, err := ioutil.ReadFile(filepath.Join(fixtureDir, "pipeline-example.yaml"))
+	if err != nil {
+		t.Fatalf("error reading pipeline: %s", err)
+	}
+	ppl, err := jindra.NewPipelineFromYaml(data)
+	if err != nil {
+		t.Fatalf("error parsing pipeline: %s", err)
+	}
+	ppl.SetDefaults()
+
+	return ppl
+}
+
+func TestPipelines(t *testing.T) {
+	old := examplePipeline(t)
+
+	changedImage := examplePipeline(t)
+	changedImage.Spec.Stages[1].Spec.Containers[0].Image = "gcr.io/kaniko-project/executor:v1.0.0"
+
+	newStage := examplePipeline(t)
+	stage := core.Pod{Spec: core.PodSpec{Containers: []core.Container{{Name: "test", Image: "golang"}}}}
+	stage.Name = "test"
+	newStage.Spec.Stages = append([]core.Pod{stage}, newStage.Spec.Stages...)
+
+	noOnError := examplePipeline(t)
+	noOnError.Spec.OnError = nil
+
+	for i, test := range []struct {
+		new      jindra.Pipeline
+		expected []string
+		desc     string
+	}{
+		{old, []string{}, "generated ssh keys are not reported"},
+		{changedImage, []string{"~ stage build-docker-image"}, "changed stages are reported"},
+		// the names of the stage pods contain their position
+		{newStage, []string{"~ config map", "+ stage test", "~ stage build-go-binary", "~ stage build-docker-image",
+			"~ stage on-success", "~ stage on-error", "~ stage final"}, "stages are compared by name"},
+		{noOnError, []string{"~ config map", "~ stage final", "- stage on-error"}, "removed stages are reported"},
+	} {
+		sections, err := Pipelines(old, test.new, 42)
+		if err != nil {
+			t.Fatalf("\t%2d: %-80s [FAILED]: unexpected error: %s", i, test.desc, err)
+		}
+
+		got := []string{}
+		for _, section := range sections {
+			got = append(got, section.Kind+" "+section.Name)
+		}
+
+		if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+			t.Fatalf("\t%2d: %-80s [FAILED]\n\t\texpected: %v\n\t\tgot: %v", i, test.desc, test.expected, got)
+		}
+		t.Logf("\t%2d: %-80s [OK]", i, test.desc)
+	}
+}
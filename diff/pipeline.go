@@ -0,0 +1,159 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+
+	jindra "github.com/kesselborn/jindra/api/v1alpha1"
+)
+
+// SSHKeyPlaceholder replaces the ssh keys of the rsync secret -- they are
+// generated randomly for every run and would always differ
+const SSHKeyPlaceholder = "<generated ssh key>"
+
+// publicKey matches the keys of authorized keys -- the restrictions of the
+// keys are compared
+var publicKey = regexp.MustCompile(`(ssh-[a-z0-9-]+) [A-Za-z0-9+/=]+`)
+
+// stagePrefix is the prefix of the config map keys that orders the stages
+var stagePrefix = regexp.MustCompile(`^[0-9]+-`)
+
+// Section contains the changes of one generated object
+type Section struct {
+	Name string
+	// Kind is Added or Removed if the object exists only for one revision,
+	// Modified otherwise
+	Kind    string
+	Changes []Change
+}
+
+// object is a generated object and the name of its section
+type object struct {
+	name  string
+	value interface{}
+}
+
+// Pipelines returns the changes of the objects generated for a run of the
+// old and the new pipeline: the config map, the runner pod, the objects of
+// the transit backend and every stage pod -- stages are compared by name,
+// so inserting a stage doesn't change all following stages
+func Pipelines(old, new jindra.Pipeline, buildNo int) ([]Section, error) {
+	oldObjects, err := objects(old, buildNo)
+	if err != nil {
+		return nil, fmt.Errorf("error generating objects of the old pipeline: %s", err)
+	}
+	newObjects, err := objects(new, buildNo)
+	if err != nil {
+		return nil, fmt.Errorf("error generating objects of the new pipeline: %s", err)
+	}
+
+	oldValues := map[string]interface{}{}
+	for _, o := range oldObjects {
+		oldValues[o.name] = o.value
+	}
+	newValues := map[string]interface{}{}
+	for _, o := range newObjects {
+		newValues[o.name] = o.value
+	}
+
+	sections := []Section{}
+	for _, o := range newObjects {
+		oldValue, ok := oldValues[o.name]
+		if !ok {
+			sections = append(sections, Section{Name: o.name, Kind: Added})
+			continue
+		}
+
+		changes, err := Objects(oldValue, o.value)
+		if err != nil {
+			return nil, fmt.Errorf("error comparing %s: %s", o.name, err)
+		}
+		if len(changes) > 0 {
+			sections = append(sections, Section{Name: o.name, Kind: Modified, Changes: changes})
+		}
+	}
+	for _, o := range oldObjects {
+		if _, ok := newValues[o.name]; !ok {
+			sections = append(sections, Section{Name: o.name, Kind: Removed})
+		}
+	}
+
+	return sections, nil
+}
+
+// objects returns the objects generated for a run of ppl in the order
+// they are created
+func objects(ppl jindra.Pipeline, buildNo int) ([]object, error) {
+	transitObjects, err := ppl.TransitObjects(buildNo)
+	if err != nil {
+		return nil, fmt.Errorf("error creating transit objects: %s", err)
+	}
+
+	cm, err := ppl.PipelineRunConfigMap(buildNo)
+	if err != nil {
+		return nil, fmt.Errorf("error creating config map: %s", err)
+	}
+
+	runnerPod, err := ppl.RunnerPod(buildNo)
+	if err != nil {
+		return nil, fmt.Errorf("error creating runner pod: %s", err)
+	}
+
+	objects := []object{}
+	for _, o := range transitObjects {
+		if secret, ok := o.(*core.Secret); ok {
+			o = withKeyPlaceholders(*secret)
+		}
+		accessor, err := meta.Accessor(o)
+		if err != nil {
+			return nil, fmt.Errorf("error reading metadata of transit object: %s", err)
+		}
+		name := strings.ToLower(o.GetObjectKind().GroupVersionKind().Kind) + " " + accessor.GetName()
+		objects = append(objects, object{name: name, value: o})
+	}
+
+	keys := []string{}
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// the stage pods are compared separately
+	stages := []object{}
+	for _, key := range keys {
+		name := "stage " + stagePrefix.ReplaceAllString(strings.TrimSuffix(key, ".yaml"), "")
+
+		var pod interface{}
+		if err := yaml.Unmarshal([]byte(cm.Data[key]), &pod); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %s", name, err)
+		}
+		stages = append(stages, object{name: name, value: pod})
+		cm.Data[key] = "<" + name + ">"
+	}
+
+	objects = append(objects, object{name: "config map", value: cm}, object{name: "runner pod", value: runnerPod})
+
+	return append(objects, stages...), nil
+}
+
+// withKeyPlaceholders replaces the private and public keys of the rsync
+// secret with placeholders
+func withKeyPlaceholders(secret core.Secret) *core.Secret {
+	stringData := map[string]string{}
+	for key, value := range secret.Data {
+		if strings.Contains(string(value), "PRIVATE KEY") {
+			stringData[key] = SSHKeyPlaceholder
+			continue
+		}
+		stringData[key] = publicKey.ReplaceAllString(string(value), "$1 "+SSHKeyPlaceholder)
+	}
+	secret.Data, secret.StringData = nil, stringData
+
+	return &secret
+}
@@ -1,15 +1,19 @@
 module github.com/kesselborn/jindra
 
-go 1.13
+go 1.15
 
 require (
 	github.com/ghodss/yaml v1.0.0
-	github.com/go-logr/logr v0.1.0
-	github.com/onsi/ginkgo v1.10.1
-	github.com/onsi/gomega v1.7.0
-	golang.org/x/crypto v0.0.0-20190820162420-60c769a6c586
-	k8s.io/api v0.17.2
-	k8s.io/apimachinery v0.17.2
-	k8s.io/client-go v0.17.2
-	sigs.k8s.io/controller-runtime v0.4.0
+	github.com/go-git/go-git/v5 v5.0.0
+	github.com/go-logr/logr v0.3.0
+	github.com/google/gofuzz v1.1.0
+	github.com/minio/minio-go/v6 v6.0.55
+	github.com/onsi/ginkgo v1.14.1
+	github.com/onsi/gomega v1.10.2
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/sys v0.0.0-20200622214017-ed371f2e16b4
+	k8s.io/api v0.19.2
+	k8s.io/apimachinery v0.19.2
+	k8s.io/client-go v0.19.2
+	sigs.k8s.io/controller-runtime v0.7.2
 )
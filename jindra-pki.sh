@@ -80,4 +80,19 @@ webhooks:
   name: validator.jindra.io
 EOF
 
+cat <<EOF >config/crd/patches/ca_bundle_in_pipelines.yaml
+# This patch adds the jindra-pki.sh create ca bundle and the webhook service to the conversion webhook
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: pipelines.ci.jindra.io
+spec:
+  conversion:
+    webhookClientConfig:
+      caBundle: $(cat ${CA_CERT} | base64)
+      service:
+        name: ${SERVICE_NAME}
+        namespace: ${NAMESPACE}
+EOF
+
 kubectl -n ${NAMESPACE} create --dry-run -oyaml secret tls webhook-server-cert --cert .pki/server.crt --key .pki/server.key
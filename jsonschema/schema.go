@@ -0,0 +1,223 @@
+// Package jsonschema creates JSON schemas (draft-07) of API types and
+// validates documents against them. Schemas are reflected from the go types:
+// objects are defined by the json tags of struct fields, fields without
+// omitempty are required and fields that are not declared are not allowed.
+// Descriptions are taken from the SwaggerDoc methods of the kubernetes types.
+package jsonschema
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Draft is the JSON schema version of the generated schemas
+const Draft = "http://json-schema.org/draft-07/schema#"
+
+// Schema is a JSON schema
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	If                   *Schema            `json:"if,omitempty"`
+	Then                 *Schema            `json:"then,omitempty"`
+	Else                 *Schema            `json:"else,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+
+	// False marks the schema that no value matches (e.g. as
+	// additionalProperties of objects that don't allow undeclared fields)
+	False bool `json:"-"`
+}
+
+// MarshalJSON implements json.Marshaler -- schemas that are False are
+// written as false
+func (s Schema) MarshalJSON() ([]byte, error) {
+	if s.False {
+		return []byte("false"), nil
+	}
+
+	type schema Schema
+	return json.Marshal(schema(s))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	if string(data) == "false" {
+		*s = Schema{False: true}
+		return nil
+	}
+
+	type schema Schema
+	return json.Unmarshal(data, (*schema)(s))
+}
+
+// Reflect returns the schema of the type of v with all referenced types in
+// its definitions
+func Reflect(v interface{}) *Schema {
+	r := reflector{definitions: map[string]*Schema{}}
+	root := r.schema(reflect.TypeOf(v))
+	root.Schema = Draft
+	root.Definitions = r.definitions
+
+	return root
+}
+
+// Definition returns the definition of the type of v
+func (s *Schema) Definition(v interface{}) *Schema {
+	return s.Definitions[DefinitionName(reflect.TypeOf(v))]
+}
+
+// DefinitionName returns the name of the definition of type t -- the
+// package path with the reversed domain (like in the kubernetes OpenAPI
+// spec) followed by the type name, e.g. io.k8s.api.core.v1.Pod
+func DefinitionName(t reflect.Type) string {
+	segments := strings.Split(t.PkgPath(), "/")
+	domain := strings.Split(segments[0], ".")
+	for i, j := 0, len(domain)-1; i < j; i, j = i+1, j-1 {
+		domain[i], domain[j] = domain[j], domain[i]
+	}
+
+	return strings.Join(append(append(domain, segments[1:]...), t.Name()), ".")
+}
+
+// RefTo returns a schema that references the definition name
+func RefTo(name string) *Schema {
+	return &Schema{Ref: "#/definitions/" + name}
+}
+
+var (
+	timeType        = reflect.TypeOf(metav1.Time{})
+	quantityType    = reflect.TypeOf(resource.Quantity{})
+	intOrStringType = reflect.TypeOf(intstr.IntOrString{})
+	marshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+type reflector struct {
+	definitions map[string]*Schema
+}
+
+func (r reflector) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case quantityType, intOrStringType:
+		return &Schema{AnyOf: []*Schema{{Type: "integer"}, {Type: "string"}}}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return r.schema(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+			// the json representation of the type is unknown
+			return &Schema{}
+		}
+
+		name := DefinitionName(t)
+		if _, ok := r.definitions[name]; !ok {
+			def := &Schema{Type: "object", Properties: map[string]*Schema{}, AdditionalProperties: &Schema{False: true}}
+			r.definitions[name] = def
+			r.addFields(def, t)
+		}
+		return RefTo(name)
+	}
+
+	return &Schema{}
+}
+
+// addFields adds the fields of struct t to the object def -- fields of
+// embedded structs without json name are added as well
+func (r reflector) addFields(def *Schema, t reflect.Type) {
+	docs := swaggerDoc(t)
+	if def.Description == "" {
+		def.Description = docs[""]
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+
+		tokens := strings.Split(tag, ",")
+		name, options := tokens[0], tokens[1:]
+		if name == "" && f.Anonymous {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			r.addFields(def, embedded)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		property := r.schema(f.Type)
+		if doc := docs[name]; doc != "" {
+			if property.Ref != "" {
+				// siblings of $ref are ignored
+				property = &Schema{Description: doc, AllOf: []*Schema{property}}
+			} else {
+				property.Description = doc
+			}
+		}
+		def.Properties[name] = property
+
+		if !contains(options, "omitempty") && f.Type.Kind() != reflect.Ptr {
+			def.Required = append(def.Required, name)
+		}
+	}
+}
+
+// swaggerDoc returns the descriptions of type t and its fields
+func swaggerDoc(t reflect.Type) map[string]string {
+	method := reflect.Zero(t).MethodByName("SwaggerDoc")
+	if !method.IsValid() {
+		return map[string]string{}
+	}
+
+	docs, _ := method.Call(nil)[0].Interface().(map[string]string)
+	return docs
+}
+
+func contains(words []string, word string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+
+	return false
+}
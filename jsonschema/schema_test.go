@@ -0,0 +1,104 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+type example struct {
+	Name     string            `json:"name"`
+	Count    *int              `json:"count,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Env      []core.EnvVar     `json:"env,omitempty"`
+	Quantity resource.Quantity `json:"quantity,omitempty"`
+	Ignored  string            `json:"-"`
+	embedded `json:",inline"`
+}
+
+type embedded struct {
+	Mode string `json:"mode,omitempty"`
+}
+
+func TestReflect(t *testing.T) {
+	s := Reflect(example{})
+
+	if s.Schema != Draft || s.Ref != "#/definitions/com.github.kesselborn.jindra.jsonschema.example" {
+		t.Fatalf("unexpected root schema: %s, %s", s.Schema, s.Ref)
+	}
+
+	def := s.Definition(example{})
+	properties := []string{}
+	for name := range def.Properties {
+		properties = append(properties, name)
+	}
+	if len(properties) != 6 || def.Properties["mode"] == nil || def.Properties["Ignored"] != nil {
+		t.Errorf("expected the properties name, count, labels, env, quantity and mode, got %v", properties)
+	}
+
+	if strings.Join(def.Required, ",") != "name" {
+		t.Errorf("expected only name to be required, got %v", def.Required)
+	}
+
+	envVar := s.Definitions["io.k8s.api.core.v1.EnvVar"]
+	if envVar == nil || !strings.HasPrefix(envVar.Description, "EnvVar represents") || envVar.Properties["name"].Description == "" {
+		t.Errorf("expected description of EnvVar from its SwaggerDoc, got %#v", envVar)
+	}
+
+	data, err := json.Marshal(def)
+	if err != nil {
+		t.Fatalf("error marshalling schema: %s", err)
+	}
+	if !strings.Contains(string(data), `"additionalProperties":false`) {
+		t.Errorf("expected objects to not allow additional properties, got %s", data)
+	}
+
+	var roundTrip Schema
+	if err := json.Unmarshal(data, &roundTrip); err != nil || !roundTrip.AdditionalProperties.False {
+		t.Errorf("expected false schema after unmarshalling, got %#v (%v)", roundTrip.AdditionalProperties, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := Reflect(example{})
+	s.Definition(example{}).Properties["mode"].Enum = []string{"fast", "slow"}
+	s.Definition(example{}).Properties["labels"].AdditionalProperties.Pattern = "^[a-z]+$"
+
+	for i, test := range []struct {
+		doc      string
+		expected []string
+		desc     string
+	}{
+		{`{"name": "a", "count": 1, "labels": {"a": "b"}, "env": [{"name": "X", "value": "1"}], "quantity": "1Gi", "mode": "fast"}`,
+			[]string{}, "valid document"},
+		{`{"name": "a", "quantity": 1, "count": null}`, []string{}, "integer quantities and null values are valid"},
+		{`{"count": 1}`, []string{"name: Required value"}, "required fields are reported"},
+		{`{"name": 1, "count": 1.5, "quantity": true}`, []string{
+			`count: Invalid value: "number": must be of type integer`,
+			`name: Invalid value: "number": must be of type string`,
+			`quantity: Invalid value: "boolean": must be of type integer or string`,
+		}, "values of the wrong type are reported"},
+		{`{"name": "a", "nmae": "a"}`, []string{`nmae: Forbidden: unknown field`}, "unknown fields are reported"},
+		{`{"name": "a", "env": [{"name": "X", "valeu": "1"}]}`, []string{`env[0].valeu: Forbidden: unknown field`}, "paths contain indices of arrays"},
+		{`{"name": "a", "labels": {"jindra.io/x": "A"}}`, []string{`labels[jindra.io/x]: Invalid value: "A": must match the pattern ^[a-z]+$`}, "values of maps are referenced by key"},
+		{`{"name": "a", "mode": "medium"}`, []string{`mode: Unsupported value: "medium": supported values: "fast", "slow"`}, "enums are checked"},
+	} {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(test.doc), &doc); err != nil {
+			t.Fatalf("error unmarshalling %s: %s", test.doc, err)
+		}
+
+		got := []string{}
+		for _, err := range s.Validate(doc) {
+			got = append(got, err.Error())
+		}
+
+		if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+			t.Fatalf("\t%2d: %-80s [FAILED]\n\t\texpected: %v\n\t\tgot: %v", i, test.desc, test.expected, got)
+		}
+		t.Logf("\t%2d: %-80s [OK]", i, test.desc)
+	}
+}
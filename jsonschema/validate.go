@@ -0,0 +1,191 @@
+package jsonschema
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validate validates doc (as decoded by encoding/json into an interface{})
+// against the schema -- definitions are looked up in the schema
+func (s *Schema) Validate(doc interface{}) field.ErrorList {
+	v := validator{definitions: s.Definitions}
+
+	// values can be checked by several schemas (e.g. with allOf), so the
+	// same error can be reported more than once
+	errs, seen := field.ErrorList{}, map[string]bool{}
+	for _, err := range v.validate(s, doc, nil) {
+		if !seen[err.Error()] {
+			errs = append(errs, err)
+			seen[err.Error()] = true
+		}
+	}
+
+	return errs
+}
+
+type validator struct {
+	definitions map[string]*Schema
+}
+
+func (v validator) validate(s *Schema, doc interface{}, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if s.False {
+		return append(errs, field.Forbidden(path, "must not be set"))
+	}
+
+	if s.Ref != "" {
+		def, ok := v.definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+		if !ok {
+			return append(errs, field.InternalError(path, fmt.Errorf("unknown schema reference %s", s.Ref)))
+		}
+		errs = append(errs, v.validate(def, doc, path)...)
+	}
+
+	for _, sub := range s.AllOf {
+		errs = append(errs, v.validate(sub, doc, path)...)
+	}
+
+	if len(s.AnyOf) > 0 && !v.anyOf(s.AnyOf, doc, path) {
+		types := []string{}
+		for _, sub := range s.AnyOf {
+			types = append(types, sub.Type)
+		}
+		errs = append(errs, field.Invalid(path, typeOf(doc), "must be of type "+strings.Join(types, " or ")))
+	}
+
+	if s.If != nil {
+		if len(v.validate(s.If, doc, path)) == 0 {
+			if s.Then != nil {
+				errs = append(errs, v.validate(s.Then, doc, path)...)
+			}
+		} else if s.Else != nil {
+			errs = append(errs, v.validate(s.Else, doc, path)...)
+		}
+	}
+
+	if s.Type != "" && !hasType(doc, s.Type) {
+		// checks of the content don't make sense for values of the wrong type
+		return append(errs, field.Invalid(path, typeOf(doc), "must be of type "+s.Type))
+	}
+
+	switch value := doc.(type) {
+	case string:
+		errs = append(errs, v.validateString(s, value, path)...)
+	case map[string]interface{}:
+		errs = append(errs, v.validateObject(s, value, path)...)
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range value {
+				errs = append(errs, v.validate(s.Items, item, path.Index(i))...)
+			}
+		}
+	}
+
+	return errs
+}
+
+func (v validator) anyOf(schemas []*Schema, doc interface{}, path *field.Path) bool {
+	for _, sub := range schemas {
+		if len(v.validate(sub, doc, path)) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v validator) validateString(s *Schema, value string, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	if len(s.Enum) > 0 && !contains(s.Enum, value) {
+		errs = append(errs, field.NotSupported(path, value, s.Enum))
+	}
+
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return append(errs, field.InternalError(path, fmt.Errorf("invalid pattern %s: %s", s.Pattern, err)))
+		}
+		if !re.MatchString(value) {
+			errs = append(errs, field.Invalid(path, value, "must match the pattern "+s.Pattern))
+		}
+	}
+
+	return errs
+}
+
+func (v validator) validateObject(s *Schema, value map[string]interface{}, path *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	for _, name := range s.Required {
+		if _, ok := value[name]; !ok {
+			errs = append(errs, field.Required(path.Child(name), ""))
+		}
+	}
+
+	names := []string{}
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// objects with additional properties are maps, so their values are
+	// referenced by key (e.g. metadata.annotations[jindra.io/inputs])
+	isMap := s.AdditionalProperties != nil && !s.AdditionalProperties.False
+	for _, name := range names {
+		child := path.Child(name)
+		if isMap {
+			child = path.Key(name)
+		}
+
+		property, ok := s.Properties[name]
+		switch {
+		case ok:
+			errs = append(errs, v.validate(property, value[name], child)...)
+		case s.AdditionalProperties == nil:
+		case s.AdditionalProperties.False:
+			errs = append(errs, field.Forbidden(child, "unknown field"))
+		default:
+			errs = append(errs, v.validate(s.AdditionalProperties, value[name], child)...)
+		}
+	}
+
+	return errs
+}
+
+// hasType returns whether doc is of the JSON type t -- null is valid for
+// every type as it is the value of omitted optional fields
+func hasType(doc interface{}, t string) bool {
+	if doc == nil {
+		return true
+	}
+
+	if n, ok := doc.(float64); ok && t == "integer" {
+		return n == math.Trunc(n)
+	}
+
+	return typeOf(doc) == t
+}
+
+// typeOf returns the JSON type of doc
+func typeOf(doc interface{}) string {
+	switch doc.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	return fmt.Sprintf("%T", doc)
+}
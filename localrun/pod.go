@@ -0,0 +1,252 @@
+package localrun
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+
+	jindra "github.com/kesselborn/jindra/api/v1alpha1"
+)
+
+// localIP is the ip of stage pods and the runner pod -- the watcher
+// container that needs the pod watcher of the runner pod is emulated
+const localIP = "127.0.0.1"
+
+// stagePod is a stage pod with its volumes as local directories
+type stagePod struct {
+	pod     core.Pod
+	volumes map[string]string
+	// secrets are the values of all secrets the containers reference
+	secrets []string
+}
+
+// newStagePod creates the directories of the volumes of pod: emptyDir
+// volumes are created in dir, claims below claimsDir and secret volumes are
+// copied from secretsDir
+func (r *Run) newStagePod(pod core.Pod, dir string) (*stagePod, error) {
+	s := &stagePod{pod: pod, volumes: map[string]string{}}
+	for _, v := range pod.Spec.Volumes {
+		var err error
+		switch {
+		case v.EmptyDir != nil:
+			s.volumes[v.Name], err = mkdir(filepath.Join(dir, v.Name))
+		case v.PersistentVolumeClaim != nil:
+			s.volumes[v.Name], err = mkdir(filepath.Join(r.claimsDir(), v.PersistentVolumeClaim.ClaimName))
+		case v.HostPath != nil:
+			s.volumes[v.Name] = v.HostPath.Path
+		case v.Secret != nil:
+			s.volumes[v.Name], err = s.secretVolume(r.SecretsDir, *v.Secret, filepath.Join(dir, v.Name))
+		default:
+			err = fmt.Errorf("only emptyDir, persistentVolumeClaim, hostPath and secret volumes can be used locally")
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("error creating volume %s: %s", v.Name, err)
+		}
+	}
+
+	return s, nil
+}
+
+// secretVolume copies the keys of the secret from secretsDir to dir
+func (s *stagePod) secretVolume(secretsDir string, secret core.SecretVolumeSource, dir string) (string, error) {
+	items := secret.Items
+	if len(items) == 0 {
+		files, err := ioutil.ReadDir(filepath.Join(secretsDir, secret.SecretName))
+		if err != nil && !(os.IsNotExist(err) && secret.Optional != nil && *secret.Optional) {
+			return "", fmt.Errorf("error reading secret %s: %s", secret.SecretName, err)
+		}
+		for _, f := range files {
+			items = append(items, core.KeyToPath{Key: f.Name(), Path: f.Name()})
+		}
+	}
+
+	if _, err := mkdir(dir); err != nil {
+		return "", err
+	}
+	for _, item := range items {
+		value, err := s.secretValue(secretsDir, secret.SecretName, item.Key)
+		if err != nil {
+			return "", err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, item.Path), []byte(value), 0644); err != nil {
+			return "", fmt.Errorf("error writing key %s of secret %s: %s", item.Key, secret.SecretName, err)
+		}
+	}
+
+	return dir, nil
+}
+
+// secretValue reads key of secret name from the file
+// <secretsDir>/<name>/<key>
+func (s *stagePod) secretValue(secretsDir, name, key string) (string, error) {
+	if secretsDir == "" {
+		return "", fmt.Errorf("secret %s is used but no secrets dir is set", name)
+	}
+
+	value, err := ioutil.ReadFile(filepath.Join(secretsDir, name, key))
+	if err != nil {
+		return "", fmt.Errorf("error reading key %s of secret %s: %s", key, name, err)
+	}
+	s.secrets = append(s.secrets, string(value))
+
+	return string(value), nil
+}
+
+// container returns the local container of c
+func (s *stagePod) container(secretsDir string, c core.Container) (Container, error) {
+	env, err := s.env(secretsDir, c)
+	if err != nil {
+		return Container{}, fmt.Errorf("error resolving env of container %s: %s", c.Name, err)
+	}
+
+	local := Container{
+		Name:       s.pod.Name + "." + c.Name,
+		Image:      c.Image,
+		Command:    c.Command,
+		Args:       c.Args,
+		WorkingDir: c.WorkingDir,
+		Env:        env,
+	}
+
+	for _, m := range c.VolumeMounts {
+		source, ok := s.volumes[m.Name]
+		if !ok {
+			return Container{}, fmt.Errorf("container %s mounts the unknown volume %s", c.Name, m.Name)
+		}
+		local.Mounts = append(local.Mounts, Mount{Source: filepath.Join(source, m.SubPath), Target: m.MountPath, ReadOnly: m.ReadOnly})
+	}
+
+	podSecurity := s.pod.Spec.SecurityContext
+	if podSecurity == nil {
+		podSecurity = &core.PodSecurityContext{}
+	}
+	security := c.SecurityContext
+	if security == nil {
+		security = &core.SecurityContext{}
+	}
+
+	user, group := security.RunAsUser, security.RunAsGroup
+	if user == nil {
+		user = podSecurity.RunAsUser
+	}
+	if group == nil {
+		group = podSecurity.RunAsGroup
+	}
+	if user != nil {
+		local.User = strconv.FormatInt(*user, 10)
+		if group != nil {
+			local.User += ":" + strconv.FormatInt(*group, 10)
+		}
+	}
+	if podSecurity.FSGroup != nil {
+		local.Groups = append(local.Groups, strconv.FormatInt(*podSecurity.FSGroup, 10))
+	}
+	for _, g := range podSecurity.SupplementalGroups {
+		local.Groups = append(local.Groups, strconv.FormatInt(g, 10))
+	}
+	local.ReadOnlyRootFilesystem = security.ReadOnlyRootFilesystem != nil && *security.ReadOnlyRootFilesystem
+
+	return local, nil
+}
+
+// env resolves the env of c -- secrets are read from secretsDir, config maps
+// can't be used locally
+func (s *stagePod) env(secretsDir string, c core.Container) ([]string, error) {
+	env := []string{}
+	for _, from := range c.EnvFrom {
+		if from.SecretRef == nil {
+			return nil, fmt.Errorf("only secrets can be used in envFrom locally")
+		}
+		files, err := ioutil.ReadDir(filepath.Join(secretsDir, from.SecretRef.Name))
+		if err != nil {
+			if os.IsNotExist(err) && from.SecretRef.Optional != nil && *from.SecretRef.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("error reading secret %s: %s", from.SecretRef.Name, err)
+		}
+		for _, f := range files {
+			value, err := s.secretValue(secretsDir, from.SecretRef.Name, f.Name())
+			if err != nil {
+				return nil, err
+			}
+			env = append(env, from.Prefix+f.Name()+"="+value)
+		}
+	}
+
+	for _, e := range c.Env {
+		value, err := s.envValue(secretsDir, e)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", e.Name, err)
+		}
+		env = append(env, e.Name+"="+value)
+	}
+
+	return env, nil
+}
+
+func (s *stagePod) envValue(secretsDir string, e core.EnvVar) (string, error) {
+	switch {
+	case e.ValueFrom == nil:
+		return e.Value, nil
+	case e.ValueFrom.SecretKeyRef != nil:
+		ref := e.ValueFrom.SecretKeyRef
+		value, err := s.secretValue(secretsDir, ref.Name, ref.Key)
+		if err != nil && ref.Optional != nil && *ref.Optional {
+			return "", nil
+		}
+		return value, err
+	case e.ValueFrom.FieldRef != nil:
+		switch e.ValueFrom.FieldRef.FieldPath {
+		case "metadata.name":
+			return s.pod.Name, nil
+		case "metadata.namespace":
+			return "default", nil
+		case "metadata.uid":
+			return localUID, nil
+		case "spec.nodeName":
+			return os.Hostname()
+		case "spec.serviceAccountName":
+			return s.pod.Spec.ServiceAccountName, nil
+		case "status.podIP", "status.hostIP":
+			return localIP, nil
+		}
+		return "", fmt.Errorf("field %s can't be used locally", e.ValueFrom.FieldRef.FieldPath)
+	}
+
+	return "", fmt.Errorf("only values, secrets and fields can be used locally")
+}
+
+// semaphoresDir returns the directory of the semaphore files
+func (s *stagePod) semaphoresDir() string {
+	return s.volumes[jindra.SemaphoresVolumeName]
+}
+
+// waitFor returns the containers the stage result depends on
+func (s *stagePod) waitFor() []string {
+	names := []string{}
+	for _, name := range strings.Split(s.pod.Annotations[jindra.WaitForAnnotationKey], ",") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// mkdir creates dir writable for all users -- containers run with other
+// users than the local user
+func mkdir(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return "", err
+	}
+
+	return dir, os.Chmod(dir, 0777)
+}
@@ -0,0 +1,389 @@
+// Package localrun runs pipelines without a cluster: the containers of the
+// stage pods that jindra generates for a run are executed with a local
+// container runtime (docker or podman). Volumes of the stage pods are local
+// directories, transit channels and other claims are directories that are
+// shared by all stages, and the runner pod is emulated: the watcher
+// container is replaced by deleting the semaphore files of the steps once
+// they finished.
+package localrun
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	core "k8s.io/api/core/v1"
+
+	jindra "github.com/kesselborn/jindra/api/v1alpha1"
+	"github.com/kesselborn/jindra/mask"
+)
+
+// localUID is the uid of the emulated runner pod
+const localUID = "00000000-0000-0000-0000-000000000000"
+
+// pauseImage is the image of the container that holds the network
+// namespace of a stage pod
+const pauseImage = "registry.k8s.io/pause:3.9"
+
+// finalStages matches the config map keys of the stages that run after the
+// stages -- like in the runner script
+var finalStages = regexp.MustCompile(`^[0-9][0-9]-(on-success|on-error|final)\.yaml$`)
+
+// Run is a local run of a pipeline
+type Run struct {
+	Pipeline jindra.Pipeline
+	BuildNo  int
+	// Dir is the directory of the volumes: volumes of stage pods are created
+	// in <dir>/<runner name>/<stage>, claims (transit channels and caches
+	// with a pvc store) in <dir>/claims
+	Dir string
+	// SecretsDir contains the secrets the stages reference, one file per key:
+	// <secrets dir>/<secret>/<key>
+	SecretsDir string
+	Runtime    Runtime
+	// Out receives the progress of the run and the output of all containers
+	Out io.Writer
+
+	mu      sync.Mutex
+	running map[string]bool
+	stopped bool
+}
+
+// Execute runs the stages like the runner pod: the stages run one after
+// another until a stage fails, then on-success or on-error and final run --
+// it returns an error if a stage failed
+func (r *Run) Execute() error {
+	// relative mount sources are names of volumes for the container runtime
+	for _, dir := range []*string{&r.Dir, &r.SecretsDir} {
+		if *dir == "" {
+			continue
+		}
+		abs, err := filepath.Abs(*dir)
+		if err != nil {
+			return fmt.Errorf("error resolving directory %s: %s", *dir, err)
+		}
+		*dir = abs
+	}
+
+	ppl := *r.Pipeline.DeepCopy()
+	ppl.Spec.Transit = jindra.Transit{Backend: jindra.PVCTransitBackend}
+
+	if err := r.removeTransitClaims(ppl); err != nil {
+		return err
+	}
+
+	cm, err := ppl.PipelineRunConfigMap(r.BuildNo)
+	if err != nil {
+		return fmt.Errorf("error generating stage pods: %s", err)
+	}
+
+	keys := []string{}
+	for key := range cm.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var runErr error
+	for _, key := range keys {
+		if finalStages.MatchString(key) {
+			continue
+		}
+		if runErr = r.runStage(key, cm.Data[key]); runErr != nil {
+			break
+		}
+	}
+
+	next := "-on-success.yaml"
+	if runErr != nil {
+		next = "-on-error.yaml"
+	}
+	for _, key := range keys {
+		if !finalStages.MatchString(key) || !(strings.HasSuffix(key, next) || strings.HasSuffix(key, "-final.yaml")) {
+			continue
+		}
+		if err := r.runStage(key, cm.Data[key]); err != nil && runErr == nil {
+			runErr = err
+		}
+	}
+
+	return runErr
+}
+
+// Stop stops the containers that are running and skips all stages that
+// didn't start yet
+func (r *Run) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopped = true
+	for name := range r.running {
+		r.Runtime.Stop(name)
+	}
+}
+
+// removeTransitClaims removes the data of the transit channels of a
+// previous run with the same build number
+func (r *Run) removeTransitClaims(ppl jindra.Pipeline) error {
+	objects, err := ppl.TransitObjects(r.BuildNo)
+	if err != nil {
+		return fmt.Errorf("error creating transit objects: %s", err)
+	}
+
+	for _, o := range objects {
+		if claim, ok := o.(*core.PersistentVolumeClaim); ok {
+			if err := os.RemoveAll(filepath.Join(r.claimsDir(), claim.Name)); err != nil {
+				return fmt.Errorf("error removing transit data: %s", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Run) claimsDir() string {
+	return filepath.Join(r.Dir, "claims")
+}
+
+// runStage runs the stage pod -- init containers run one after another,
+// then all containers start
+func (r *Run) runStage(key string, podYaml string) error {
+	stageName := strings.TrimSuffix(key, ".yaml")
+	if r.isStopped() {
+		return fmt.Errorf("stage %s: run was stopped", stageName)
+	}
+
+	hostname, _ := os.Hostname()
+	placeholders := strings.NewReplacer(
+		"${MY_NAME}", r.Pipeline.RunnerName(r.BuildNo),
+		"${MY_UID}", localUID,
+		"${MY_NODE_NAME}", hostname,
+		"${MY_IP}", localIP,
+	)
+
+	var pod core.Pod
+	if err := yaml.Unmarshal([]byte(placeholders.Replace(podYaml)), &pod); err != nil {
+		return fmt.Errorf("error parsing stage %s: %s", stageName, err)
+	}
+
+	dir := filepath.Join(r.Dir, r.Pipeline.RunnerName(r.BuildNo), stageName)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("error removing volumes of previous runs of stage %s: %s", stageName, err)
+	}
+
+	s, err := r.newStagePod(pod, dir)
+	if err != nil {
+		return fmt.Errorf("stage %s: %s", stageName, err)
+	}
+
+	r.progress("stage %s started", stageName)
+	start := time.Now()
+	if err := r.runPod(s); err != nil {
+		r.progress("stage %s failed after %s: %s", stageName, time.Since(start).Round(time.Second), err)
+		return fmt.Errorf("stage %s failed: %s", stageName, err)
+	}
+	r.progress("stage %s succeeded after %s", stageName, time.Since(start).Round(time.Second))
+
+	return nil
+}
+
+func (r *Run) runPod(s *stagePod) error {
+	initContainers, err := r.containers(s, s.pod.Spec.InitContainers)
+	if err != nil {
+		return err
+	}
+	containers, err := r.containers(s, s.pod.Spec.Containers)
+	if err != nil {
+		return err
+	}
+	masker := mask.New(s.secrets...)
+
+	// like in a pod, all containers share the network namespace (services
+	// are reachable via localhost)
+	if err := r.Runtime.Start(Container{Name: s.pod.Name, Image: pauseImage}); err != nil {
+		return err
+	}
+	defer r.Runtime.Stop(s.pod.Name)
+
+	for _, c := range initContainers {
+		if err := r.run(c, masker); err != nil {
+			return fmt.Errorf("init container %s failed", r.shortName(s, c))
+		}
+	}
+
+	results := map[string]chan error{}
+	for _, c := range containers {
+		result := make(chan error, 1)
+		results[strings.TrimPrefix(c.Name, s.pod.Name+".")] = result
+		go func(c Container) {
+			result <- r.run(c, masker)
+		}(c)
+	}
+	defer func() {
+		// services and outputs that wait for the steps are still running
+		for _, c := range containers {
+			r.Runtime.Stop(c.Name)
+		}
+		for _, result := range results {
+			<-result
+		}
+	}()
+
+	waitFor := func(names []string) bool {
+		succeeded := true
+		for _, name := range names {
+			if result, ok := results[name]; ok {
+				err := <-result
+				result <- err
+				succeeded = succeeded && err == nil
+			}
+		}
+		return succeeded
+	}
+
+	// emulate the watcher container
+	steps, debug := []string{}, []string{}
+	for _, name := range s.waitFor() {
+		if name == jindra.DebugContainerName {
+			debug = append(debug, name)
+			continue
+		}
+		steps = append(steps, name)
+	}
+	stepsSucceeded := waitFor(steps)
+	os.Remove(filepath.Join(s.semaphoresDir(), jindra.StepsFinishedSemaphore))
+	if stepsSucceeded {
+		os.Remove(filepath.Join(s.semaphoresDir(), jindra.StepsRunningSemaphore))
+	}
+	stepsSucceeded = waitFor(debug) && stepsSucceeded
+
+	outputs, artifacts := []string{}, []string{}
+	for _, c := range s.pod.Spec.Containers {
+		switch {
+		case c.Name == jindra.ArtifactsContainerName:
+			artifacts = append(artifacts, c.Name)
+			outputs = append(outputs, c.Name)
+		case strings.HasPrefix(c.Name, jindra.OutResourceContainerNamePrefix), strings.HasPrefix(c.Name, jindra.CacheSaveContainerNamePrefix):
+			outputs = append(outputs, c.Name)
+		}
+	}
+
+	if !stepsSucceeded {
+		// artifacts are saved even if the steps failed
+		waitFor(artifacts)
+		return fmt.Errorf("steps %s failed", strings.Join(s.waitFor(), ","))
+	}
+
+	if !waitFor(outputs) {
+		return fmt.Errorf("outputs %s failed", strings.Join(outputs, ","))
+	}
+
+	return nil
+}
+
+// containers returns the local containers of the containers of the stage
+// pod -- the watcher container is emulated
+func (r *Run) containers(s *stagePod, containers []core.Container) ([]Container, error) {
+	local := []Container{}
+	for _, c := range containers {
+		if c.Name == jindra.WatcherContainerName {
+			continue
+		}
+
+		container, err := s.container(r.SecretsDir, c)
+		if err != nil {
+			return nil, err
+		}
+		container.Network = "container:" + s.pod.Name
+		local = append(local, container)
+	}
+
+	return local, nil
+}
+
+// run runs container c and streams its output prefixed with its name
+func (r *Run) run(c Container, masker *mask.Masker) error {
+	r.mu.Lock()
+	if r.stopped {
+		r.mu.Unlock()
+		return fmt.Errorf("run was stopped")
+	}
+	if r.running == nil {
+		r.running = map[string]bool{}
+	}
+	r.running[c.Name] = true
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		delete(r.running, c.Name)
+		r.mu.Unlock()
+	}()
+
+	out := &lineWriter{run: r, prefix: c.Name + " | ", masker: masker}
+	err := r.Runtime.Run(c, out)
+	out.Flush()
+
+	return err
+}
+
+func (r *Run) isStopped() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stopped
+}
+
+func (r *Run) shortName(s *stagePod, c Container) string {
+	return strings.TrimPrefix(c.Name, s.pod.Name+".")
+}
+
+// progress writes a progress line
+func (r *Run) progress(format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fmt.Fprintf(r.Out, "==> "+format+"\n", args...)
+}
+
+// lineWriter writes complete lines with a prefix to the output of a run
+// and masks secrets
+type lineWriter struct {
+	run    *Run
+	prefix string
+	masker *mask.Masker
+	buf    bytes.Buffer
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.writeLine(string(w.buf.Next(i + 1)))
+	}
+}
+
+// Flush writes the last line if it doesn't end with a new line
+func (w *lineWriter) Flush() {
+	if w.buf.Len() > 0 {
+		w.writeLine(w.buf.String() + "\n")
+		w.buf.Reset()
+	}
+}
+
+func (w *lineWriter) writeLine(line string) {
+	w.run.mu.Lock()
+	defer w.run.mu.Unlock()
+
+	io.WriteString(w.run.Out, w.prefix+w.masker.String(line))
+}
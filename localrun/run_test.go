@@ -0,0 +1,350 @@
+package localrun
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jindra "github.com/kesselborn/jindra/api/v1alpha1"
+)
+
+const fixtureDir = "../tests/fixtures"
+
+// fakeRuntime emulates the containers the runner depends on: get-jindra-tools
+// creates the semaphores and output containers wait until the steps
+// succeeded
+type fakeRuntime struct {
+	mu      sync.Mutex
+	started []string
+	fail    map[string]bool
+	stopped map[string]bool
+	// relativeMounts are the mount sources that are not absolute
+	relativeMounts []string
+	// pods are the started pause containers of the stage pods
+	pods     []string
+	networks map[string]string
+}
+
+func (f *fakeRuntime) Run(c Container, out io.Writer) error {
+	f.mu.Lock()
+	f.started = append(f.started, c.Name)
+	f.networks[c.Name] = c.Network
+	for _, m := range c.Mounts {
+		if !filepath.IsAbs(m.Source) {
+			f.relativeMounts = append(f.relativeMounts, m.Source)
+		}
+	}
+	f.mu.Unlock()
+
+	name := c.Name[strings.LastIndex(c.Name, ".")+1:]
+	semaphores := ""
+	for _, m := range c.Mounts {
+		if m.Target == "/var/lock/jindra" {
+			semaphores = m.Source
+		}
+	}
+
+	switch {
+	case name == "get-jindra-tools":
+		for _, semaphore := range []string{jindra.StepsRunningSemaphore, jindra.StepsFinishedSemaphore} {
+			if err := ioutil.WriteFile(filepath.Join(semaphores, semaphore), nil, 0644); err != nil {
+				return err
+			}
+		}
+	case strings.HasPrefix(name, jindra.OutResourceContainerNamePrefix):
+		for {
+			if _, err := os.Stat(filepath.Join(semaphores, jindra.StepsRunningSemaphore)); os.IsNotExist(err) {
+				break
+			}
+			if f.isStopped(c.Name) {
+				return fmt.Errorf("container %s was stopped", c.Name)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	fmt.Fprintf(out, "%s started\n", name)
+	for _, env := range c.Env {
+		if strings.HasPrefix(env, "git.source.private_key=") {
+			fmt.Fprintf(out, "%s\n", env)
+		}
+	}
+
+	if f.fail[name] {
+		return fmt.Errorf("container %s failed", c.Name)
+	}
+
+	return nil
+}
+
+func (f *fakeRuntime) Start(c Container) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.pods = append(f.pods, c.Name)
+
+	return nil
+}
+
+func (f *fakeRuntime) Stop(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.stopped == nil {
+		f.stopped = map[string]bool{}
+	}
+	f.stopped[name] = true
+
+	return nil
+}
+
+func (f *fakeRuntime) isStopped(name string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.stopped[name]
+}
+
+// stages returns the stages of the started containers in the order they
+// started
+func (f *fakeRuntime) stages() []string {
+	stages := []string{}
+	for _, name := range f.started {
+		stage := strings.Split(name, ".")[3]
+		if len(stages) == 0 || stages[len(stages)-1] != stage {
+			stages = append(stages, stage)
+		}
+	}
+
+	return stages
+}
+
+// newTestRun returns a run of the example pipeline -- the returned func
+// removes its directories, which contain the secrets
+func newTestRun(t *testing.T, fail ...string) (*Run, *fakeRuntime, *bytes.Buffer, func()) {
+	data, err := ioutil.ReadFile(filepath.Join(fixtureDir, "pipeline-example.yaml"))
+	if err != nil {
+		t.Fatalf("error reading pipeline: %s", err)
+	}
+	ppl, err := jindra.NewPipelineFromYaml(data)
+	if err != nil {
+		t.Fatalf("error parsing pipeline: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "jindra-local-run")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %s", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	for file, value := range map[string]string{
+		"deploy-key/key":     "s3cr3t-deploy-key",
+		"dockerhub/password": "s3cr3t-password",
+		"dockerhub/username": "docker-user",
+		"slack/webhook_url":  "https://hooks.slack.com/s3cr3t",
+	} {
+		path := filepath.Join(dir, "secrets", file)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			cleanup()
+			t.Fatalf("error creating secrets dir: %s", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(value), 0600); err != nil {
+			cleanup()
+			t.Fatalf("error writing secret: %s", err)
+		}
+	}
+
+	runtime := &fakeRuntime{fail: map[string]bool{}, networks: map[string]string{}}
+	for _, name := range fail {
+		runtime.fail[name] = true
+	}
+	out := &bytes.Buffer{}
+
+	return &Run{
+		Pipeline:   ppl,
+		BuildNo:    42,
+		Dir:        filepath.Join(dir, "volumes"),
+		SecretsDir: filepath.Join(dir, "secrets"),
+		Runtime:    runtime,
+		Out:        out,
+	}, runtime, out, cleanup
+}
+
+func TestExecute(t *testing.T) {
+	for i, test := range []struct {
+		fail     []string
+		expected []string
+		desc     string
+	}{
+		{[]string{}, []string{"01-build-go-binary", "02-build-docker-image", "03-on-success", "05-final"},
+			"on success runs after all stages succeeded"},
+		{[]string{"build-go-binary"}, []string{"01-build-go-binary", "04-on-error", "05-final"},
+			"on error runs after a failed stage"},
+		{[]string{"hello"}, []string{"01-build-go-binary", "04-on-error", "05-final"},
+			"stages fail if an init container fails"},
+		{[]string{"jindra-resource-out-registry-image"}, []string{"01-build-go-binary", "02-build-docker-image", "04-on-error", "05-final"},
+			"stages fail if an output fails"},
+	} {
+		run, runtime, out, cleanup := newTestRun(t, test.fail...)
+		defer cleanup()
+
+		err := run.Execute()
+		if (err != nil) != (len(test.fail) > 0) {
+			t.Fatalf("\t%2d: %-80s [FAILED]: unexpected result: %v\n%s", i, test.desc, err, out)
+		}
+
+		if got := runtime.stages(); strings.Join(got, ",") != strings.Join(test.expected, ",") {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected, got))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestExecuteOutput(t *testing.T) {
+	run, runtime, out, cleanup := newTestRun(t)
+	defer cleanup()
+
+	if err := run.Execute(); err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+
+	for i, test := range []struct {
+		check func() bool
+		desc  string
+	}{
+		{func() bool { return strings.Contains(out.String(), "==> stage 01-build-go-binary started\n") },
+			"progress of the stages is written"},
+		{func() bool {
+			return strings.Contains(out.String(), "jindra.http-fs.42.01-build-go-binary.jindra-resource-in-git | jindra-resource-in-git started")
+		}, "output of containers is prefixed with the container name"},
+		{func() bool {
+			return strings.Contains(out.String(), "git.source.private_key=***") && !strings.Contains(out.String(), "s3cr3t-deploy-key")
+		}, "secrets are masked"},
+		{func() bool {
+			for _, name := range runtime.started {
+				if strings.HasSuffix(name, "."+jindra.WatcherContainerName) {
+					return false
+				}
+			}
+			return true
+		}, "the watcher container is emulated"},
+		{func() bool {
+			pods := map[string]bool{}
+			for _, pod := range runtime.pods {
+				pods[pod] = true
+			}
+			for _, name := range runtime.started {
+				pod := name[:strings.LastIndex(name, ".")]
+				if !pods[pod] || runtime.networks[name] != "container:"+pod {
+					return false
+				}
+			}
+			return true
+		}, "containers share the network of their stage pod"},
+		{func() bool {
+			_, err := os.Stat(filepath.Join(run.claimsDir(), "jindra.http-fs.42.transit"))
+			return err == nil
+		}, "transit channels are shared directories"},
+	} {
+		if !test.check() {
+			t.Fatalf("\t%2d: %-80s [FAILED]\n%s", i, test.desc, out)
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func TestExecuteRelativeDir(t *testing.T) {
+	run, runtime, out, cleanup := newTestRun(t)
+	defer cleanup()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("error getting working directory: %s", err)
+	}
+	for _, dir := range []*string{&run.Dir, &run.SecretsDir} {
+		if *dir, err = filepath.Rel(wd, *dir); err != nil {
+			t.Fatalf("error creating relative directory: %s", err)
+		}
+	}
+
+	if err := run.Execute(); err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+
+	if len(runtime.relativeMounts) > 0 {
+		t.Fatalf("%s", errMsg(t, "only absolute mount sources", runtime.relativeMounts))
+	}
+}
+
+func TestRunArgs(t *testing.T) {
+	c := Container{
+		Name:    "jindra.http-fs.42.01-build-go-binary.build-go-binary",
+		Image:   "golang",
+		Command: []string{"sh", "-c"},
+		Args:    []string{"go build"},
+		Env:     []string{"SECRET=s3cr3t", "HOME=/root", "KEY=-----BEGIN KEY-----\ns3cr3t\n-----END KEY-----"},
+		Mounts: []Mount{
+			{Source: "/tmp/git", Target: "/jindra/resources/git"},
+			{Source: "/tmp/tools", Target: "/jindra/tools", ReadOnly: true},
+		},
+		User:                   "1000:1000",
+		Groups:                 []string{"2000"},
+		ReadOnlyRootFilesystem: true,
+		WorkingDir:             "/jindra/resources/git",
+		Network:                "container:jindra.http-fs.42.01-build-go-binary",
+	}
+
+	expected := "run --rm --name jindra.http-fs.42.01-build-go-binary.build-go-binary --user 1000:1000 --group-add 2000 --read-only " +
+		"--workdir /jindra/resources/git --network container:jindra.http-fs.42.01-build-go-binary --env-file /tmp/jindra-env --env KEY " +
+		"--volume /tmp/git:/jindra/resources/git --volume /tmp/tools:/jindra/tools:ro " +
+		"--entrypoint sh golang -c go build"
+
+	if got := strings.Join(CLI{Command: "docker"}.RunArgs(c, "/tmp/jindra-env"), " "); got != expected {
+		t.Fatalf("%s", errMsg(t, expected, got))
+	}
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	envFile, err := writeEnvFile(Container{Env: []string{"SECRET=s3cr3t", "HOME=/tmp", "KEY=-----BEGIN KEY-----\ns3cr3t\n-----END KEY-----"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer os.Remove(envFile)
+
+	info, err := os.Stat(envFile)
+	if err != nil {
+		t.Fatalf("error reading env file: %s", err)
+	}
+	data, err := ioutil.ReadFile(envFile)
+	if err != nil {
+		t.Fatalf("error reading env file: %s", err)
+	}
+
+	for i, test := range []struct {
+		expected interface{}
+		got      interface{}
+		desc     string
+	}{
+		{os.FileMode(0600), info.Mode().Perm(), "only the user can read the env file"},
+		{"SECRET=s3cr3t\nHOME=/tmp\n", string(data), "env file contains the values that fit on one line"},
+	} {
+		if test.expected != test.got {
+			t.Fatalf("\t%2d: %-80s %s", i, test.desc, errMsg(t, test.expected, test.got))
+		}
+		t.Logf("\t%2d: %-80s %s", i, test.desc, ok())
+	}
+}
+
+func ok() string {
+	return " [OK]"
+}
+
+func errMsg(t *testing.T, expected interface{}, got interface{}) string {
+	return fmt.Sprintf(" [FAILED]\n\t\texpected: %v\n\t\tgot:      %v", expected, got)
+}
@@ -0,0 +1,188 @@
+package localrun
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Container is a container of a stage pod with its volumes resolved to
+// local directories and its env resolved to values
+type Container struct {
+	Name  string
+	Image string
+	// Command replaces the entrypoint of the image
+	Command    []string
+	Args       []string
+	WorkingDir string
+	// Env contains 'name=value' pairs
+	Env                    []string
+	Mounts                 []Mount
+	User                   string
+	Groups                 []string
+	ReadOnlyRootFilesystem bool
+	// Network is the network of the container: 'container:<name>' shares
+	// the network namespace of another container
+	Network string
+}
+
+// Mount is a local directory mounted into a container
+type Mount struct {
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+// Runtime runs containers
+type Runtime interface {
+	// Run runs the container until it exits and writes its output to out --
+	// an exit code other than 0 is an error
+	Run(c Container, out io.Writer) error
+	// Start starts the container in the background
+	Start(c Container) error
+	// Stop stops and removes the container if it is still running
+	Stop(name string) error
+}
+
+// CLI is a Runtime that calls the command line interface of docker or
+// podman
+type CLI struct {
+	Command string
+}
+
+// DetectRuntime returns the CLI of docker or -- if docker is not installed
+// -- podman
+func DetectRuntime() (CLI, error) {
+	for _, command := range []string{"docker", "podman"} {
+		if _, err := exec.LookPath(command); err == nil {
+			return CLI{Command: command}, nil
+		}
+	}
+
+	return CLI{}, fmt.Errorf("neither docker nor podman found in PATH")
+}
+
+// Run implements Runtime
+func (r CLI) Run(c Container, out io.Writer) error {
+	envFile, err := writeEnvFile(c)
+	if err != nil {
+		return fmt.Errorf("container %s: %s", c.Name, err)
+	}
+	defer os.Remove(envFile)
+
+	cmd := exec.Command(r.Command, r.RunArgs(c, envFile)...)
+	// values are not passed as arguments, so secrets don't show up in the
+	// process list: values with new lines can't be written to the env file
+	// and are passed via the environment of the cli instead
+	cmd.Env = os.Environ()
+	for _, env := range c.Env {
+		name := strings.SplitN(env, "=", 2)[0]
+		if !strings.Contains(env, "\n") {
+			continue
+		}
+		if _, ok := os.LookupEnv(name); ok {
+			return fmt.Errorf("container %s: env %s has multiple lines and is set for %s as well", c.Name, name, r.Command)
+		}
+		cmd.Env = append(cmd.Env, env)
+	}
+	cmd.Stdout, cmd.Stderr = out, out
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("container %s failed: %s", c.Name, err)
+	}
+
+	return nil
+}
+
+// Start implements Runtime -- a container of a previous run with the same
+// name is removed
+func (r CLI) Start(c Container) error {
+	r.Stop(c.Name)
+
+	cmd := exec.Command(r.Command, append([]string{"run", "--detach"}, r.RunArgs(c, "")[1:]...)...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("container %s failed to start: %s: %s", c.Name, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// writeEnvFile writes the env of c that fits on one line to a temporary file
+// only the user can read
+func writeEnvFile(c Container) (string, error) {
+	f, err := ioutil.TempFile("", "jindra-env")
+	if err != nil {
+		return "", fmt.Errorf("error creating env file: %s", err)
+	}
+	defer f.Close()
+
+	for _, env := range c.Env {
+		if strings.Contains(env, "\n") {
+			continue
+		}
+		if _, err := fmt.Fprintln(f, env); err != nil {
+			os.Remove(f.Name())
+			return "", fmt.Errorf("error writing env file: %s", err)
+		}
+	}
+
+	return f.Name(), nil
+}
+
+// Stop implements Runtime
+func (r CLI) Stop(name string) error {
+	cmd := exec.Command(r.Command, "rm", "--force", name)
+	cmd.Stdout, cmd.Stderr = ioutil.Discard, ioutil.Discard
+
+	return cmd.Run()
+}
+
+// RunArgs returns the arguments of '<cli> run' for container c -- envFile
+// contains the env of c that fits on one line, all other values are passed
+// by name
+func (r CLI) RunArgs(c Container, envFile string) []string {
+	args := []string{"run", "--rm", "--name", c.Name}
+	if c.User != "" {
+		args = append(args, "--user", c.User)
+	}
+	for _, group := range c.Groups {
+		args = append(args, "--group-add", group)
+	}
+	if c.ReadOnlyRootFilesystem {
+		args = append(args, "--read-only")
+	}
+	if c.WorkingDir != "" {
+		args = append(args, "--workdir", c.WorkingDir)
+	}
+	if c.Network != "" {
+		args = append(args, "--network", c.Network)
+	}
+	if envFile != "" {
+		args = append(args, "--env-file", envFile)
+	}
+	for _, env := range c.Env {
+		if strings.Contains(env, "\n") {
+			args = append(args, "--env", strings.SplitN(env, "=", 2)[0])
+		}
+	}
+	for _, m := range c.Mounts {
+		volume := m.Source + ":" + m.Target
+		if m.ReadOnly {
+			volume += ":ro"
+		}
+		args = append(args, "--volume", volume)
+	}
+
+	command := c.Command
+	if len(command) > 0 {
+		args = append(args, "--entrypoint", command[0])
+		command = command[1:]
+	}
+	args = append(args, c.Image)
+	args = append(args, command...)
+
+	return append(args, c.Args...)
+}
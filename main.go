@@ -20,7 +20,9 @@ import (
 	"os"
 
 	civ1alpha1 "github.com/kesselborn/jindra/api/v1alpha1"
+	civ1beta1 "github.com/kesselborn/jindra/api/v1beta1"
 	"github.com/kesselborn/jindra/controllers"
+	"github.com/kesselborn/jindra/resources"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -38,6 +40,7 @@ func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
 	_ = civ1alpha1.AddToScheme(scheme)
+	_ = civ1beta1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -72,6 +75,7 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Pipeline")
 		os.Exit(1)
 	}
+	civ1alpha1.SetNativeResourceRegistry(resources.Registry{})
 	if err = (&civ1alpha1.Pipeline{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "Pipeline")
 		os.Exit(1)
@@ -0,0 +1,104 @@
+// Package mask replaces secret values in logs. Besides the plain value, the
+// base64 and url encoded forms of a secret are masked -- and every line of
+// multi line secrets like private keys, as logs are masked line by line.
+package mask
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Mask replaces secret values
+const Mask = "***"
+
+// minLength is the minimal length of values that are masked -- shorter
+// values would mask arbitrary parts of the log
+const minLength = 4
+
+// Masker replaces secret values in text
+type Masker struct {
+	replacer *strings.Replacer
+}
+
+// New returns a masker for the values of secrets
+func New(secrets ...string) *Masker {
+	values := map[string]bool{}
+	add := func(value string) {
+		if len(value) < minLength {
+			return
+		}
+		for _, v := range []string{
+			value,
+			base64.StdEncoding.EncodeToString([]byte(value)),
+			base64.RawStdEncoding.EncodeToString([]byte(value)),
+			base64.URLEncoding.EncodeToString([]byte(value)),
+			base64.RawURLEncoding.EncodeToString([]byte(value)),
+			url.QueryEscape(value),
+			url.PathEscape(value),
+		} {
+			values[v] = true
+		}
+	}
+
+	for _, secret := range secrets {
+		add(secret)
+		add(strings.TrimSpace(secret))
+		for _, line := range strings.Split(secret, "\n") {
+			add(strings.TrimSpace(line))
+		}
+	}
+
+	if len(values) == 0 {
+		return &Masker{}
+	}
+
+	// the replacer prefers the first of overlapping values, so longer values
+	// have to come first in order to not leave parts of them unmasked
+	sorted := []string{}
+	for v := range values {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+
+	oldnew := []string{}
+	for _, v := range sorted {
+		oldnew = append(oldnew, v, Mask)
+	}
+
+	return &Masker{replacer: strings.NewReplacer(oldnew...)}
+}
+
+// String returns s with all secret values replaced by Mask
+func (m *Masker) String(s string) string {
+	if m.replacer == nil {
+		return s
+	}
+
+	return m.replacer.Replace(s)
+}
+
+// Copy copies r to w line by line and masks every line
+func (m *Masker) Copy(w io.Writer, r io.Reader) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if _, writeErr := io.WriteString(w, m.String(line)); writeErr != nil {
+			return writeErr
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
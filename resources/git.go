@@ -0,0 +1,258 @@
+package resources
+
+import (
+	"fmt"
+	"path"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"golang.org/x/crypto/ssh"
+)
+
+func init() {
+	Register("git", gitResource{})
+}
+
+// gitSource configures the git resource:
+//
+// uri:         uri of the repository
+// branch:      branch to track (default: master)
+// private_key: private ssh key for ssh uris (host keys are not verified)
+// username:    username for http(s) uris
+// password:    password for http(s) uris
+type gitSource struct {
+	URI        string `json:"uri"`
+	Branch     string `json:"branch"`
+	PrivateKey string `json:"private_key"`
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+}
+
+// gitOutParams configures the out call of the git resource:
+//
+// repository: path of the repository (relative to the resource directory)
+//             whose HEAD is pushed to the source branch
+// force:      force push
+type gitOutParams struct {
+	Repository string `json:"repository"`
+	Force      bool   `json:"force"`
+}
+
+// gitResource tracks the commits of a branch of a git repository -- versions
+// are identified by the commit sha ({"ref": "<sha>"})
+type gitResource struct{}
+
+func (r gitResource) source(req Request) (gitSource, error) {
+	var source gitSource
+	if err := decode(req.Source, &source); err != nil {
+		return source, err
+	}
+
+	if source.URI == "" {
+		return source, fmt.Errorf("source.uri must be set")
+	}
+
+	if source.Branch == "" {
+		source.Branch = "master"
+	}
+
+	return source, nil
+}
+
+func (s gitSource) auth() (transport.AuthMethod, error) {
+	switch {
+	case s.PrivateKey != "":
+		auth, err := gitssh.NewPublicKeys("git", []byte(s.PrivateKey), "")
+		if err != nil {
+			return nil, fmt.Errorf("error parsing private key: %s", err)
+		}
+		auth.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+		return auth, nil
+	case s.Username != "":
+		return &githttp.BasicAuth{Username: s.Username, Password: s.Password}, nil
+	}
+
+	return nil, nil
+}
+
+func (s gitSource) cloneOptions() (*git.CloneOptions, error) {
+	auth, err := s.auth()
+	if err != nil {
+		return nil, err
+	}
+
+	return &git.CloneOptions{
+		URL:           s.URI,
+		Auth:          auth,
+		ReferenceName: plumbing.NewBranchReferenceName(s.Branch),
+		SingleBranch:  true,
+	}, nil
+}
+
+func (r gitResource) Check(req Request) ([]Version, error) {
+	source, err := r.source(req)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := source.cloneOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := git.Clone(memory.NewStorage(), nil, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error cloning %s: %s", source.URI, err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("error getting head of %s: %s", source.Branch, err)
+	}
+
+	current := Version{"ref": head.Hash().String()}
+	if req.Version == nil || req.Version["ref"] == "" {
+		return []Version{current}, nil
+	}
+
+	commits, err := repo.Log(&git.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, fmt.Errorf("error reading log of %s: %s", source.Branch, err)
+	}
+	defer commits.Close()
+
+	// collect all commits up to the requested version (newest first)
+	versions := []Version{}
+	found := false
+	for {
+		c, err := commits.Next()
+		if err != nil {
+			break
+		}
+		versions = append(versions, Version{"ref": c.Hash.String()})
+		if c.Hash.String() == req.Version["ref"] {
+			found = true
+			break
+		}
+	}
+
+	// history was rewritten: the requested version does not exist anymore
+	if !found {
+		return []Version{current}, nil
+	}
+
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
+	}
+
+	return versions, nil
+}
+
+func (r gitResource) In(dir string, req Request) (Response, error) {
+	source, err := r.source(req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	opts, err := source.cloneOptions()
+	if err != nil {
+		return Response{}, err
+	}
+
+	repo, err := git.PlainClone(dir, false, opts)
+	if err != nil {
+		return Response{}, fmt.Errorf("error cloning %s: %s", source.URI, err)
+	}
+
+	var hash plumbing.Hash
+	if req.Version != nil && req.Version["ref"] != "" {
+		hash = plumbing.NewHash(req.Version["ref"])
+
+		wt, err := repo.Worktree()
+		if err != nil {
+			return Response{}, fmt.Errorf("error getting worktree: %s", err)
+		}
+		if err := wt.Checkout(&git.CheckoutOptions{Hash: hash}); err != nil {
+			return Response{}, fmt.Errorf("error checking out %s: %s", req.Version["ref"], err)
+		}
+	} else {
+		head, err := repo.Head()
+		if err != nil {
+			return Response{}, fmt.Errorf("error getting head of %s: %s", source.Branch, err)
+		}
+		hash = head.Hash()
+	}
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return Response{}, fmt.Errorf("error reading commit %s: %s", hash, err)
+	}
+
+	return Response{Version: Version{"ref": hash.String()}, Metadata: commitMetadata(commit)}, nil
+}
+
+func (r gitResource) Out(dir string, req Request) (Response, error) {
+	source, err := r.source(req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	var params gitOutParams
+	if err := decode(req.Params, &params); err != nil {
+		return Response{}, err
+	}
+
+	repo, err := git.PlainOpen(path.Join(dir, params.Repository))
+	if err != nil {
+		return Response{}, fmt.Errorf("error opening repository %s: %s", params.Repository, err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return Response{}, fmt.Errorf("error getting head of %s: %s", params.Repository, err)
+	}
+
+	// HEAD might be detached, so push it via a temporary reference
+	pushRef := plumbing.ReferenceName("refs/jindra/out")
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(pushRef, head.Hash())); err != nil {
+		return Response{}, fmt.Errorf("error creating reference for pushing: %s", err)
+	}
+
+	auth, err := source.auth()
+	if err != nil {
+		return Response{}, err
+	}
+
+	refSpec := fmt.Sprintf("%s:%s", pushRef, plumbing.NewBranchReferenceName(source.Branch))
+	if params.Force {
+		refSpec = "+" + refSpec
+	}
+
+	remote := git.NewRemote(repo.Storer, &config.RemoteConfig{Name: "jindra-out", URLs: []string{source.URI}})
+	err = remote.Push(&git.PushOptions{RemoteName: "jindra-out", Auth: auth, RefSpecs: []config.RefSpec{config.RefSpec(refSpec)}})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return Response{}, fmt.Errorf("error pushing to %s: %s", source.URI, err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return Response{}, fmt.Errorf("error reading commit %s: %s", head.Hash(), err)
+	}
+
+	return Response{Version: Version{"ref": head.Hash().String()}, Metadata: commitMetadata(commit)}, nil
+}
+
+func commitMetadata(c *object.Commit) []MetadataField {
+	return []MetadataField{
+		{Name: "commit", Value: c.Hash.String()},
+		{Name: "author", Value: c.Author.Name},
+		{Name: "author_date", Value: c.Author.When.String()},
+		{Name: "message", Value: c.Message},
+	}
+}
@@ -0,0 +1,166 @@
+package resources
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+func init() {
+	// serve file:// uris in process, so tests don't depend on a git binary
+	client.InstallProtocol("file", server.DefaultServer)
+}
+
+// gitFixture is a bare repository ("remote") and a clone to create commits in
+type gitFixture struct {
+	dir    string
+	remote string
+	work   *git.Repository
+	t      *testing.T
+}
+
+func newGitFixture(t *testing.T) gitFixture {
+	dir, err := ioutil.TempDir("", "jindra-git-resource")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %s", err)
+	}
+
+	f := gitFixture{dir: dir, remote: path.Join(dir, "remote.git"), t: t}
+	if _, err := git.PlainInit(f.remote, true); err != nil {
+		t.Fatalf("error creating bare repository: %s", err)
+	}
+
+	if f.work, err = git.PlainInit(path.Join(dir, "work"), false); err != nil {
+		t.Fatalf("error creating work repository: %s", err)
+	}
+
+	if _, err := f.work.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"file://" + f.remote}}); err != nil {
+		t.Fatalf("error creating remote: %s", err)
+	}
+
+	return f
+}
+
+// commit commits a file and pushes it to the remote
+func (f gitFixture) commit(file, content string) string {
+	if err := ioutil.WriteFile(path.Join(f.dir, "work", file), []byte(content), 0644); err != nil {
+		f.t.Fatalf("error writing %s: %s", file, err)
+	}
+
+	wt, _ := f.work.Worktree()
+	if _, err := wt.Add(file); err != nil {
+		f.t.Fatalf("error adding %s: %s", file, err)
+	}
+
+	hash, err := wt.Commit("add "+file, &git.CommitOptions{Author: &object.Signature{Name: "jindra", Email: "ci@jindra.io", When: time.Now()}})
+	if err != nil {
+		f.t.Fatalf("error committing %s: %s", file, err)
+	}
+
+	if err := f.work.Push(&git.PushOptions{RemoteName: "origin"}); err != nil {
+		f.t.Fatalf("error pushing: %s", err)
+	}
+
+	return hash.String()
+}
+
+func (f gitFixture) source() json.RawMessage {
+	return json.RawMessage(`{"uri": "file://` + f.remote + `"}`)
+}
+
+func TestGitCheck(t *testing.T) {
+	f := newGitFixture(t)
+	defer os.RemoveAll(f.dir)
+
+	first := f.commit("a", "a")
+	second := f.commit("b", "b")
+	third := f.commit("c", "c")
+
+	for i, test := range []struct {
+		version  Version
+		expected []Version
+		desc     string
+	}{
+		{nil, []Version{{"ref": third}}, "without version only the current version is returned"},
+		{Version{"ref": first}, []Version{{"ref": first}, {"ref": second}, {"ref": third}}, "all versions since the given version"},
+		{Version{"ref": third}, []Version{{"ref": third}}, "no new versions"},
+		{Version{"ref": "0000000000000000000000000000000000000000"}, []Version{{"ref": third}}, "unknown version yields current version"},
+	} {
+		versions, err := gitResource{}.Check(Request{Source: f.source(), Version: test.version})
+		if err != nil {
+			t.Fatalf("\t%2d: %-80s unexpected error: %s", i, test.desc, err)
+		}
+		if !reflect.DeepEqual(test.expected, versions) {
+			t.Fatalf("\t%2d: %-80s expected %v, got %v", i, test.desc, test.expected, versions)
+		}
+	}
+}
+
+func TestGitIn(t *testing.T) {
+	f := newGitFixture(t)
+	defer os.RemoveAll(f.dir)
+
+	first := f.commit("a", "first")
+	f.commit("a", "second")
+
+	dest := path.Join(f.dir, "in")
+	res, err := gitResource{}.In(dest, Request{Source: f.source(), Version: Version{"ref": first}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if res.Version["ref"] != first {
+		t.Errorf("expected version %s, got %s", first, res.Version["ref"])
+	}
+
+	content, err := ioutil.ReadFile(path.Join(dest, "a"))
+	if err != nil || string(content) != "first" {
+		t.Errorf("expected requested version to be checked out, got '%s' (%v)", string(content), err)
+	}
+}
+
+func TestGitOut(t *testing.T) {
+	f := newGitFixture(t)
+	defer os.RemoveAll(f.dir)
+
+	f.commit("a", "a")
+
+	// clone, commit and push back via out
+	dir := path.Join(f.dir, "out")
+	if _, err := (gitResource{}).In(path.Join(dir, "repo"), Request{Source: f.source()}); err != nil {
+		t.Fatalf("error cloning: %s", err)
+	}
+
+	repo, _ := git.PlainOpen(path.Join(dir, "repo"))
+	ioutil.WriteFile(path.Join(dir, "repo", "b"), []byte("b"), 0644)
+	wt, _ := repo.Worktree()
+	wt.Add("b")
+	hash, err := wt.Commit("add b", &git.CommitOptions{Author: &object.Signature{Name: "jindra", Email: "ci@jindra.io", When: time.Now()}})
+	if err != nil {
+		t.Fatalf("error committing: %s", err)
+	}
+
+	res, err := gitResource{}.Out(dir, Request{Source: f.source(), Params: json.RawMessage(`{"repository": "repo"}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if res.Version["ref"] != hash.String() {
+		t.Errorf("expected version %s, got %s", hash, res.Version["ref"])
+	}
+
+	versions, err := gitResource{}.Check(Request{Source: f.source()})
+	if err != nil || !reflect.DeepEqual(versions, []Version{{"ref": hash.String()}}) {
+		t.Errorf("expected pushed commit %s to be the current version, got %v (%v)", hash, versions, err)
+	}
+}
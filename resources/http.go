@@ -0,0 +1,185 @@
+package resources
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+)
+
+func init() {
+	Register("http", httpResource{client: http.DefaultClient})
+}
+
+// httpSource configures the http resource:
+//
+// url:      url of the file
+// filename: name of the file in the resource directory (default: last path
+//           element of the url)
+// headers:  additional request headers (i.e. for authorization)
+type httpSource struct {
+	URL      string            `json:"url"`
+	Filename string            `json:"filename"`
+	Headers  map[string]string `json:"headers"`
+}
+
+// httpOutParams configures the out call of the http resource:
+//
+// file: path of the file (relative to the resource directory) that is uploaded
+//       with a PUT request to the source url
+type httpOutParams struct {
+	File string `json:"file"`
+}
+
+// httpResource downloads a file via http -- versions are identified by the
+// sha256 sum of the content
+type httpResource struct {
+	client *http.Client
+}
+
+func (r httpResource) source(req Request) (httpSource, error) {
+	var source httpSource
+	if err := decode(req.Source, &source); err != nil {
+		return source, err
+	}
+
+	if source.URL == "" {
+		return source, fmt.Errorf("source.url must be set")
+	}
+
+	if source.Filename == "" {
+		u, err := url.Parse(source.URL)
+		if err != nil {
+			return source, fmt.Errorf("invalid url '%s': %s", source.URL, err)
+		}
+		source.Filename = path.Base(u.Path)
+		if source.Filename == "/" || source.Filename == "." {
+			source.Filename = "index"
+		}
+	}
+
+	return source, nil
+}
+
+func (r httpResource) do(method string, source httpSource, body io.Reader) (*http.Response, error) {
+	httpReq, err := http.NewRequest(method, source.URL, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %s", err)
+	}
+	for k, v := range source.Headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	res, err := r.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s %s: %s", method, source.URL, err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		res.Body.Close()
+		return nil, fmt.Errorf("%s %s returned %s", method, source.URL, res.Status)
+	}
+
+	return res, nil
+}
+
+// fetch downloads the file and returns its content and version
+func (r httpResource) fetch(source httpSource) ([]byte, Version, error) {
+	res, err := r.do(http.MethodGet, source, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading body of %s: %s", source.URL, err)
+	}
+
+	return content, Version{"sha256": fmt.Sprintf("%x", sha256.Sum256(content))}, nil
+}
+
+func (r httpResource) Check(req Request) ([]Version, error) {
+	source, err := r.source(req)
+	if err != nil {
+		return nil, err
+	}
+
+	_, version, err := r.fetch(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Version != nil && req.Version["sha256"] != version["sha256"] {
+		return []Version{req.Version, version}, nil
+	}
+
+	return []Version{version}, nil
+}
+
+func (r httpResource) In(dir string, req Request) (Response, error) {
+	source, err := r.source(req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	content, version, err := r.fetch(source)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if req.Version != nil && req.Version["sha256"] != version["sha256"] {
+		return Response{}, fmt.Errorf("requested version %s is not available anymore (current version: %s)", req.Version["sha256"], version["sha256"])
+	}
+
+	if err := ioutil.WriteFile(path.Join(dir, source.Filename), content, 0644); err != nil {
+		return Response{}, fmt.Errorf("error writing %s: %s", source.Filename, err)
+	}
+
+	return Response{
+		Version: version,
+		Metadata: []MetadataField{
+			{Name: "url", Value: source.URL},
+			{Name: "size", Value: fmt.Sprintf("%d", len(content))},
+		},
+	}, nil
+}
+
+func (r httpResource) Out(dir string, req Request) (Response, error) {
+	source, err := r.source(req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	var params httpOutParams
+	if err := decode(req.Params, &params); err != nil {
+		return Response{}, err
+	}
+	if params.File == "" {
+		params.File = source.Filename
+	}
+
+	content, err := ioutil.ReadFile(path.Join(dir, params.File))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Response{}, fmt.Errorf("file %s to upload does not exist", params.File)
+		}
+		return Response{}, fmt.Errorf("error reading %s: %s", params.File, err)
+	}
+
+	res, err := r.do(http.MethodPut, source, bytes.NewReader(content))
+	if err != nil {
+		return Response{}, err
+	}
+	res.Body.Close()
+
+	return Response{
+		Version:  Version{"sha256": fmt.Sprintf("%x", sha256.Sum256(content))},
+		Metadata: []MetadataField{{Name: "url", Value: source.URL}},
+	}, nil
+}
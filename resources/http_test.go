@@ -0,0 +1,100 @@
+package resources
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func newHTTPServer(content *string, uploaded *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			fmt.Fprint(w, *content)
+		case http.MethodPut:
+			body, _ := ioutil.ReadAll(r.Body)
+			*uploaded = string(body)
+		}
+	}))
+}
+
+func sha(s string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))
+}
+
+func TestHTTPCheck(t *testing.T) {
+	content := "v1"
+	srv := newHTTPServer(&content, nil)
+	defer srv.Close()
+
+	source := json.RawMessage(`{"url": "` + srv.URL + `/file.txt", "headers": {"Authorization": "Bearer secret"}}`)
+	r := httpResource{client: srv.Client()}
+
+	versions, err := r.Check(Request{Source: source})
+	if err != nil || !reflect.DeepEqual(versions, []Version{{"sha256": sha("v1")}}) {
+		t.Fatalf("unexpected check result %v (%v)", versions, err)
+	}
+
+	content = "v2"
+	versions, err = r.Check(Request{Source: source, Version: Version{"sha256": sha("v1")}})
+	if err != nil || !reflect.DeepEqual(versions, []Version{{"sha256": sha("v1")}, {"sha256": sha("v2")}}) {
+		t.Fatalf("expected new version after content changed, got %v (%v)", versions, err)
+	}
+
+	_, err = r.Check(Request{Source: json.RawMessage(`{"url": "` + srv.URL + `/file.txt"}`)})
+	if err == nil {
+		t.Fatalf("expected error if server returns non 2xx status")
+	}
+}
+
+func TestHTTPInAndOut(t *testing.T) {
+	content := "hello jindra"
+	uploaded := ""
+	srv := newHTTPServer(&content, &uploaded)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "jindra-http-resource")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := json.RawMessage(`{"url": "` + srv.URL + `/files/file.txt", "headers": {"Authorization": "Bearer secret"}}`)
+	r := httpResource{client: srv.Client()}
+
+	res, err := r.In(dir, Request{Source: source, Version: Version{"sha256": sha(content)}})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.Version["sha256"] != sha(content) {
+		t.Errorf("unexpected version %v", res.Version)
+	}
+	if got, _ := ioutil.ReadFile(path.Join(dir, "file.txt")); string(got) != content {
+		t.Errorf("expected downloaded file to contain '%s', got '%s'", content, string(got))
+	}
+
+	if _, err := r.In(dir, Request{Source: source, Version: Version{"sha256": sha("outdated")}}); err == nil {
+		t.Errorf("expected error when requesting a version that is not available anymore")
+	}
+
+	ioutil.WriteFile(path.Join(dir, "upload.txt"), []byte("uploaded content"), 0644)
+	res, err = r.Out(dir, Request{Source: source, Params: json.RawMessage(`{"file": "upload.txt"}`)})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if uploaded != "uploaded content" || res.Version["sha256"] != sha("uploaded content") {
+		t.Errorf("unexpected upload result: '%s' / %v", uploaded, res.Version)
+	}
+}
@@ -0,0 +1,131 @@
+// Package resources implements jindra's native resources. They speak the same
+// check/in/out json contract as concourse resources
+// (https://concourse-ci.org/implementing-resource-types.html), so crij can
+// invoke them exactly like the scripts in /opt/resource of a concourse
+// resource image -- but they don't need an extra image.
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Version identifies a version of a resource
+type Version map[string]string
+
+// MetadataField is an arbitrary key value pair describing a version
+type MetadataField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Request is the json payload a resource gets via stdin
+type Request struct {
+	Source  json.RawMessage `json:"source"`
+	Version Version         `json:"version,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is the json payload an in or out call writes to stdout
+type Response struct {
+	Version  Version         `json:"version"`
+	Metadata []MetadataField `json:"metadata,omitempty"`
+}
+
+// Resource is a native jindra resource
+type Resource interface {
+	// Check returns all versions since req.Version (in chronological order,
+	// including req.Version if it still exists) -- if no version is given, only
+	// the current version is returned
+	Check(req Request) ([]Version, error)
+	// In fetches req.Version into dir
+	In(dir string, req Request) (Response, error)
+	// Out pushes the content of dir and returns the newly created version
+	Out(dir string, req Request) (Response, error)
+}
+
+var registry = map[string]Resource{}
+
+// Register makes a resource available under name
+func Register(name string, r Resource) {
+	registry[name] = r
+}
+
+// Names returns the names of all registered resources
+func Names() []string {
+	names := []string{}
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Exists returns true, if there is a resource with the given name
+func Exists(name string) bool {
+	_, ok := registry[name]
+	return ok
+}
+
+// Registry is the registry of all resources -- it implements the native
+// resource registry of the validator
+type Registry struct{}
+
+// Exists returns true, if there is a resource with the given name
+func (Registry) Exists(name string) bool {
+	return Exists(name)
+}
+
+// Names returns the names of all registered resources
+func (Registry) Names() []string {
+	return Names()
+}
+
+// Run reads the request from stdin, calls action (check, in or out) of
+// resource name and writes the response to stdout -- dir is ignored for check
+func Run(name, action, dir string, stdin io.Reader, stdout io.Writer) error {
+	r, ok := registry[name]
+	if !ok {
+		return fmt.Errorf("unknown resource '%s' (available: %v)", name, Names())
+	}
+
+	var req Request
+	if err := json.NewDecoder(stdin).Decode(&req); err != nil {
+		return fmt.Errorf("error decoding request: %s", err)
+	}
+
+	var res interface{}
+	var err error
+	switch action {
+	case "check":
+		res, err = r.Check(req)
+	case "in":
+		res, err = r.In(dir, req)
+	case "out":
+		res, err = r.Out(dir, req)
+	default:
+		return fmt.Errorf("unknown action '%s' (must be one of check, in, out)", action)
+	}
+
+	if err != nil {
+		return fmt.Errorf("error calling %s of resource %s: %s", action, name, err)
+	}
+
+	return json.NewEncoder(stdout).Encode(res)
+}
+
+// decode unmarshals raw into v and tolerates empty input
+func decode(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("error decoding %s: %s", string(raw), err)
+	}
+
+	return nil
+}
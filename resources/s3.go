@@ -0,0 +1,242 @@
+package resources
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	minio "github.com/minio/minio-go/v6"
+)
+
+func init() {
+	Register("s3", s3Resource{})
+}
+
+// s3Source configures the s3 resource:
+//
+// endpoint:          url of the s3 compatible object storage (e.g.
+//                    https://s3.amazonaws.com or http://minio:9000)
+// bucket:            bucket that holds the objects
+// prefix:            the resource directory is mirrored to all objects
+//                    below this prefix
+// region:            region of the bucket (default: us-east-1)
+// access_key_id:     access key
+// secret_access_key: secret key
+type s3Source struct {
+	Endpoint        string `json:"endpoint"`
+	Bucket          string `json:"bucket"`
+	Prefix          string `json:"prefix"`
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+}
+
+// s3ModeMetadata is the user metadata key that preserves the file mode
+const s3ModeMetadata = "Mode"
+
+// s3Resource mirrors a directory to objects of a s3 compatible object
+// storage -- versions are identified by the digest of all object names and
+// etags
+type s3Resource struct {
+	transport http.RoundTripper
+}
+
+func (r s3Resource) client(req Request) (*minio.Client, s3Source, error) {
+	var source s3Source
+	if err := decode(req.Source, &source); err != nil {
+		return nil, source, err
+	}
+
+	if source.Endpoint == "" || source.Bucket == "" {
+		return nil, source, fmt.Errorf("source.endpoint and source.bucket must be set")
+	}
+
+	if source.Region == "" {
+		source.Region = "us-east-1"
+	}
+
+	if source.Prefix != "" {
+		source.Prefix = strings.TrimSuffix(source.Prefix, "/") + "/"
+	}
+
+	u, err := url.Parse(source.Endpoint)
+	if err != nil || u.Host == "" {
+		return nil, source, fmt.Errorf("invalid endpoint '%s' (must be an url like https://s3.amazonaws.com)", source.Endpoint)
+	}
+
+	client, err := minio.NewWithRegion(u.Host, source.AccessKeyID, source.SecretAccessKey, u.Scheme == "https", source.Region)
+	if err != nil {
+		return nil, source, fmt.Errorf("error creating s3 client: %s", err)
+	}
+
+	if r.transport != nil {
+		client.SetCustomTransport(r.transport)
+	}
+
+	return client, source, nil
+}
+
+// list returns all objects below the prefix and the corresponding version
+func (r s3Resource) list(client *minio.Client, source s3Source) ([]minio.ObjectInfo, Version, error) {
+	done := make(chan struct{})
+	defer close(done)
+
+	objects := []minio.ObjectInfo{}
+	for object := range client.ListObjectsV2(source.Bucket, source.Prefix, true, done) {
+		if object.Err != nil {
+			return nil, nil, fmt.Errorf("error listing objects of %s/%s: %s", source.Bucket, source.Prefix, object.Err)
+		}
+		objects = append(objects, object)
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+
+	digest := sha256.New()
+	for _, object := range objects {
+		fmt.Fprintf(digest, "%s %s\n", object.Key, object.ETag)
+	}
+
+	return objects, Version{"digest": fmt.Sprintf("%x", digest.Sum(nil))}, nil
+}
+
+func (r s3Resource) Check(req Request) ([]Version, error) {
+	client, source, err := r.client(req)
+	if err != nil {
+		return nil, err
+	}
+
+	_, version, err := r.list(client, source)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Version != nil && req.Version["digest"] != version["digest"] {
+		return []Version{req.Version, version}, nil
+	}
+
+	return []Version{version}, nil
+}
+
+func (r s3Resource) In(dir string, req Request) (Response, error) {
+	client, source, err := r.client(req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	objects, version, err := r.list(client, source)
+	if err != nil {
+		return Response{}, err
+	}
+
+	if req.Version != nil && req.Version["digest"] != version["digest"] {
+		return Response{}, fmt.Errorf("requested version %s is not available anymore (current version: %s)", req.Version["digest"], version["digest"])
+	}
+
+	for _, object := range objects {
+		if err := r.download(client, source.Bucket, object.Key, path.Join(dir, strings.TrimPrefix(object.Key, source.Prefix))); err != nil {
+			return Response{}, err
+		}
+	}
+
+	return Response{
+		Version:  version,
+		Metadata: []MetadataField{{Name: "objects", Value: fmt.Sprintf("%d", len(objects))}},
+	}, nil
+}
+
+func (r s3Resource) download(client *minio.Client, bucket, key, file string) error {
+	object, err := client.GetObject(bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("error getting %s: %s", key, err)
+	}
+	defer object.Close()
+
+	info, err := object.Stat()
+	if err != nil {
+		return fmt.Errorf("error getting %s: %s", key, err)
+	}
+
+	mode := os.FileMode(0644)
+	if m, err := strconv.ParseUint(info.Metadata.Get("X-Amz-Meta-"+s3ModeMetadata), 8, 32); err == nil {
+		mode = os.FileMode(m)
+	}
+
+	if err := os.MkdirAll(path.Dir(file), 0755); err != nil {
+		return fmt.Errorf("error creating directory for %s: %s", file, err)
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %s", file, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, object); err != nil {
+		return fmt.Errorf("error downloading %s: %s", key, err)
+	}
+
+	return nil
+}
+
+// Out uploads all files of dir and deletes all objects below the prefix that
+// don't exist in dir anymore
+func (r s3Resource) Out(dir string, req Request) (Response, error) {
+	client, source, err := r.client(req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	uploaded := map[string]bool{}
+	err = filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+
+		key := source.Prefix + filepath.ToSlash(rel)
+		opts := minio.PutObjectOptions{UserMetadata: map[string]string{s3ModeMetadata: fmt.Sprintf("%o", info.Mode().Perm())}}
+		if _, err := client.FPutObject(source.Bucket, key, file, opts); err != nil {
+			return fmt.Errorf("error uploading %s: %s", rel, err)
+		}
+		uploaded[key] = true
+
+		return nil
+	})
+	if err != nil {
+		return Response{}, err
+	}
+
+	objects, _, err := r.list(client, source)
+	if err != nil {
+		return Response{}, err
+	}
+
+	for _, object := range objects {
+		if !uploaded[object.Key] {
+			if err := client.RemoveObject(source.Bucket, object.Key); err != nil {
+				return Response{}, fmt.Errorf("error deleting %s: %s", object.Key, err)
+			}
+		}
+	}
+
+	_, version, err := r.list(client, source)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		Version:  version,
+		Metadata: []MetadataField{{Name: "objects", Value: fmt.Sprintf("%d", len(uploaded))}},
+	}, nil
+}
@@ -0,0 +1,209 @@
+package resources
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type fakeS3Object struct {
+	content []byte
+	mode    string
+}
+
+// fakeS3 is a minimal in-memory stand-in for minio: it supports path style
+// list (v2), get, put and delete requests and ignores signatures
+type fakeS3 struct {
+	sync.Mutex
+	objects map[string]fakeS3Object
+}
+
+type fakeS3ListResult struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Name        string
+	Prefix      string
+	KeyCount    int
+	MaxKeys     int
+	IsTruncated bool
+	Contents    []fakeS3ListEntry
+}
+
+type fakeS3ListEntry struct {
+	Key          string
+	ETag         string
+	Size         int
+	LastModified string
+	StorageClass string
+}
+
+func etag(content []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(content))
+}
+
+// readAWSChunked decodes a body sent with a streaming v4 signature
+func readAWSChunked(r io.Reader) ([]byte, error) {
+	content := []byte{}
+	br := bufio.NewReader(r)
+	for {
+		header, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.ParseInt(strings.Split(strings.TrimSpace(header), ";")[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return content, nil
+		}
+		content = append(content, chunk[:size]...)
+	}
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	tokens := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucket, key := tokens[0], ""
+	if len(tokens) == 2 {
+		key = tokens[1]
+	}
+
+	object, ok := s.objects[bucket+"/"+key]
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		result := fakeS3ListResult{Name: bucket, Prefix: r.URL.Query().Get("prefix"), MaxKeys: 1000}
+		for name, object := range s.objects {
+			if strings.HasPrefix(name, bucket+"/"+result.Prefix) {
+				result.Contents = append(result.Contents, fakeS3ListEntry{
+					Key:          strings.TrimPrefix(name, bucket+"/"),
+					ETag:         etag(object.content),
+					Size:         len(object.content),
+					LastModified: "2020-02-20T12:00:00.000Z",
+					StorageClass: "STANDARD",
+				})
+			}
+		}
+		sort.Slice(result.Contents, func(i, j int) bool { return result.Contents[i].Key < result.Contents[j].Key })
+		result.KeyCount = len(result.Contents)
+		xml.NewEncoder(w).Encode(result)
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && !ok:
+		http.Error(w, "NoSuchKey", http.StatusNotFound)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		w.Header().Set("ETag", etag(object.content))
+		w.Header().Set("Last-Modified", "Thu, 20 Feb 2020 12:00:00 GMT")
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(object.content)))
+		w.Header().Set("X-Amz-Meta-Mode", object.mode)
+		if r.Method == http.MethodGet {
+			w.Write(object.content)
+		}
+	case r.Method == http.MethodPut:
+		var content []byte
+		var err error
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			content, err = readAWSChunked(r.Body)
+		} else {
+			content, err = ioutil.ReadAll(r.Body)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.objects[bucket+"/"+key] = fakeS3Object{content: content, mode: r.Header.Get("X-Amz-Meta-Mode")}
+		w.Header().Set("ETag", etag(content))
+	case r.Method == http.MethodDelete:
+		delete(s.objects, bucket+"/"+key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "NotImplemented", http.StatusNotImplemented)
+	}
+}
+
+func (s *fakeS3) keys() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	keys := []string{}
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func TestS3OutAndIn(t *testing.T) {
+	s3 := &fakeS3{objects: map[string]fakeS3Object{"jindra/other/file": {content: []byte("other")}}}
+	srv := httptest.NewServer(s3)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "jindra-s3-resource")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := json.RawMessage(`{"endpoint": "` + srv.URL + `", "bucket": "jindra", "prefix": "http-fs/42", "access_key_id": "key", "secret_access_key": "secret"}`)
+	r := s3Resource{}
+
+	out := path.Join(dir, "out")
+	os.MkdirAll(path.Join(out, "bin"), 0755)
+	ioutil.WriteFile(path.Join(out, "bin", "http-fs"), []byte("binary"), 0755)
+	ioutil.WriteFile(path.Join(out, "Dockerfile"), []byte("FROM scratch"), 0644)
+	s3.objects["jindra/http-fs/42/stale"] = fakeS3Object{content: []byte("stale")}
+
+	res, err := r.Out(out, Request{Source: source})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if expected := []string{"jindra/http-fs/42/Dockerfile", "jindra/http-fs/42/bin/http-fs", "jindra/other/file"}; !reflect.DeepEqual(expected, s3.keys()) {
+		t.Fatalf("expected objects %v, got %v", expected, s3.keys())
+	}
+
+	versions, err := r.Check(Request{Source: source})
+	if err != nil || !reflect.DeepEqual(versions, []Version{res.Version}) {
+		t.Fatalf("expected check to return version %v of out, got %v (%v)", res.Version, versions, err)
+	}
+
+	in := path.Join(dir, "in")
+	if _, err := r.In(in, Request{Source: source, Version: res.Version}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for file, expected := range map[string]string{"Dockerfile": "FROM scratch", "bin/http-fs": "binary"} {
+		if got, _ := ioutil.ReadFile(path.Join(in, file)); string(got) != expected {
+			t.Errorf("expected %s to contain '%s', got '%s'", file, expected, string(got))
+		}
+	}
+
+	if info, err := os.Stat(path.Join(in, "bin", "http-fs")); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("expected file mode to be preserved, got %v (%v)", info.Mode(), err)
+	}
+
+	if _, err := r.In(in, Request{Source: source, Version: Version{"digest": "outdated"}}); err == nil {
+		t.Errorf("expected error when requesting a version that is not available anymore")
+	}
+}
@@ -0,0 +1,74 @@
+package resources
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"time"
+)
+
+func init() {
+	Register("time", timeResource{now: time.Now})
+}
+
+// timeSource configures the time resource:
+//
+// interval: minimum duration between two versions (i.e. "1h"); if not set,
+//           every check yields a new version
+type timeSource struct {
+	Interval string `json:"interval"`
+}
+
+// timeResource emits a new version once the configured interval passed
+type timeResource struct {
+	now func() time.Time
+}
+
+func (r timeResource) Check(req Request) ([]Version, error) {
+	var source timeSource
+	if err := decode(req.Source, &source); err != nil {
+		return nil, err
+	}
+
+	now := r.now().UTC()
+	current := Version{"time": now.Format(time.RFC3339)}
+
+	if req.Version == nil || source.Interval == "" {
+		return []Version{current}, nil
+	}
+
+	interval, err := time.ParseDuration(source.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid interval '%s': %s", source.Interval, err)
+	}
+
+	last, err := time.Parse(time.RFC3339, req.Version["time"])
+	if err != nil {
+		return []Version{current}, nil
+	}
+
+	if now.Sub(last) < interval {
+		return []Version{req.Version}, nil
+	}
+
+	return []Version{req.Version, current}, nil
+}
+
+// In writes the time of the version to the file 'timestamp'
+func (r timeResource) In(dir string, req Request) (Response, error) {
+	version := req.Version
+	if version == nil {
+		version = Version{"time": r.now().UTC().Format(time.RFC3339)}
+	}
+
+	if err := ioutil.WriteFile(path.Join(dir, "timestamp"), []byte(version["time"]+"\n"), 0644); err != nil {
+		return Response{}, fmt.Errorf("error writing timestamp: %s", err)
+	}
+
+	return Response{Version: version}, nil
+}
+
+// Out creates a version for the current time
+func (r timeResource) Out(dir string, req Request) (Response, error) {
+	return Response{Version: Version{"time": r.now().UTC().Format(time.RFC3339)}}, nil
+}
@@ -0,0 +1,57 @@
+package resources
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeCheck(t *testing.T) {
+	now := time.Date(2020, 2, 20, 12, 0, 0, 0, time.UTC)
+	r := timeResource{now: func() time.Time { return now }}
+	source := json.RawMessage(`{"interval": "1h"}`)
+
+	for i, test := range []struct {
+		version  Version
+		expected []Version
+		desc     string
+	}{
+		{nil, []Version{{"time": "2020-02-20T12:00:00Z"}}, "first check yields current time"},
+		{Version{"time": "2020-02-20T11:30:00Z"}, []Version{{"time": "2020-02-20T11:30:00Z"}}, "no new version within interval"},
+		{Version{"time": "2020-02-20T10:30:00Z"}, []Version{{"time": "2020-02-20T10:30:00Z"}, {"time": "2020-02-20T12:00:00Z"}}, "new version after interval"},
+	} {
+		versions, err := r.Check(Request{Source: source, Version: test.version})
+		if err != nil {
+			t.Fatalf("\t%2d: %-80s unexpected error: %s", i, test.desc, err)
+		}
+		if !reflect.DeepEqual(test.expected, versions) {
+			t.Fatalf("\t%2d: %-80s expected %v, got %v", i, test.desc, test.expected, versions)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	now := time.Date(2020, 2, 20, 12, 0, 0, 0, time.UTC)
+	Register("fake-time", timeResource{now: func() time.Time { return now }})
+	defer delete(registry, "fake-time")
+
+	var stdout bytes.Buffer
+	if err := Run("fake-time", "out", "/tmp", strings.NewReader(`{"source": {}}`), &stdout); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if expected := `{"version":{"time":"2020-02-20T12:00:00Z"}}` + "\n"; stdout.String() != expected {
+		t.Errorf("expected %s, got %s", expected, stdout.String())
+	}
+
+	if err := Run("xxx", "in", "/tmp", strings.NewReader(`{}`), &stdout); err == nil {
+		t.Errorf("expected error for unknown resource")
+	}
+
+	if err := Run("fake-time", "xxx", "/tmp", strings.NewReader(`{}`), &stdout); err == nil {
+		t.Errorf("expected error for unknown action")
+	}
+}
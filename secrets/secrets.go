@@ -0,0 +1,44 @@
+// Package secrets reads secrets from external secret stores at run time, so
+// their values never end up in config maps or pod specs. Env vars reference
+// secrets with '((<provider>:<path>#<key>))', e.g.
+// '((vault:secret/ci/dockerhub#password))'.
+package secrets
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Provider returns the values of secrets of a secret store
+type Provider interface {
+	// Get returns the value of key of the secret at path
+	Get(path, key string) (string, error)
+}
+
+var referenceRegexp = regexp.MustCompile(`^\(\(([a-z]+):([^#()]+)#([^#()]+)\)\)$`)
+
+// Reference returns the reference to key of the secret at path of provider
+func Reference(provider, path, key string) string {
+	return fmt.Sprintf("((%s:%s#%s))", provider, path, key)
+}
+
+// Resolve returns the value of the secret value references -- values that
+// are no references are returned as is
+func Resolve(providers map[string]Provider, value string) (string, error) {
+	match := referenceRegexp.FindStringSubmatch(value)
+	if match == nil {
+		return value, nil
+	}
+
+	provider, ok := providers[match[1]]
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider '%s'", match[1])
+	}
+
+	secret, err := provider.Get(match[2], match[3])
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %s", value, err)
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,121 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Vault reads secrets from the KV secrets engine of HashiCorp Vault
+type Vault struct {
+	address   string
+	token     string
+	kvVersion int
+	client    *http.Client
+}
+
+// NewVault returns a provider for the vault server at address -- kvVersion
+// is the version of the KV secrets engine (1 or 2), token may be empty if
+// Login is called before secrets are read
+func NewVault(address string, kvVersion int, token string) *Vault {
+	return &Vault{
+		address:   strings.TrimSuffix(address, "/"),
+		token:     token,
+		kvVersion: kvVersion,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Login logs in with the kubernetes auth method mounted at authPath using the
+// service account token jwt
+func (v *Vault) Login(authPath, role, jwt string) error {
+	body, _ := json.Marshal(map[string]string{"role": role, "jwt": jwt})
+	resp := struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}{}
+	if err := v.request("POST", "auth/"+strings.Trim(authPath, "/")+"/login", bytes.NewReader(body), &resp); err != nil {
+		return fmt.Errorf("error logging in to vault: %s", err)
+	}
+
+	if resp.Auth.ClientToken == "" {
+		return fmt.Errorf("error logging in to vault: no token returned")
+	}
+	v.token = resp.Auth.ClientToken
+
+	return nil
+}
+
+// Get returns the value of key of the secret at path -- the first segment of
+// path is the mount path of the KV secrets engine (e.g. 'secret/ci/dockerhub')
+func (v *Vault) Get(path, key string) (string, error) {
+	path = strings.Trim(path, "/")
+	if v.kvVersion == 2 {
+		tokens := strings.SplitN(path, "/", 2)
+		if len(tokens) != 2 {
+			return "", fmt.Errorf("path '%s' must start with the mount path of the secrets engine", path)
+		}
+		path = tokens[0] + "/data/" + tokens[1]
+	}
+
+	resp := struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+	if err := v.request("GET", path, nil, &resp); err != nil {
+		return "", err
+	}
+
+	data := resp.Data
+	if v.kvVersion == 2 {
+		data, _ = resp.Data["data"].(map[string]interface{})
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key '%s'", path, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+func (v *Vault) request(method, path string, body io.Reader, result interface{}) error {
+	req, err := http.NewRequest(method, v.address+"/v1/"+path, body)
+	if err != nil {
+		return err
+	}
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%s does not exist", path)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		errResp := struct {
+			Errors []string `json:"errors"`
+		}{}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		return fmt.Errorf("%s %s: %s %s", method, path, resp.Status, strings.Join(errResp.Errors, ", "))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("error decoding response of %s: %s", path, err)
+	}
+
+	return nil
+}
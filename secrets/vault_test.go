@@ -0,0 +1,106 @@
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeVault is a minimal stand-in for a vault dev server: it supports the
+// kubernetes auth method and reading secrets of a KV v1 engine mounted at
+// 'kv' and a KV v2 engine mounted at 'secret'
+func fakeVault(t *testing.T) *httptest.Server {
+	secrets := map[string]map[string]interface{}{
+		"/v1/kv/ci/dockerhub":          {"password": "v1-password"},
+		"/v1/secret/data/ci/dockerhub": {"data": map[string]interface{}{"password": "v2-password", "port": 5000}},
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/v1/auth/kubernetes/login" {
+			login := map[string]string{}
+			json.NewDecoder(r.Body).Decode(&login)
+			if login["role"] != "jindra" || login["jwt"] != "sa-token" {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			w.Write([]byte(`{"auth":{"client_token":"s.token"}}`))
+			return
+		}
+
+		if r.Header.Get("X-Vault-Token") != "s.token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		data, ok := secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[]}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+}
+
+func TestVault(t *testing.T) {
+	server := fakeVault(t)
+	defer server.Close()
+
+	if err := NewVault(server.URL, 2, "").Login("kubernetes", "jindra", "wrong-token"); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("expected login with wrong token to fail, got %v", err)
+	}
+
+	v2 := NewVault(server.URL, 2, "")
+	if err := v2.Login("/kubernetes/", "jindra", "sa-token"); err != nil {
+		t.Fatalf("unexpected error logging in: %s", err)
+	}
+	v1 := NewVault(server.URL+"/", 1, "s.token")
+
+	for i, test := range []struct {
+		vault    *Vault
+		path     string
+		key      string
+		expected string
+		err      string
+	}{
+		{v2, "secret/ci/dockerhub", "password", "v2-password", ""},
+		{v2, "secret/ci/dockerhub", "port", "5000", ""},
+		{v1, "kv/ci/dockerhub", "password", "v1-password", ""},
+		{v2, "secret/ci/dockerhub", "username", "", "secret secret/data/ci/dockerhub has no key 'username'"},
+		{v2, "secret/ci/slack", "url", "", "secret/data/ci/slack does not exist"},
+		{v2, "dockerhub", "password", "", "path 'dockerhub' must start with the mount path of the secrets engine"},
+		{NewVault(server.URL, 1, ""), "kv/ci/dockerhub", "password", "", "GET kv/ci/dockerhub: 403 Forbidden permission denied"},
+	} {
+		value, err := test.vault.Get(test.path, test.key)
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) || value != test.expected {
+			t.Errorf("%d: expected '%s' (error: '%s'), got '%s' (error: %v)", i, test.expected, test.err, value, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	server := fakeVault(t)
+	defer server.Close()
+	providers := map[string]Provider{"vault": NewVault(server.URL, 2, "s.token")}
+
+	for i, test := range []struct {
+		value    string
+		expected string
+		err      string
+	}{
+		{Reference("vault", "secret/ci/dockerhub", "password"), "v2-password", ""},
+		{"plain value", "plain value", ""},
+		{"prefix ((vault:secret/ci/dockerhub#password))", "prefix ((vault:secret/ci/dockerhub#password))", ""},
+		{"((aws:ci/dockerhub#password))", "", "unknown secret provider 'aws'"},
+		{"((vault:secret/ci/slack#url))", "", "error reading ((vault:secret/ci/slack#url)): secret/data/ci/slack does not exist"},
+	} {
+		value, err := Resolve(providers, test.value)
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) || value != test.expected {
+			t.Errorf("%d: expected '%s' (error: '%s'), got '%s' (error: %v)", i, test.expected, test.err, value, err)
+		}
+	}
+}
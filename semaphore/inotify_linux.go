@@ -0,0 +1,64 @@
+package semaphore
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF
+
+type inotifyWatcher struct {
+	f      *os.File
+	events chan struct{}
+}
+
+func newWatcher(dirs []string) (watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing inotify: %s", err)
+	}
+
+	for _, dir := range dirs {
+		if _, err := unix.InotifyAddWatch(fd, dir, inotifyMask); err != nil {
+			unix.Close(fd)
+			return nil, fmt.Errorf("error watching %s: %s", dir, err)
+		}
+	}
+
+	// as the fd is non-blocking, reads go through the runtime poller and
+	// closing the file unblocks a pending read
+	w := &inotifyWatcher{
+		f:      os.NewFile(uintptr(fd), "inotify"),
+		events: make(chan struct{}, 1),
+	}
+	go w.read()
+
+	return w, nil
+}
+
+func (w *inotifyWatcher) read() {
+	defer close(w.events)
+
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.PathMax))
+	for {
+		if _, err := w.f.Read(buf); err != nil {
+			return
+		}
+
+		// we re-check all semaphores anyways, so the event details do not matter
+		select {
+		case w.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *inotifyWatcher) Events() <-chan struct{} {
+	return w.events
+}
+
+func (w *inotifyWatcher) Close() error {
+	return w.f.Close()
+}
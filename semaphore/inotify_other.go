@@ -0,0 +1,10 @@
+// +build !linux
+
+package semaphore
+
+import "errors"
+
+// newWatcher is only implemented on linux -- other platforms use polling
+func newWatcher(dirs []string) (watcher, error) {
+	return nil, errors.New("inotify is not available on this platform")
+}
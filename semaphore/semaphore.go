@@ -0,0 +1,155 @@
+// Package semaphore implements waiting for jindra's semaphore files. Jindra
+// uses plain files (usually under /var/lock/jindra) to signal state between
+// containers of a pod: a container starts its work once a file was created
+// or deleted by another container.
+//
+// Waiting is event driven (inotify) where available and falls back to
+// polling the file system otherwise.
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Event is the file system event that is waited for
+type Event int
+
+const (
+	// Created waits until the semaphore file exists
+	Created Event = iota
+	// Deleted waits until the semaphore file does not exist anymore
+	Deleted
+)
+
+func (e Event) String() string {
+	if e == Created {
+		return "creation"
+	}
+
+	return "deletion"
+}
+
+// PollInterval is the interval in which files are checked if inotify is not
+// available
+var PollInterval = 1 * time.Second
+
+// ErrTimeout is returned if the semaphores did not reach the desired state
+// within the given timeout
+var ErrTimeout = errors.New("timeout waiting for semaphores")
+
+// watcher notifies about changes in the directories that contain the
+// semaphore files
+type watcher interface {
+	// Events returns a channel that receives a value whenever something in
+	// one of the watched directories changed. The channel is closed if the
+	// watcher stops working.
+	Events() <-chan struct{}
+	Close() error
+}
+
+// WaitForCreation blocks until all files exist. A timeout of 0 means no timeout.
+func WaitForCreation(ctx context.Context, timeout time.Duration, files ...string) error {
+	return Wait(ctx, Created, timeout, files...)
+}
+
+// WaitForDeletion blocks until none of the files exists anymore. A timeout of
+// 0 means no timeout.
+func WaitForDeletion(ctx context.Context, timeout time.Duration, files ...string) error {
+	return Wait(ctx, Deleted, timeout, files...)
+}
+
+// Wait blocks until event happened for all files, the timeout expired or the
+// context was cancelled. A timeout of 0 means no timeout.
+func Wait(ctx context.Context, event Event, timeout time.Duration, files ...string) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	pending := map[string]bool{}
+	dirs := map[string]bool{}
+	for _, f := range files {
+		pending[f] = true
+		dirs[filepath.Dir(f)] = true
+	}
+
+	if done, err := check(pending, event); done || err != nil {
+		return err
+	}
+
+	var events <-chan struct{}
+	var poll <-chan time.Time
+
+	w, err := newWatcher(keys(dirs))
+	if err == nil {
+		defer w.Close()
+		events = w.Events()
+
+		// the semaphores might have changed before the watches were set up
+		if done, err := check(pending, event); done || err != nil {
+			return err
+		}
+	} else {
+		ticker := time.NewTicker(PollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("%w: %v still pending %s", ErrTimeout, keys(pending), event)
+			}
+			return ctx.Err()
+		case _, ok := <-events:
+			if !ok {
+				// watcher failed: continue by polling
+				events = nil
+				ticker := time.NewTicker(PollInterval)
+				defer ticker.Stop()
+				poll = ticker.C
+			}
+		case <-poll:
+		}
+
+		if done, err := check(pending, event); done || err != nil {
+			return err
+		}
+	}
+}
+
+// check removes all files that reached the desired state from pending and
+// returns true if no files are pending anymore
+func check(pending map[string]bool, event Event) (bool, error) {
+	for f := range pending {
+		_, err := os.Stat(f)
+		switch {
+		case err == nil && event == Created:
+			delete(pending, f)
+		case os.IsNotExist(err) && event == Deleted:
+			delete(pending, f)
+		case err != nil && !os.IsNotExist(err):
+			return false, fmt.Errorf("error stating %s: %s", f, err)
+		}
+	}
+
+	return len(pending) == 0, nil
+}
+
+func keys(set map[string]bool) []string {
+	ks := []string{}
+	for k := range set {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+
+	return ks
+}
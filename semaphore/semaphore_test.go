@@ -0,0 +1,127 @@
+package semaphore
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "jindra-semaphore")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %s", err)
+	}
+
+	return dir
+}
+
+func touch(t *testing.T, file string) {
+	if err := ioutil.WriteFile(file, []byte{}, 0644); err != nil {
+		t.Fatalf("error creating %s: %s", file, err)
+	}
+}
+
+func after(d time.Duration, f func()) {
+	go func() {
+		time.Sleep(d)
+		f()
+	}()
+}
+
+func TestWaitForDeletion(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	files := []string{path.Join(dir, "steps-running"), path.Join(dir, "outputs-running")}
+	for _, f := range files {
+		touch(t, f)
+	}
+
+	after(50*time.Millisecond, func() { os.Remove(files[0]) })
+	after(100*time.Millisecond, func() { os.Remove(files[1]) })
+
+	start := time.Now()
+	if err := WaitForDeletion(context.Background(), 5*time.Second, files...); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// inotify should notice the deletion way before the poll interval is over
+	if d := time.Since(start); d > PollInterval {
+		t.Errorf("waiting took %s, expected it to be event driven", d)
+	}
+}
+
+func TestWaitForCreation(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	files := []string{path.Join(dir, "a"), path.Join(dir, "b")}
+	after(50*time.Millisecond, func() { ioutil.WriteFile(files[0], nil, 0644) })
+	after(100*time.Millisecond, func() { ioutil.WriteFile(files[1], nil, 0644) })
+
+	if err := WaitForCreation(context.Background(), 5*time.Second, files...); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestAlreadyInDesiredState(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	if err := WaitForDeletion(context.Background(), time.Millisecond, path.Join(dir, "does-not-exist")); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "steps-running")
+	touch(t, file)
+
+	err := WaitForDeletion(context.Background(), 50*time.Millisecond, file)
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected timeout error, got: %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "steps-running")
+	touch(t, file)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	after(50*time.Millisecond, cancel)
+
+	if err := WaitForDeletion(ctx, 0, file); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got: %v", err)
+	}
+}
+
+func TestPollingFallback(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	oldInterval := PollInterval
+	PollInterval = 10 * time.Millisecond
+	defer func() { PollInterval = oldInterval }()
+
+	// the directory does not exist yet, so it can't be watched
+	subDir := path.Join(dir, "sub")
+	file := path.Join(subDir, "semaphore")
+	after(50*time.Millisecond, func() {
+		os.Mkdir(subDir, 0755)
+		ioutil.WriteFile(file, nil, 0644)
+	})
+
+	if err := WaitForCreation(context.Background(), 5*time.Second, file); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
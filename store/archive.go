@@ -0,0 +1,160 @@
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Archive writes paths (relative to dir) as gzipped tar archive to w -- if
+// no paths are given, the whole content of dir is archived
+func Archive(dir string, w io.Writer, paths ...string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	add := func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	}
+
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	for _, p := range paths {
+		if err := filepath.Walk(filepath.Join(dir, p), add); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// PutArchive archives paths (relative to dir, see Archive) and stores the
+// archive as entry key
+func PutArchive(s Store, key, dir string, paths ...string) error {
+	f, err := ioutil.TempFile("", "jindra-archive")
+	if err != nil {
+		return fmt.Errorf("error creating archive: %s", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := Archive(dir, f, paths...); err != nil {
+		return fmt.Errorf("error archiving %s: %s", dir, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("error archiving %s: %s", dir, err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error archiving %s: %s", dir, err)
+	}
+
+	if err := s.Put(key, f, info.Size()); err != nil {
+		return fmt.Errorf("error storing %s: %s", key, err)
+	}
+
+	return nil
+}
+
+// Extract unpacks the gzipped tar archive r into dir
+func Extract(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		file := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if file != dir && !strings.HasPrefix(file, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return fmt.Errorf("illegal path %s in archive", header.Name)
+		}
+
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(file, mode|0700); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				return err
+			}
+			os.Remove(file)
+			if err := os.Symlink(header.Linkname, file); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tmpPrefix marks entries that are currently written
+const tmpPrefix = ".jindra-tmp-"
+
+// dirStore keeps entries as files below a directory, e.g. a persistent
+// volume claim mounted into the stage
+type dirStore struct {
+	root string
+}
+
+// NewDirStore returns a store that keeps entries below root
+func NewDirStore(root string) Store {
+	return dirStore{root: root}
+}
+
+func (s dirStore) file(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+// Get opens the entry and marks it as used
+func (s dirStore) Get(key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.file(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %s", key, err)
+	}
+
+	now := time.Now()
+	os.Chtimes(s.file(key), now, now)
+
+	return f, nil
+}
+
+// Put writes the entry to a temporary file first, so concurrent readers
+// never see partial entries
+func (s dirStore) Put(key string, r io.Reader, size int64) error {
+	if err := os.MkdirAll(filepath.Dir(s.file(key)), 0755); err != nil {
+		return fmt.Errorf("error creating directory for %s: %s", key, err)
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.file(key)), tmpPrefix)
+	if err != nil {
+		return fmt.Errorf("error creating %s: %s", key, err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing %s: %s", key, err)
+	}
+
+	if err := os.Rename(f.Name(), s.file(key)); err != nil {
+		return fmt.Errorf("error writing %s: %s", key, err)
+	}
+
+	return nil
+}
+
+func (s dirStore) List(prefix string) ([]Entry, error) {
+	entries := []Entry{}
+	err := filepath.Walk(s.root, func(file string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil || !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), tmpPrefix) {
+			return err
+		}
+
+		rel, err := filepath.Rel(s.root, file)
+		if err != nil {
+			return err
+		}
+
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			entries = append(entries, Entry{Key: key, Size: info.Size(), LastUsed: info.ModTime()})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing entries of %s: %s", s.root, err)
+	}
+
+	return entries, nil
+}
+
+func (s dirStore) Delete(key string) error {
+	if err := os.Remove(s.file(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error deleting %s: %s", key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listScript prints '<size> <mtime> <path>' of all files below the directory
+// $1 -- a missing directory has no entries
+const listScript = `cd "$1" 2>/dev/null || exit 0; find . -type f -exec stat -c '%s %Y %n' {} +`
+
+// kubectlStore reads the entries of a directory of a running pod with
+// kubectl exec, e.g. of a persistent volume claim that is mounted into the
+// pod -- it is read-only
+type kubectlStore struct {
+	namespace string
+	pod       string
+	root      string
+}
+
+// NewKubectlStore returns a read-only store of the entries below root of the
+// pod -- an empty namespace is the namespace of the current kubectl context
+func NewKubectlStore(namespace, pod, root string) Store {
+	return kubectlStore{namespace: namespace, pod: pod, root: root}
+}
+
+func (s kubectlStore) exec(command ...string) *exec.Cmd {
+	args := []string{}
+	if s.namespace != "" {
+		args = append(args, "--namespace", s.namespace)
+	}
+	args = append(args, "exec", s.pod, "--")
+
+	return exec.Command("kubectl", append(args, command...)...)
+}
+
+// Get streams the entry from the pod
+func (s kubectlStore) Get(key string) (io.ReadCloser, error) {
+	file := path.Join(s.root, key)
+	if err := s.exec("test", "-f", file).Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error reading %s: %s", key, err)
+	}
+
+	cmd := s.exec("cat", file)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %s", key, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error reading %s: %s", key, err)
+	}
+
+	return &commandReader{ReadCloser: stdout, cmd: cmd, stderr: stderr}, nil
+}
+
+func (s kubectlStore) Put(key string, r io.Reader, size int64) error {
+	return fmt.Errorf("error writing %s: store is read-only", key)
+}
+
+func (s kubectlStore) List(prefix string) ([]Entry, error) {
+	out, err := s.exec("sh", "-c", listScript, "sh", s.root).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("%s: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("error listing entries of %s: %s", s.root, err)
+	}
+
+	return parseFileList(out, prefix)
+}
+
+func (s kubectlStore) Delete(key string) error {
+	return fmt.Errorf("error deleting %s: store is read-only", key)
+}
+
+// parseFileList returns the entries of the output of listScript whose keys
+// start with prefix -- entries that are currently written are skipped
+func parseFileList(out []byte, prefix string) ([]Entry, error) {
+	entries := []Entry{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected line in file list: %s", scanner.Text())
+		}
+
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size in file list: %s", scanner.Text())
+		}
+		mtime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modification time in file list: %s", scanner.Text())
+		}
+
+		key := strings.TrimPrefix(fields[2], "./")
+		if strings.HasPrefix(path.Base(key), tmpPrefix) || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		entries = append(entries, Entry{Key: key, Size: size, LastUsed: time.Unix(mtime, 0)})
+	}
+
+	return entries, scanner.Err()
+}
+
+// commandReader reads the output of a command -- Close waits for the
+// command to exit
+type commandReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func (r *commandReader) Close() error {
+	r.ReadCloser.Close()
+	if err := r.cmd.Wait(); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(r.stderr.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFileList(t *testing.T) {
+	out := []byte(`7 1600000000 ./1/build/binary.tar.gz
+12 1600000100 ./1/test/report with spaces.tar.gz
+3 1600000200 ./2/build/.jindra-tmp-123
+`)
+
+	entries, err := parseFileList(out, "1/")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %v", entries)
+	}
+	if entries[0].Key != "1/build/binary.tar.gz" || entries[0].Size != 7 || !entries[0].LastUsed.Equal(time.Unix(1600000000, 0)) {
+		t.Errorf("unexpected entry: %v", entries[0])
+	}
+	if entries[1].Key != "1/test/report with spaces.tar.gz" || entries[1].Size != 12 {
+		t.Errorf("unexpected entry: %v", entries[1])
+	}
+
+	if entries, err := parseFileList(out, ""); err != nil || len(entries) != 2 {
+		t.Errorf("expected entries that are currently written to be skipped, got %v (%v)", entries, err)
+	}
+
+	if _, err := parseFileList([]byte("garbage\n"), ""); err == nil {
+		t.Errorf("expected an error for an unexpected line")
+	}
+
+	if entries, err := parseFileList([]byte{}, ""); err != nil || len(entries) != 0 {
+		t.Errorf("expected no entries for empty output, got %v (%v)", entries, err)
+	}
+}
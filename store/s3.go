@@ -0,0 +1,101 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	minio "github.com/minio/minio-go/v6"
+)
+
+// s3Store keeps entries as objects of a s3 compatible object storage. The
+// last modification of an object is its last usage, so entries are evicted
+// in the order they were written.
+type s3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Store returns a store that keeps entries below prefix in bucket --
+// the scheme of endpoint (e.g. https://s3.amazonaws.com or http://minio:9000)
+// decides whether tls is used
+func NewS3Store(endpoint, bucket, prefix, region, accessKeyID, secretAccessKey string) (Store, error) {
+	if endpoint == "" || bucket == "" {
+		return nil, fmt.Errorf("endpoint and bucket of the s3 store must be set")
+	}
+
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	if prefix != "" {
+		prefix = strings.TrimSuffix(prefix, "/") + "/"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint '%s' (must be an url like https://s3.amazonaws.com)", endpoint)
+	}
+
+	client, err := minio.NewWithRegion(u.Host, accessKeyID, secretAccessKey, u.Scheme == "https", region)
+	if err != nil {
+		return nil, fmt.Errorf("error creating s3 client: %s", err)
+	}
+
+	return s3Store{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+func (s s3Store) Get(key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(s.bucket, s.prefix+key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s: %s", key, err)
+	}
+
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("error getting %s: %s", key, err)
+	}
+
+	return object, nil
+}
+
+func (s s3Store) Put(key string, r io.Reader, size int64) error {
+	if _, err := s.client.PutObject(s.bucket, s.prefix+key, r, size, minio.PutObjectOptions{}); err != nil {
+		return fmt.Errorf("error uploading %s: %s", key, err)
+	}
+
+	return nil
+}
+
+func (s s3Store) List(prefix string) ([]Entry, error) {
+	done := make(chan struct{})
+	defer close(done)
+
+	entries := []Entry{}
+	for object := range s.client.ListObjectsV2(s.bucket, s.prefix+prefix, true, done) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("error listing objects of %s/%s: %s", s.bucket, s.prefix+prefix, object.Err)
+		}
+		entries = append(entries, Entry{
+			Key:      strings.TrimPrefix(object.Key, s.prefix),
+			Size:     object.Size,
+			LastUsed: object.LastModified,
+		})
+	}
+
+	return entries, nil
+}
+
+func (s s3Store) Delete(key string) error {
+	if err := s.client.RemoveObject(s.bucket, s.prefix+key); err != nil {
+		return fmt.Errorf("error deleting %s: %s", key, err)
+	}
+
+	return nil
+}
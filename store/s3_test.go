@@ -0,0 +1,169 @@
+package store
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is a minimal in-memory stand-in for minio: it supports path style
+// list (v2), get, put and delete requests and ignores signatures
+type fakeS3 struct {
+	sync.Mutex
+	objects map[string][]byte
+}
+
+type fakeS3ListEntry struct {
+	Key          string
+	ETag         string
+	Size         int
+	LastModified string
+}
+
+type fakeS3ListResult struct {
+	XMLName  xml.Name `xml:"ListBucketResult"`
+	Name     string
+	Prefix   string
+	KeyCount int
+	MaxKeys  int
+	Contents []fakeS3ListEntry
+}
+
+// readAWSChunked decodes a body sent with a streaming v4 signature
+func readAWSChunked(r io.Reader) ([]byte, error) {
+	content := []byte{}
+	br := bufio.NewReader(r)
+	for {
+		header, err := br.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.ParseInt(strings.Split(strings.TrimSpace(header), ";")[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(br, chunk); err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return content, nil
+		}
+		content = append(content, chunk[:size]...)
+	}
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+
+	tokens := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucket, key := tokens[0], ""
+	if len(tokens) == 2 {
+		key = tokens[1]
+	}
+
+	content, ok := s.objects[bucket+"/"+key]
+
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		result := fakeS3ListResult{Name: bucket, Prefix: r.URL.Query().Get("prefix"), MaxKeys: 1000}
+		for name, content := range s.objects {
+			if strings.HasPrefix(name, bucket+"/"+result.Prefix) {
+				result.Contents = append(result.Contents, fakeS3ListEntry{
+					Key:          strings.TrimPrefix(name, bucket+"/"),
+					ETag:         fmt.Sprintf(`"%x"`, md5.Sum(content)),
+					Size:         len(content),
+					LastModified: "2020-02-20T12:00:00.000Z",
+				})
+			}
+		}
+		result.KeyCount = len(result.Contents)
+		xml.NewEncoder(w).Encode(result)
+	case (r.Method == http.MethodGet || r.Method == http.MethodHead) && !ok:
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "<Error><Code>NoSuchKey</Code><Key>%s</Key></Error>", key)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(content)))
+		w.Header().Set("Last-Modified", "Thu, 20 Feb 2020 12:00:00 GMT")
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(content)))
+		if r.Method == http.MethodGet {
+			w.Write(content)
+		}
+	case r.Method == http.MethodPut:
+		var err error
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			content, err = readAWSChunked(r.Body)
+		} else {
+			content, err = ioutil.ReadAll(r.Body)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.objects[bucket+"/"+key] = content
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, md5.Sum(content)))
+	case r.Method == http.MethodDelete:
+		delete(s.objects, bucket+"/"+key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "NotImplemented", http.StatusNotImplemented)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	s3 := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(s3)
+	defer srv.Close()
+
+	store, err := NewS3Store(srv.URL, "jindra", "caches/http-fs", "", "key", "secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := store.Get("go-mod/a.tar.gz"); err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound for missing entry, got %v", err)
+	}
+
+	if err := store.Put("go-mod/a.tar.gz", strings.NewReader("archive"), 7); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, ok := s3.objects["jindra/caches/http-fs/go-mod/a.tar.gz"]; !ok {
+		t.Fatalf("expected entry to be stored below the prefix, got %v", s3.objects)
+	}
+
+	r, err := store.Get("go-mod/a.tar.gz")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	content, _ := ioutil.ReadAll(r)
+	r.Close()
+	if string(content) != "archive" {
+		t.Errorf("expected content 'archive', got '%s'", string(content))
+	}
+
+	entries, err := store.List("go-mod/")
+	if err != nil || len(entries) != 1 || entries[0].Key != "go-mod/a.tar.gz" || entries[0].Size != 7 {
+		t.Fatalf("expected go-mod/a.tar.gz to be listed, got %v (%v)", entries, err)
+	}
+
+	if err := store.Delete("go-mod/a.tar.gz"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(s3.objects) != 0 {
+		t.Errorf("expected entry to be deleted, got %v", s3.objects)
+	}
+}
@@ -0,0 +1,35 @@
+// Package store implements the backends jindra keeps data in beyond a
+// pipeline run (caches and artifacts): a directory (e.g. a persistent volume
+// claim mounted into the stage) or a s3 compatible object storage. Entries
+// are gzipped tar archives.
+package store
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned by stores if an entry does not exist
+var ErrNotFound = errors.New("entry not found")
+
+// Entry describes an entry of a store
+type Entry struct {
+	Key  string
+	Size int64
+	// LastUsed is the last time the entry was written (or read, if the store
+	// supports it)
+	LastUsed time.Time
+}
+
+// Store persists entries between pipeline runs
+type Store interface {
+	// Get returns the content of entry key or ErrNotFound
+	Get(key string) (io.ReadCloser, error)
+	// Put stores size bytes of r as entry key
+	Put(key string, r io.Reader, size int64) error
+	// List returns all entries whose keys start with prefix
+	List(prefix string) ([]Entry, error)
+	// Delete removes entry key
+	Delete(key string) error
+}